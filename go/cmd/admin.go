@@ -0,0 +1,495 @@
+package main
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/config"
+	"Voice_Assistant/internal/logging"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// 命令行工具退出时等待历史写入的时长
+const cliCloseTimeout = 10 * time.Second
+
+// openServices 加载配置并初始化服务（日志写到stderr，避免混入导出数据；除调试模式外只输出错误）
+func openServices(opts config.Options) (*config.Services, error) {
+	cfg, err := config.LoadConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("加载配置失败: %w", err)
+	}
+	logCfg := cfg.Log
+	if !strings.EqualFold(logCfg.Level, "debug") {
+		logCfg.Level = "error"
+	}
+	if err := logging.SetupTo(logCfg, os.Stderr); err != nil {
+		return nil, fmt.Errorf("初始化日志失败: %w", err)
+	}
+	return config.NewServices(cfg)
+}
+
+// closeServices 等待历史写入后关闭数据库
+func closeServices(svc *config.Services) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cliCloseTimeout)
+	defer cancel()
+	return svc.Close(ctx)
+}
+
+// withServices 初始化服务后执行fn（Ctrl+C取消执行）
+func withServices(opts config.Options, fn func(ctx context.Context, svc *config.Services) error) error {
+	svc, err := openServices(opts)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return errors.Join(fn(ctx, svc), closeServices(svc))
+}
+
+// asUser 以指定用户身份访问（管理员权限，但按该用户读写会话与所有权）；为空时为系统身份
+func asUser(ctx context.Context, userID string) context.Context {
+	if userID == "" {
+		return ctx
+	}
+	return auth.WithPrincipal(ctx, &auth.Principal{UserID: userID, Admin: true})
+}
+
+// subcommand 二级子命令（如 assistant list）
+type subcommand struct {
+	name string
+	run  func(args []string) error
+}
+
+// dispatch 按名称执行二级子命令
+func dispatch(group string, args []string, subs []subcommand) error {
+	names := make([]string, len(subs))
+	for i, sub := range subs {
+		names[i] = sub.name
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("用法: %s <%s>", group, strings.Join(names, "|"))
+	}
+	for _, sub := range subs {
+		if sub.name == args[0] {
+			return sub.run(args[1:])
+		}
+	}
+	return fmt.Errorf("未知的子命令: %s %s（可选：%s）", group, args[0], strings.Join(names, "、"))
+}
+
+// requireArgs 校验位置参数个数
+func requireArgs(fs *flag.FlagSet, args []string, n int, usage string) error {
+	if len(args) != n {
+		return fmt.Errorf("用法: %s %s", fs.Name(), usage)
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// runMigrate 创建或升级数据库表结构（打开数据库时自动执行）
+func runMigrate(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("migrate", &opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		fmt.Println("数据库结构已是最新")
+		return nil
+	})
+}
+
+func runAssistant(args []string) error {
+	return dispatch("assistant", args, []subcommand{
+		{"list", assistantList},
+		{"create", assistantCreate},
+		{"delete", assistantDelete},
+		{"sync", assistantSync},
+	})
+}
+
+func assistantList(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("assistant list", &opts)
+	asJSON := fs.Bool("json", false, "以JSON输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		assistants, err := svc.Assistant.SelectAll(ctx)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return writeJSON(os.Stdout, assistants)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\t名称\t可见性\t所有者\t定义文件\t修改时间")
+		for _, a := range assistants {
+			owner, source := a.OwnerID, a.Source
+			if owner == "" {
+				owner = "-"
+			}
+			if source == "" {
+				source = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", a.ID, a.Name, a.Visibility, owner, source, a.GmtModified)
+		}
+		return w.Flush()
+	})
+}
+
+func assistantCreate(args []string) error {
+	var opts config.Options
+	var a model.Assistant
+	fs := newFlagSet("assistant create", &opts)
+	fs.StringVar(&a.Name, "name", "", "助手名称（必填）")
+	fs.StringVar(&a.Description, "description", "", "助手描述")
+	fs.StringVar(&a.Prompt, "prompt", "", "提示词")
+	promptFile := fs.String("prompt-file", "", "从文件读取提示词（- 表示标准输入）")
+	fs.StringVar(&a.Visibility, "visibility", "", "可见性：private/shared/public（默认private）")
+	owner := fs.String("owner", "", "所有者用户ID（默认系统身份）")
+	asJSON := fs.Bool("json", false, "以JSON输出创建结果")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *promptFile != "" {
+		data, err := readInput(*promptFile)
+		if err != nil {
+			return err
+		}
+		a.Prompt = string(data)
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		saved, err := svc.Assistant.Save(asUser(ctx, *owner), &a)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return writeJSON(os.Stdout, saved)
+		}
+		fmt.Println(saved.ID)
+		return nil
+	})
+}
+
+func assistantDelete(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("assistant delete", &opts)
+	ids, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("用法: %s <助手ID>...", fs.Name())
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		var errs []error
+		for _, id := range ids {
+			if err := svc.Assistant.DeleteByID(ctx, id); err != nil {
+				errs = append(errs, fmt.Errorf("删除助手 %s 失败: %w", id, err))
+				continue
+			}
+			fmt.Printf("已删除助手 %s\n", id)
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// 同步计划中各变更类型的说明
+var syncActionNames = map[string]string{
+	model.SyncCreate:    "新建",
+	model.SyncUpdate:    "更新",
+	model.SyncUnchanged: "无变化",
+	model.SyncRelease:   "解除只读",
+	model.SyncDelete:    "删除",
+	model.SyncConflict:  "冲突（未接管）",
+}
+
+func assistantSync(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("assistant sync", &opts)
+	dryRun := fs.Bool("dry-run", false, "只显示将要发生的变更，不写入数据库")
+	asJSON := fs.Bool("json", false, "以JSON输出同步计划")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		if svc.AssistantSync == nil {
+			return errors.New("未配置assistants.dir（可通过 --set assistants.dir=目录 指定）")
+		}
+		plan, err := svc.AssistantSync.Sync(ctx, *dryRun)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return writeJSON(os.Stdout, plan)
+		}
+		for _, c := range plan.Changes {
+			if c.Action == model.SyncUnchanged {
+				continue
+			}
+			fmt.Printf("%s %s（%s，%s）\n", syncActionNames[c.Action], c.Name, c.Source, c.ID)
+			if c.Action == model.SyncConflict {
+				fmt.Println("    已存在通过API创建的同ID助手，确认接管请在文件中设置 adopt: true")
+			}
+			for _, f := range c.Fields {
+				if len(f.Diff) == 0 {
+					fmt.Printf("    %s: %q → %q\n", f.Field, f.Old, f.New)
+					continue
+				}
+				fmt.Printf("    %s:\n", f.Field)
+				for _, line := range f.Diff {
+					fmt.Printf("      %s\n", line)
+				}
+			}
+		}
+		summary := fmt.Sprintf("新建 %d，更新 %d，无变化 %d，解除只读 %d，删除 %d，冲突 %d",
+			plan.Count(model.SyncCreate), plan.Count(model.SyncUpdate), plan.Count(model.SyncUnchanged),
+			plan.Count(model.SyncRelease), plan.Count(model.SyncDelete), plan.Count(model.SyncConflict))
+		if plan.DryRun {
+			summary += "（dry-run，未写入）"
+		}
+		fmt.Println(summary)
+		return nil
+	})
+}
+
+func runHistory(args []string) error {
+	return dispatch("history", args, []subcommand{
+		{"export", historyExport},
+		{"import", historyImport},
+		{"reset", historyReset},
+	})
+}
+
+func historyExport(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("history export", &opts)
+	user := fs.String("user", "", "会话所属用户ID（默认系统身份）")
+	out := fs.String("out", "-", "输出文件（- 表示标准输出）")
+	format := fs.String("format", "", "导出格式：json/markdown/html/jsonl（默认按输出文件扩展名推断，标准输出为json）")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, pos, 1, "<助手ID> [--user 用户ID] [--format 格式] [--out 文件]"); err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = service.ExportFormatByExt(*out)
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		file, err := svc.History.Export(asUser(ctx, *user), pos[0], *format)
+		if err != nil {
+			return err
+		}
+		return writeOutput(*out, func(w io.Writer) error {
+			_, err := w.Write(file.Data)
+			return err
+		})
+	})
+}
+
+func historyImport(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("history import", &opts)
+	user := fs.String("user", "", "会话所属用户ID（默认系统身份）")
+	in := fs.String("in", "-", "导入文件（history export的输出，- 表示标准输入）")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, pos, 1, "<助手ID> [--user 用户ID] [--in 文件]"); err != nil {
+		return err
+	}
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	var history model.History
+	if err := json.Unmarshal(data, &history); err != nil {
+		return fmt.Errorf("解析导入文件失败: %w", err)
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		// 追加到已有会话之后；需要覆盖时先执行 history reset
+		ctx = asUser(ctx, *user)
+		for i, msg := range history.Messages {
+			if err := svc.History.SaveByAssistantID(ctx, pos[0], msg); err != nil {
+				return fmt.Errorf("导入第%d条消息失败（此前%d条已导入）: %w", i+1, i, err)
+			}
+		}
+		fmt.Printf("已导入%d条消息\n", len(history.Messages))
+		return nil
+	})
+}
+
+func historyReset(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("history reset", &opts)
+	user := fs.String("user", "", "会话所属用户ID（默认系统身份）")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, pos, 1, "<助手ID> [--user 用户ID]"); err != nil {
+		return err
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		if err := svc.History.ResetByAssistantID(asUser(ctx, *user), pos[0]); err != nil {
+			return err
+		}
+		fmt.Println("对话已重置")
+		return nil
+	})
+}
+
+func runArchive(args []string) error {
+	return dispatch("archive", args, []subcommand{
+		{"export", archiveExport},
+		{"import", archiveImport},
+	})
+}
+
+func archiveExport(args []string) error {
+	var opts config.Options
+	var ids []string
+	fs := newFlagSet("archive export", &opts)
+	fs.Var((*repeatedFlag)(&ids), "assistant", "只导出指定助手（可重复，默认全部）")
+	out := fs.String("out", "-", "输出文件（- 表示标准输出）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		archive, err := svc.Archive.Export(ctx, ids)
+		if err != nil {
+			return err
+		}
+		return writeOutput(*out, func(w io.Writer) error { return writeJSON(w, archive) })
+	})
+}
+
+func archiveImport(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("archive import", &opts)
+	in := fs.String("in", "-", "归档文件（archive export的输出，- 表示标准输入）")
+	conflict := fs.String("conflict", model.ConflictSkip, "ID冲突处理：skip（跳过）/overwrite（覆盖）/new_id（以新ID导入）")
+	dryRun := fs.Bool("dry-run", false, "只预览导入结果，不写入数据库")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	var archive model.Archive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return fmt.Errorf("解析归档文件失败: %w", err)
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		report, err := svc.Archive.Import(ctx, &archive, *conflict, *dryRun)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "原ID\t导入后ID\t名称\t处理\t消息数")
+		for _, item := range report.Items {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", item.SourceID, item.ID, item.Name, item.Action, item.Messages)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		prefix := "导入完成"
+		if report.DryRun {
+			prefix = "预览（未写入）"
+		}
+		fmt.Printf("%s：新建%d，覆盖%d，跳过%d\n", prefix, report.Created, report.Overwritten, report.Skipped)
+		return nil
+	})
+}
+
+func runDB(args []string) error {
+	return dispatch("db", args, []subcommand{
+		{"backup", dbBackup},
+		{"vacuum", dbVacuum},
+	})
+}
+
+func dbBackup(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("db backup", &opts)
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, pos, 1, "<备份文件>"); err != nil {
+		return err
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		if err := svc.Maintenance.Backup(ctx, pos[0]); err != nil {
+			return err
+		}
+		fmt.Printf("已备份到 %s\n", pos[0])
+		return nil
+	})
+}
+
+func dbVacuum(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("db vacuum", &opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return withServices(opts, func(ctx context.Context, svc *config.Services) error {
+		if err := svc.Maintenance.Vacuum(ctx); err != nil {
+			return err
+		}
+		fmt.Println("数据库整理完成")
+		return nil
+	})
+}
+
+// readInput 读取文件内容（- 表示标准输入）
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	return data, nil
+}
+
+// writeOutput 写入文件（- 表示标准输出）
+func writeOutput(path string, write func(io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"Voice_Assistant/internal/config"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/service"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 聊天中可用的斜杠命令
+const chatHelp = `可用命令:
+  /history [N]    查看最近N轮对话（默认全部）
+  /reset          重置当前对话
+  /switch         切换助手
+  /export [文件]  导出当前对话（按扩展名选择格式：.md/.html/.jsonl/.json，默认 <助手ID>.md）
+  /help           显示帮助
+  /quit           退出（也可按Ctrl+D）
+回复过程中按Ctrl+C可中断本次回复`
+
+// runChat 终端交互式对话（指定--server时连接远程服务，否则在进程内直接调用服务）
+func runChat(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("chat", &opts)
+	server := fs.String("server", "", "远程服务地址，如 http://localhost:8080（为空时在进程内对话）")
+	apiKey := fs.String("api-key", os.Getenv("VA_API_KEY"), "远程服务的API密钥或会话令牌（默认读取环境变量VA_API_KEY）")
+	user := fs.String("user", "", "进程内对话时的用户ID（默认系统身份）")
+	assistantID := fs.String("assistant", "", "直接进入指定助手的对话")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *server != "" {
+		backend, err := newRemoteBackend(*server, *apiKey)
+		if err != nil {
+			return err
+		}
+		return newChatSession(backend).run(context.Background(), *assistantID)
+	}
+	// Ctrl+C由对话自行处理（中断当前回复），不使用withServices
+	svc, err := openServices(opts)
+	if err != nil {
+		return err
+	}
+	err = newChatSession(&localBackend{svc: svc, user: *user}).run(context.Background(), *assistantID)
+	return errors.Join(err, closeServices(svc))
+}
+
+// chatSession 一次交互式对话
+type chatSession struct {
+	backend   chatBackend
+	in        *bufio.Scanner
+	out       io.Writer
+	assistant *model.Assistant
+
+	// 当前回复的取消函数（Ctrl+C只中断回复，不退出）
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func newChatSession(backend chatBackend) *chatSession {
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &chatSession{backend: backend, in: in, out: os.Stdout}
+}
+
+func (s *chatSession) run(ctx context.Context, assistantID string) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	go func() {
+		for range sigs {
+			s.mu.Lock()
+			if s.cancel != nil {
+				s.cancel()
+			} else {
+				fmt.Fprint(s.out, "\n（输入 /quit 或按Ctrl+D退出）\n> ")
+			}
+			s.mu.Unlock()
+		}
+	}()
+
+	if err := s.choose(ctx, assistantID); err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, "输入消息开始对话，/help 查看命令")
+	for {
+		line, ok := s.readLine("> ")
+		if !ok {
+			fmt.Fprintln(s.out)
+			return nil
+		}
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "/") {
+			s.send(ctx, line)
+			continue
+		}
+		quit, err := s.command(ctx, line)
+		if err != nil {
+			fmt.Fprintf(s.out, "错误: %v\n", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// readLine 输出提示并读取一行（输入结束时返回false）
+func (s *chatSession) readLine(prompt string) (string, bool) {
+	fmt.Fprint(s.out, prompt)
+	if !s.in.Scan() {
+		return "", false
+	}
+	return strings.TrimSpace(s.in.Text()), true
+}
+
+// choose 选择助手（指定ID时直接使用）
+func (s *chatSession) choose(ctx context.Context, assistantID string) error {
+	assistants, err := s.backend.Assistants(ctx)
+	if err != nil {
+		return fmt.Errorf("查询助手失败: %w", err)
+	}
+	if len(assistants) == 0 {
+		return errors.New("没有可用的助手，请先创建（assistant create）")
+	}
+	if assistantID != "" {
+		for i := range assistants {
+			if assistants[i].ID == assistantID {
+				s.enter(&assistants[i])
+				return nil
+			}
+		}
+		return fmt.Errorf("助手不存在或无权访问: %s", assistantID)
+	}
+
+	for i, a := range assistants {
+		fmt.Fprintf(s.out, "  %d. %s", i+1, a.Name)
+		if a.Description != "" {
+			fmt.Fprintf(s.out, " - %s", a.Description)
+		}
+		fmt.Fprintln(s.out)
+	}
+	for {
+		line, ok := s.readLine(fmt.Sprintf("选择助手 [1-%d]: ", len(assistants)))
+		if !ok {
+			return errors.New("未选择助手")
+		}
+		n, err := strconv.Atoi(line)
+		if err == nil && n >= 1 && n <= len(assistants) {
+			s.enter(&assistants[n-1])
+			return nil
+		}
+		fmt.Fprintln(s.out, "请输入列表中的编号")
+	}
+}
+
+func (s *chatSession) enter(a *model.Assistant) {
+	s.assistant = a
+	fmt.Fprintf(s.out, "已进入「%s」的对话\n", a.Name)
+}
+
+// send 发送消息并实时输出回复
+func (s *chatSession) send(ctx context.Context, text string) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.cancel = nil
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	fmt.Fprintf(s.out, "%s: ", s.assistant.Name)
+	usage, err := s.backend.Stream(ctx, s.assistant.ID, text, func(chunk string) {
+		fmt.Fprint(s.out, chunk)
+	})
+	fmt.Fprintln(s.out)
+	switch {
+	case ctx.Err() != nil:
+		fmt.Fprintln(s.out, "（已中断）")
+	case err != nil:
+		fmt.Fprintf(s.out, "错误: %v\n", err)
+	case usage != nil:
+		fmt.Fprintf(s.out, "（tokens: 输入%d / 输出%d）\n", usage.InputTokens, usage.OutputTokens)
+	}
+}
+
+// command 执行斜杠命令，返回是否退出
+func (s *chatSession) command(ctx context.Context, line string) (bool, error) {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "/quit", "/exit":
+		return true, nil
+	case "/help":
+		fmt.Fprintln(s.out, chatHelp)
+	case "/reset":
+		if err := s.backend.Reset(ctx, s.assistant.ID); err != nil {
+			return false, err
+		}
+		fmt.Fprintln(s.out, "对话已重置")
+	case "/switch":
+		return false, s.choose(ctx, "")
+	case "/history":
+		limit := 0
+		if len(fields) > 1 {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n <= 0 {
+				return false, errors.New("用法: /history [N]")
+			}
+			limit = n
+		}
+		return false, s.printHistory(ctx, limit)
+	case "/export":
+		path := s.assistant.ID + ".md"
+		if len(fields) > 1 {
+			path = fields[1]
+		}
+		data, err := s.backend.Export(ctx, s.assistant.ID, service.ExportFormatByExt(path))
+		if err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return false, fmt.Errorf("写入文件失败: %w", err)
+		}
+		fmt.Fprintf(s.out, "已导出到 %s\n", path)
+	default:
+		return false, fmt.Errorf("未知命令 %s，/help 查看可用命令", fields[0])
+	}
+	return false, nil
+}
+
+// printHistory 输出最近limit轮对话（0为全部）
+func (s *chatSession) printHistory(ctx context.Context, limit int) error {
+	history, err := s.backend.History(ctx, s.assistant.ID)
+	if err != nil {
+		return err
+	}
+	messages := history.Messages
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	if len(messages) == 0 {
+		fmt.Fprintln(s.out, "（暂无对话）")
+	}
+	for _, m := range messages {
+		fmt.Fprintf(s.out, "[%s]\n", m.GmtCreate)
+		if m.Input.Send != "" {
+			fmt.Fprintf(s.out, "你: %s\n", m.Input.Send)
+		}
+		fmt.Fprintf(s.out, "%s: %s\n\n", s.assistant.Name, m.Output.Content)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"Voice_Assistant/internal/config"
+	"Voice_Assistant/internal/model"
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// chatBackend 对话后端：远程服务（HTTP+SSE）或进程内服务
+type chatBackend interface {
+	Assistants(ctx context.Context) ([]model.Assistant, error)
+	History(ctx context.Context, assistantID string) (*model.History, error)
+	Reset(ctx context.Context, assistantID string) error
+	Export(ctx context.Context, assistantID, format string) ([]byte, error)
+	// Stream 发送消息并逐段回调回复内容，返回本次用量
+	Stream(ctx context.Context, assistantID, text string, onChunk func(string)) (*model.Usage, error)
+}
+
+// localBackend 直接调用进程内的业务服务
+type localBackend struct {
+	svc  *config.Services
+	user string // 会话所属用户ID（空为系统身份）
+}
+
+func (b *localBackend) Assistants(ctx context.Context) ([]model.Assistant, error) {
+	return b.svc.Assistant.SelectAll(asUser(ctx, b.user))
+}
+
+func (b *localBackend) History(ctx context.Context, assistantID string) (*model.History, error) {
+	history, err := b.svc.History.SelectByAssistantID(asUser(ctx, b.user), assistantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &model.History{AssistantID: assistantID, UserID: b.user}, nil
+	}
+	return history, err
+}
+
+func (b *localBackend) Reset(ctx context.Context, assistantID string) error {
+	return b.svc.History.ResetByAssistantID(asUser(ctx, b.user), assistantID)
+}
+
+func (b *localBackend) Export(ctx context.Context, assistantID, format string) ([]byte, error) {
+	file, err := b.svc.History.Export(asUser(ctx, b.user), assistantID, format)
+	if err != nil {
+		return nil, err
+	}
+	return file.Data, nil
+}
+
+func (b *localBackend) Stream(ctx context.Context, assistantID, text string, onChunk func(string)) (*model.Usage, error) {
+	contentChan, errChan, usage, err := b.svc.History.StreamProcessMessage(asUser(ctx, b.user), assistantID, model.Input{Send: text})
+	if err != nil {
+		return nil, err
+	}
+	for chunk := range contentChan {
+		onChunk(chunk)
+	}
+	// contentChan在历史保存后关闭，此时错误通道已有结果（或已关闭）
+	return usage, <-errChan
+}
+
+// remoteBackend 通过HTTP调用远程服务
+type remoteBackend struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+const apiPrefix = "/api/voice-robot/v1"
+
+// apiResult 接口统一返回结构（Data延迟解析）
+type apiResult struct {
+	Success bool            `json:"success"`
+	Msg     string          `json:"msg"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// streamEvent 流式接口的SSE数据
+type streamEvent struct {
+	Content string       `json:"content"`
+	Error   string       `json:"error"`
+	Done    bool         `json:"done"`
+	Usage   *model.Usage `json:"usage"`
+}
+
+func newRemoteBackend(server, apiKey string) (*remoteBackend, error) {
+	u, err := url.Parse(server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("服务地址不合法: %s", server)
+	}
+	// 流式回复时长不可预知，不设置整体超时（由Ctrl+C取消）
+	return &remoteBackend{baseURL: strings.TrimRight(server, "/"), apiKey: apiKey, client: &http.Client{}}, nil
+}
+
+func (b *remoteBackend) Assistants(ctx context.Context) ([]model.Assistant, error) {
+	var assistants []model.Assistant
+	err := b.call(ctx, http.MethodGet, "/assistant", nil, &assistants)
+	return assistants, err
+}
+
+func (b *remoteBackend) History(ctx context.Context, assistantID string) (*model.History, error) {
+	var history model.History
+	if err := b.call(ctx, http.MethodGet, "/history/"+url.PathEscape(assistantID), nil, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+func (b *remoteBackend) Reset(ctx context.Context, assistantID string) error {
+	return b.call(ctx, http.MethodDelete, "/history/"+url.PathEscape(assistantID), nil, nil)
+}
+
+func (b *remoteBackend) Export(ctx context.Context, assistantID, format string) ([]byte, error) {
+	path := "/history/" + url.PathEscape(assistantID) + "/export?format=" + url.QueryEscape(format)
+	resp, err := b.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (b *remoteBackend) Stream(ctx context.Context, assistantID, text string, onChunk func(string)) (*model.Usage, error) {
+	resp, err := b.do(ctx, http.MethodPost, "/history/"+url.PathEscape(assistantID)+"/stream-process", model.Input{Send: text})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp)
+	}
+
+	var streamErr error
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var ev streamEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return nil, fmt.Errorf("解析流式数据失败: %w", err)
+		}
+		switch {
+		case ev.Error != "":
+			streamErr = errors.New(ev.Error)
+		case ev.Done:
+			return ev.Usage, streamErr
+		default:
+			onChunk(ev.Content)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取流式回复失败: %w", err)
+	}
+	return nil, errors.Join(streamErr, errors.New("流式回复意外中断"))
+}
+
+// call 调用普通接口并解析Data
+func (b *remoteBackend) call(ctx context.Context, method, path string, body, out any) error {
+	resp, err := b.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return decodeError(resp)
+	}
+	var result apiResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	if !result.Success {
+		return errors.New(result.Msg)
+	}
+	if out == nil || len(result.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+func (b *remoteBackend) do(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+apiPrefix+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求服务失败: %w", err)
+	}
+	return resp, nil
+}
+
+// decodeError 从错误响应中提取提示信息
+func decodeError(resp *http.Response) error {
+	var result apiResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err == nil && result.Msg != "" {
+		return fmt.Errorf("%s（HTTP %d）", result.Msg, resp.StatusCode)
+	}
+	return fmt.Errorf("请求失败: HTTP %d", resp.StatusCode)
+}
@@ -2,16 +2,96 @@ package main
 
 import (
 	"Voice_Assistant/internal/config"
-	"log"
-	"net/http"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
 )
 
+// repeatedFlag 可重复指定的参数（如 --set key=value）
+type repeatedFlag []string
+
+func (s *repeatedFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *repeatedFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// command 子命令
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+// 子命令（未指定时执行serve，兼容旧的启动方式）
+var commands = []command{
+	{"serve", "serve                               启动HTTP服务（默认）", runServe},
+	{"chat", "chat                                终端交互式对话", runChat},
+	{"migrate", "migrate                             创建或升级数据库表结构", runMigrate},
+	{"assistant", "assistant list|create|delete|sync   管理助手", runAssistant},
+	{"history", "history export|import|reset         管理对话历史", runHistory},
+	{"archive", "archive export|import               导出或导入助手归档（含全部会话）", runArchive},
+	{"db", "db backup|vacuum                    备份或整理数据库", runDB},
+}
+
 func main() {
-	// 1. 初始化应用并获取路由和配置
-	router, cfg, err := config.SetupApp()
-	if err != nil {
-		log.Fatalf("应用初始化失败: %v", err)
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage()
+		return
 	}
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "未知的子命令: %s\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "用法: %s <子命令> [参数]\n\n子命令:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\n各子命令均支持 --config 与 --set，使用 <子命令> -h 查看详细参数\n")
+}
+
+// newFlagSet 创建子命令参数集（附带通用的配置参数）
+func newFlagSet(name string, opts *config.Options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&opts.Path, "config", "", "配置文件路径（默认依次查找 ./application.yaml、./config/、./internal/config/ 及可执行文件所在目录）")
+	fs.Var((*repeatedFlag)(&opts.Sets), "set", "覆盖配置项，如 --set server.port=:9090（可重复）")
+	return fs
+}
 
-	log.Fatal(http.ListenAndServe(cfg.Server.Port, router))
+// parseArgs 解析参数，允许参数与位置参数交替出现（如 history export <id> --out a.json）
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"Voice_Assistant/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// 未配置时的优雅退出等待时长
+const defaultShutdownTimeout = 30 * time.Second
+
+// runServe 启动HTTP服务，收到退出信号后优雅退出
+func runServe(args []string) error {
+	var opts config.Options
+	fs := newFlagSet("serve", &opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// 1. 初始化应用并获取路由和配置
+	app, err := config.SetupApp(opts)
+	if err != nil {
+		return fmt.Errorf("应用初始化失败: %w", err)
+	}
+
+	// 2. 启动HTTP服务
+	srv := app.NewServer()
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("服务启动", "addr", srv.Addr, "tls", app.TLSEnabled())
+		if app.TLSEnabled() {
+			tls := app.Config.Server.TLS
+			serveErr <- srv.ListenAndServeTLS(tls.CertFile, tls.KeyFile)
+			return
+		}
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	// 3. 监听配置文件变化（可选）
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	if app.Config.Reload.Watch {
+		go app.Watch(watchCtx)
+	}
+
+	// 4. 等待退出信号（SIGHUP重新加载配置）
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+wait:
+	for {
+		select {
+		case err := <-serveErr:
+			app.Close(context.Background())
+			return fmt.Errorf("服务异常退出: %w", err)
+		case sig := <-stop:
+			if sig == syscall.SIGHUP {
+				if err := app.Reload(); err != nil {
+					slog.Error("重新加载配置失败，继续使用当前配置", "error", err)
+				}
+				continue
+			}
+			slog.Info("收到退出信号，开始优雅退出", "signal", sig.String())
+			break wait
+		}
+	}
+	stopWatch()
+
+	// 5. 停止接收新连接，等待进行中的请求（含SSE流）结束
+	timeout := defaultShutdownTimeout
+	if app.Config.Server.ShutdownTimeoutSec > 0 {
+		timeout = time.Duration(app.Config.Server.ShutdownTimeoutSec) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Warn("等待请求结束超时，强制关闭连接", "error", err)
+		srv.Close()
+	}
+
+	// 6. 等待历史写入后释放资源（强制关闭连接后仍在写入的历史需要单独的等待时间）
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), timeout)
+	defer closeCancel()
+	if err := app.Close(closeCtx); err != nil {
+		return fmt.Errorf("释放资源失败: %w", err)
+	}
+	slog.Info("服务已退出")
+	return nil
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	apiKeyService service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// SelectAll 获取所有API密钥（不含明文）
+func (h *APIKeyHandler) SelectAll(c *gin.Context) {
+	keys, err := h.apiKeyService.SelectAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "获取成功", Data: keys})
+}
+
+// Save 创建API密钥（明文仅返回一次）
+func (h *APIKeyHandler) Save(c *gin.Context) {
+	var req struct {
+		Name         string   `json:"name"`
+		Scope        string   `json:"scope"`
+		AssistantIDs []string `json:"assistant_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "参数格式错误"})
+		return
+	}
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "名称为必填项"})
+		return
+	}
+	for _, id := range req.AssistantIDs {
+		if !isValidUUID(id) {
+			c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "助手ID格式不正确: " + id})
+			return
+		}
+	}
+
+	key, raw, err := h.apiKeyService.Create(c.Request.Context(), req.Name, req.Scope, req.AssistantIDs)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidScope) {
+			c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.Result{
+		Success: true,
+		Msg:     "创建成功，请妥善保存密钥，之后将无法再次查看",
+		Data: gin.H{
+			"key":     raw,
+			"api_key": key,
+		},
+	})
+}
+
+// DeleteByID 吊销API密钥
+func (h *APIKeyHandler) DeleteByID(c *gin.Context) {
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "ID格式不正确"})
+		return
+	}
+
+	if err := h.apiKeyService.DeleteByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "删除成功"})
+}
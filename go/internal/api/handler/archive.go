@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ArchiveHandler struct {
+	archiveService service.ArchiveService
+}
+
+func NewArchiveHandler(archiveService service.ArchiveService) *ArchiveHandler {
+	return &ArchiveHandler{archiveService: archiveService}
+}
+
+// Export 导出全部助手（或通过assistant_id参数指定多个）的归档文件
+func (h *ArchiveHandler) Export(c *gin.Context) {
+	h.export(c, c.QueryArray("assistant_id"))
+}
+
+// ExportOne 导出单个助手的归档文件
+func (h *ArchiveHandler) ExportOne(c *gin.Context) {
+	h.export(c, []string{c.Param("id")})
+}
+
+func (h *ArchiveHandler) export(c *gin.Context, ids []string) {
+	for _, id := range ids {
+		if !isValidUUID(id) {
+			c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "助手ID格式不正确: " + id})
+			return
+		}
+	}
+	archive, err := h.archiveService.Export(c.Request.Context(), ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	// 直接输出归档内容（不包装Result），下载的文件可原样导入
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: "序列化归档失败"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "assistants-"+time.Now().Format("20060102-150405")+".json"))
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// Import 导入归档（conflict：skip/overwrite/new_id，dry_run=true时只预览结果）
+func (h *ArchiveHandler) Import(c *gin.Context) {
+	// 限制请求体大小，超大归档不会被完整读入内存
+	limit := h.archiveService.MaxImportSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	var archive model.Archive
+	if err := c.ShouldBindJSON(&archive); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, model.Result{Success: false, Msg: fmt.Sprintf("归档文件过大（上限%dMB）", limit>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "归档格式错误: " + err.Error()})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	report, err := h.archiveService.Import(c.Request.Context(), &archive, c.DefaultQuery("conflict", model.ConflictSkip), dryRun)
+	if errors.Is(err, service.ErrInvalidArchive) || errors.Is(err, service.ErrInvalidConflict) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	msg := "导入成功"
+	if dryRun {
+		msg = "预览成功（未写入）"
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: msg, Data: report})
+}
@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"Voice_Assistant/internal/api/middleware"
 	"Voice_Assistant/internal/model"
 	"Voice_Assistant/internal/service"
+	"errors"
 	"net/http"
 	"regexp"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -33,10 +36,19 @@ func (h *AssistantHandler) SelectAll(c *gin.Context) {
 		})
 		return
 	}
+
+	// 限定助手范围的密钥只能看到被授权的助手
+	key := middleware.CurrentAPIKey(c)
+	visible := make([]model.Assistant, 0, len(assistants))
+	for _, a := range assistants {
+		if service.CanAccessAssistant(key, a.ID) {
+			visible = append(visible, a)
+		}
+	}
 	c.JSON(http.StatusOK, model.Result{
 		Success: true,
 		Msg:     "获取成功",
-		Data:    assistants,
+		Data:    visible,
 	})
 }
 
@@ -62,6 +74,10 @@ func (h *AssistantHandler) DeleteByID(c *gin.Context) {
 	}
 
 	if err := h.assistantService.DeleteByID(c.Request.Context(), id); err != nil {
+		if errors.Is(err, service.ErrManagedAssistant) {
+			c.JSON(http.StatusForbidden, model.Result{Success: false, Msg: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, model.Result{
 			Success: false,
 			Msg:     err.Error(),
@@ -99,6 +115,10 @@ func (h *AssistantHandler) Save(c *gin.Context) {
 
 	saved, err := h.assistantService.Save(c.Request.Context(), &assistant)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidPrompt) {
+			c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, model.Result{
 			Success: false,
 			Msg:     err.Error(),
@@ -146,6 +166,18 @@ func (h *AssistantHandler) UpdateByID(c *gin.Context) {
 
 	updated, err := h.assistantService.UpdateByID(c.Request.Context(), id, &assistant)
 	if err != nil {
+		if errors.Is(err, service.ErrManagedAssistant) || errors.Is(err, service.ErrAssistantNotOwner) {
+			c.JSON(http.StatusForbidden, model.Result{Success: false, Msg: err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrAssistantConflict) {
+			c.JSON(http.StatusConflict, model.Result{Success: false, Msg: err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidPrompt) {
+			c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, model.Result{
 			Success: false,
 			Msg:     err.Error(),
@@ -159,3 +191,80 @@ func (h *AssistantHandler) UpdateByID(c *gin.Context) {
 		Data:    updated,
 	})
 }
+
+// ListRevisions 查询助手的版本历史
+func (h *AssistantHandler) ListRevisions(c *gin.Context) {
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "ID格式不正确"})
+		return
+	}
+
+	revisions, err := h.assistantService.ListRevisions(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "获取成功", Data: revisions})
+}
+
+// DiffRevisions 比较两个版本（?from=1&to=3，省略to时与当前版本比较）
+func (h *AssistantHandler) DiffRevisions(c *gin.Context) {
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "ID格式不正确"})
+		return
+	}
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil || from <= 0 {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "from必须为正整数"})
+		return
+	}
+	to, err := strconv.Atoi(c.DefaultQuery("to", "0"))
+	if err != nil || to < 0 {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "to必须为正整数"})
+		return
+	}
+
+	diff, err := h.assistantService.DiffRevisions(c.Request.Context(), id, from, to)
+	if errors.Is(err, service.ErrRevisionNotFound) {
+		c.JSON(http.StatusNotFound, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "获取成功", Data: diff})
+}
+
+// Rollback 回滚到指定版本
+func (h *AssistantHandler) Rollback(c *gin.Context) {
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "ID格式不正确"})
+		return
+	}
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || revision <= 0 {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "版本号必须为正整数"})
+		return
+	}
+
+	updated, err := h.assistantService.Rollback(c.Request.Context(), id, revision)
+	switch {
+	case errors.Is(err, service.ErrRevisionNotFound):
+		c.JSON(http.StatusNotFound, model.Result{Success: false, Msg: err.Error()})
+		return
+	case errors.Is(err, service.ErrManagedAssistant), errors.Is(err, service.ErrAssistantNotOwner):
+		c.JSON(http.StatusForbidden, model.Result{Success: false, Msg: err.Error()})
+		return
+	case errors.Is(err, service.ErrAssistantConflict):
+		c.JSON(http.StatusConflict, model.Result{Success: false, Msg: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "回滚成功", Data: updated})
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	healthService service.HealthService
+}
+
+func NewHealthHandler(healthService service.HealthService) *HealthHandler {
+	return &HealthHandler{healthService: healthService}
+}
+
+// Live 存活检查（进程能响应即为存活）
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "ok"})
+}
+
+// Ready 就绪检查（依赖不可用时返回503）
+func (h *HealthHandler) Ready(c *gin.Context) {
+	ready, checks := h.healthService.Ready(c.Request.Context())
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, model.Result{Success: false, Msg: "服务未就绪", Data: checks})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "ok", Data: checks})
+}
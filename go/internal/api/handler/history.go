@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"Voice_Assistant/internal/metrics"
 	"Voice_Assistant/internal/model"
 	"Voice_Assistant/internal/service"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
@@ -37,6 +40,75 @@ func (h *HistoryHandler) SelectByAssistantID(c *gin.Context) {
 	c.JSON(http.StatusOK, model.Result{Success: true, Data: history})
 }
 
+// Export 导出会话（format：json/markdown/html/jsonl，默认markdown）
+func (h *HistoryHandler) Export(c *gin.Context) {
+	assistantID := c.Param("assistant_id")
+	if !isValidUUID(assistantID) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "无效的助手ID"})
+		return
+	}
+
+	file, err := h.historyService.Export(c.Request.Context(), assistantID, c.DefaultQuery("format", service.ExportMarkdown))
+	if errors.Is(err, service.ErrUnsupportedFormat) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Name))
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+// SelectVars 查询会话标题与提示词模板变量
+func (h *HistoryHandler) SelectVars(c *gin.Context) {
+	assistantID := c.Param("assistant_id")
+	if !isValidUUID(assistantID) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "无效的助手ID"})
+		return
+	}
+
+	vars, err := h.historyService.SelectVars(c.Request.Context(), assistantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Result{Success: true, Data: vars})
+}
+
+// SaveVars 设置会话标题与提示词模板变量（整体覆盖）
+func (h *HistoryHandler) SaveVars(c *gin.Context) {
+	assistantID := c.Param("assistant_id")
+	if !isValidUUID(assistantID) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "无效的助手ID"})
+		return
+	}
+
+	var req struct {
+		Title     string            `json:"title"`
+		Variables map[string]string `json:"variables"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "格式错误: " + err.Error()})
+		return
+	}
+
+	vars, err := h.historyService.SaveVars(c.Request.Context(), assistantID, &model.ConversationVars{Title: req.Title, Variables: req.Variables})
+	if errors.Is(err, service.ErrInvalidVariables) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "已保存", Data: vars})
+}
+
 // ResetByAssistantID 重置对话历史
 func (h *HistoryHandler) ResetByAssistantID(c *gin.Context) {
 	assistantID := c.Param("assistant_id")
@@ -46,7 +118,7 @@ func (h *HistoryHandler) ResetByAssistantID(c *gin.Context) {
 	}
 
 	if err := h.historyService.ResetByAssistantID(c.Request.Context(), assistantID); err != nil {
-		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		writeProcessError(c, err)
 		return
 	}
 
@@ -86,6 +158,33 @@ func (h *HistoryHandler) SaveByAssistantID(c *gin.Context) {
 	c.JSON(http.StatusOK, model.Result{Success: true, Data: message})
 }
 
+// ProcessMessage 非流式处理消息（同步返回完整回复）
+func (h *HistoryHandler) ProcessMessage(c *gin.Context) {
+	assistantID := c.Param("assistant_id")
+	if !isValidUUID(assistantID) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "无效的助手ID"})
+		return
+	}
+
+	var input model.Input
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "格式错误: " + err.Error()})
+		return
+	}
+	if input.Send == "" {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "发送内容不能为空"})
+		return
+	}
+
+	result, err := h.historyService.ProcessMessage(c.Request.Context(), assistantID, input)
+	if err != nil {
+		writeProcessError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "处理成功", Data: result})
+}
+
 // StreamProcessMessage 流式处理消息（修复未使用变量）
 func (h *HistoryHandler) StreamProcessMessage(c *gin.Context) {
 	assistantID := c.Param("assistant_id")
@@ -95,6 +194,21 @@ func (h *HistoryHandler) StreamProcessMessage(c *gin.Context) {
 		return
 	}
 
+	// 调用服务层（在写入SSE响应头之前，以便返回正确的状态码）
+	contentChan, llmErrChan, usage, err := h.historyService.StreamProcessMessage(c.Request.Context(), assistantID, input)
+	if err != nil {
+		writeProcessError(c, err)
+		return
+	}
+
+	defer metrics.StreamStarted()()
+
+	// 流式回复时长不可预知，取消服务端读写超时（读超时到期也会取消请求上下文）
+	rc := http.NewResponseController(c.Writer)
+	if err := errors.Join(rc.SetReadDeadline(time.Time{}), rc.SetWriteDeadline(time.Time{})); err != nil {
+		slog.WarnContext(c.Request.Context(), "取消读写超时失败", "error", err)
+	}
+
 	// 设置SSE响应头
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
@@ -103,14 +217,6 @@ func (h *HistoryHandler) StreamProcessMessage(c *gin.Context) {
 	c.Status(http.StatusOK)
 	c.Writer.(http.Flusher).Flush()
 
-	// 调用服务层（修复：直接使用llmErrChan，不定义未使用的变量）
-	contentChan, llmErrChan, _, err := h.historyService.StreamProcessMessage(c.Request.Context(), assistantID, input)
-	if err != nil {
-		c.Writer.WriteString(fmt.Sprintf("data: {\"error\":\"%s\"}\n\n", err.Error()))
-		c.Writer.(http.Flusher).Flush()
-		return
-	}
-
 	// 转发流式内容
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -134,4 +240,23 @@ func (h *HistoryHandler) StreamProcessMessage(c *gin.Context) {
 
 	// 等待所有内容处理完成
 	wg.Wait()
+
+	// 发送完成信号（携带本次用量）
+	data, _ := json.Marshal(map[string]interface{}{"done": true, "usage": usage})
+	c.Writer.WriteString(fmt.Sprintf("data: %s\n\n", data))
+	c.Writer.(http.Flusher).Flush()
+}
+
+// writeProcessError 输出消息处理错误（配额超限返回429及结构化信息）
+func writeProcessError(c *gin.Context, err error) {
+	var quotaErr *service.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		c.JSON(http.StatusTooManyRequests, model.Result{Success: false, Msg: quotaErr.Error(), Data: quotaErr})
+		return
+	}
+	if errors.Is(err, service.ErrConversationBusy) {
+		c.JSON(http.StatusConflict, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
 }
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/service"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 上传请求中除文件内容外的表单开销上限
+const multipartOverhead = 1 << 20
+
+type KnowledgeHandler struct {
+	knowledgeService service.KnowledgeService
+}
+
+func NewKnowledgeHandler(knowledgeService service.KnowledgeService) *KnowledgeHandler {
+	return &KnowledgeHandler{knowledgeService: knowledgeService}
+}
+
+// ListDocuments 助手知识库的文档
+func (h *KnowledgeHandler) ListDocuments(c *gin.Context) {
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "ID格式不正确"})
+		return
+	}
+
+	docs, err := h.knowledgeService.ListDocuments(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "获取成功", Data: docs})
+}
+
+// Upload 上传文档（multipart表单：file为文档，title可选）
+func (h *KnowledgeHandler) Upload(c *gin.Context) {
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "ID格式不正确"})
+		return
+	}
+	// 先限制请求体再解析表单（留出multipart的额外开销），超大文件不会被完整读入
+	limit := h.knowledgeService.MaxDocumentSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+multipartOverhead)
+	tooLarge := fmt.Sprintf("%s（%dMB）", service.ErrDocumentTooLarge, limit>>20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, model.Result{Success: false, Msg: tooLarge})
+			return
+		}
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "缺少上传文件（表单字段file）"})
+		return
+	}
+	if header.Size > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, model.Result{Success: false, Msg: tooLarge})
+		return
+	}
+	f, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "读取上传文件失败: " + err.Error()})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "读取上传文件失败: " + err.Error()})
+		return
+	}
+
+	doc, err := h.knowledgeService.Upload(c.Request.Context(), id, header.Filename, c.PostForm("title"), data)
+	switch {
+	case errors.Is(err, service.ErrUnsupportedDocument) || errors.Is(err, service.ErrEmptyDocument):
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: err.Error()})
+	case errors.Is(err, service.ErrDocumentTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, model.Result{Success: false, Msg: err.Error()})
+	case errors.Is(err, service.ErrNotAssistantOwner):
+		c.JSON(http.StatusForbidden, model.Result{Success: false, Msg: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+	default:
+		c.JSON(http.StatusOK, model.Result{Success: true, Msg: "上传成功", Data: doc})
+	}
+}
+
+// DeleteDocument 删除文档
+func (h *KnowledgeHandler) DeleteDocument(c *gin.Context) {
+	id, documentID := c.Param("id"), c.Param("document_id")
+	if !isValidUUID(id) || !isValidUUID(documentID) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "ID格式不正确"})
+		return
+	}
+
+	err := h.knowledgeService.DeleteDocument(c.Request.Context(), id, documentID)
+	switch {
+	case errors.Is(err, service.ErrDocumentNotFound):
+		c.JSON(http.StatusNotFound, model.Result{Success: false, Msg: err.Error()})
+	case errors.Is(err, service.ErrNotAssistantOwner):
+		c.JSON(http.StatusForbidden, model.Result{Success: false, Msg: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+	default:
+		c.JSON(http.StatusOK, model.Result{Success: true, Msg: "删除成功"})
+	}
+}
+
+// Search 检索知识库（?query=&top_k=，便于调试检索效果）
+func (h *KnowledgeHandler) Search(c *gin.Context) {
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "ID格式不正确"})
+		return
+	}
+	query := c.Query("query")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "query不能为空"})
+		return
+	}
+	topK, err := strconv.Atoi(c.DefaultQuery("top_k", "0"))
+	if err != nil || topK < 0 {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "top_k必须为正整数"})
+		return
+	}
+
+	matches, err := h.knowledgeService.Search(c.Request.Context(), id, query, topK)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "获取成功", Data: matches})
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MemoryHandler struct {
+	memoryService service.MemoryService
+}
+
+func NewMemoryHandler(memoryService service.MemoryService) *MemoryHandler {
+	return &MemoryHandler{memoryService: memoryService}
+}
+
+// memoryRequest 添加或修改记忆（assistant_id为空时对所有助手生效）
+type memoryRequest struct {
+	Content     string `json:"content"`
+	AssistantID string `json:"assistant_id"`
+}
+
+// List 当前用户的记忆（?assistant_id=只返回该助手可用的记忆）
+func (h *MemoryHandler) List(c *gin.Context) {
+	assistantID := c.Query("assistant_id")
+	if assistantID != "" && !isValidUUID(assistantID) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "无效的助手ID"})
+		return
+	}
+
+	memories, err := h.memoryService.List(c.Request.Context(), assistantID)
+	if err != nil {
+		writeMemoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "获取成功", Data: memories})
+}
+
+// Create 添加记忆
+func (h *MemoryHandler) Create(c *gin.Context) {
+	var req memoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "参数格式错误"})
+		return
+	}
+	if req.AssistantID != "" && !isValidUUID(req.AssistantID) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "无效的助手ID"})
+		return
+	}
+
+	memory, err := h.memoryService.Create(c.Request.Context(), req.Content, req.AssistantID)
+	if err != nil {
+		writeMemoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "保存成功", Data: memory})
+}
+
+// Update 修改记忆内容与适用助手（整体覆盖）
+func (h *MemoryHandler) Update(c *gin.Context) {
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "ID格式不正确"})
+		return
+	}
+	var req memoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "参数格式错误"})
+		return
+	}
+	if req.AssistantID != "" && !isValidUUID(req.AssistantID) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "无效的助手ID"})
+		return
+	}
+
+	memory, err := h.memoryService.Update(c.Request.Context(), id, req.Content, req.AssistantID)
+	if err != nil {
+		writeMemoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "修改成功", Data: memory})
+}
+
+// Delete 删除记忆
+func (h *MemoryHandler) Delete(c *gin.Context) {
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "ID格式不正确"})
+		return
+	}
+
+	if err := h.memoryService.Delete(c.Request.Context(), id); err != nil {
+		writeMemoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "删除成功"})
+}
+
+// Clear 清空记忆（?assistant_id=只清空该助手专属的记忆）
+func (h *MemoryHandler) Clear(c *gin.Context) {
+	assistantID := c.Query("assistant_id")
+	if assistantID != "" && !isValidUUID(assistantID) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "无效的助手ID"})
+		return
+	}
+
+	n, err := h.memoryService.Clear(c.Request.Context(), assistantID)
+	if err != nil {
+		writeMemoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "删除成功", Data: gin.H{"deleted": n}})
+}
+
+// writeMemoryError 按错误类型返回状态码
+func writeMemoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidMemory) || errors.Is(err, service.ErrMemoryAssistantNotFound):
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: err.Error()})
+	case errors.Is(err, service.ErrMemoryLimit):
+		c.JSON(http.StatusConflict, model.Result{Success: false, Msg: err.Error()})
+	case errors.Is(err, service.ErrMemoryNotFound):
+		c.JSON(http.StatusNotFound, model.Result{Success: false, Msg: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+	}
+}
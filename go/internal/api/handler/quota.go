@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"Voice_Assistant/internal/api/middleware"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/service"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QuotaHandler struct {
+	quotaService     service.QuotaService
+	assistantService service.AssistantService
+}
+
+func NewQuotaHandler(quotaService service.QuotaService, assistantService service.AssistantService) *QuotaHandler {
+	return &QuotaHandler{quotaService: quotaService, assistantService: assistantService}
+}
+
+// Report 查询当前用户（及可选助手）的用量与配额
+func (h *QuotaHandler) Report(c *gin.Context) {
+	assistantID := c.Query("assistant_id")
+	if assistantID != "" {
+		if !isValidUUID(assistantID) {
+			c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "无效的助手ID"})
+			return
+		}
+		if !service.CanAccessAssistant(middleware.CurrentAPIKey(c), assistantID) {
+			c.JSON(http.StatusForbidden, model.Result{Success: false, Msg: "无权访问该助手"})
+			return
+		}
+		// 只能查询自己可见的助手（按当前用户过滤后的列表判断）
+		assistants, err := h.assistantService.SelectAll(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+			return
+		}
+		if !slices.ContainsFunc(assistants, func(a model.Assistant) bool { return a.ID == assistantID }) {
+			c.JSON(http.StatusNotFound, model.Result{Success: false, Msg: "助手不存在"})
+			return
+		}
+	}
+
+	reports, err := h.quotaService.Report(c.Request.Context(), assistantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "获取成功", Data: reports})
+}
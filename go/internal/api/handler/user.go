@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"Voice_Assistant/internal/api/middleware"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/service"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserHandler struct {
+	userService service.UserService
+}
+
+func NewUserHandler(userService service.UserService) *UserHandler {
+	return &UserHandler{userService: userService}
+}
+
+type registerRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	DisplayName string `json:"display_name"`
+}
+
+// Register 注册用户（公开注册或管理员创建）
+func (h *UserHandler) Register(c *gin.Context) {
+	var req registerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "参数格式错误"})
+		return
+	}
+
+	user, err := h.userService.Register(c.Request.Context(), req.Username, req.Password, req.DisplayName)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRegisterDisabled):
+			c.JSON(http.StatusForbidden, model.Result{Success: false, Msg: err.Error()})
+		case errors.Is(err, service.ErrUsernameTaken):
+			c.JSON(http.StatusConflict, model.Result{Success: false, Msg: err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, model.Result{Success: true, Msg: "注册成功", Data: user})
+}
+
+// Login 登录并返回会话令牌
+func (h *UserHandler) Login(c *gin.Context) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "参数格式错误"})
+		return
+	}
+
+	token, expiresAt, user, err := h.userService.Login(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			c.JSON(http.StatusUnauthorized, model.Result{Success: false, Msg: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{
+		Success: true,
+		Msg:     "登录成功",
+		Data: gin.H{
+			"token":      token,
+			"expires_at": expiresAt,
+			"user":       user,
+		},
+	})
+}
+
+// Logout 注销当前会话
+func (h *UserHandler) Logout(c *gin.Context) {
+	token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	if !strings.HasPrefix(token, service.SessionTokenPrefix) {
+		c.JSON(http.StatusBadRequest, model.Result{Success: false, Msg: "当前凭证不是登录会话"})
+		return
+	}
+	if err := h.userService.Logout(c.Request.Context(), token); err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "已注销"})
+}
+
+// Me 获取当前登录用户
+func (h *UserHandler) Me(c *gin.Context) {
+	key := middleware.CurrentAPIKey(c)
+	if key == nil || key.UserID == "" {
+		c.JSON(http.StatusOK, model.Result{Success: true, Msg: "系统身份", Data: nil})
+		return
+	}
+	user, err := h.userService.SelectByID(c.Request.Context(), key.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "获取成功", Data: user})
+}
+
+// SelectAll 获取所有用户（仅管理员）
+func (h *UserHandler) SelectAll(c *gin.Context) {
+	users, err := h.userService.SelectAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.Result{Success: true, Msg: "获取成功", Data: users})
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/service"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 上下文中保存当前API密钥的键
+const apiKeyContextKey = "api_key"
+
+// Auth 校验Authorization: Bearer <key>（API密钥或登录会话令牌），
+// 并将密钥信息写入gin上下文、将身份写入请求上下文
+func Auth(apiKeyService service.APIKeyService, userService service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		rawKey, ok := strings.CutPrefix(header, "Bearer ")
+		rawKey = strings.TrimSpace(rawKey)
+		if !ok || rawKey == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.Result{Success: false, Msg: "缺少API密钥"})
+			return
+		}
+
+		var (
+			key       *model.APIKey
+			principal *auth.Principal
+			err       error
+		)
+		if strings.HasPrefix(rawKey, service.SessionTokenPrefix) {
+			// 登录会话：拥有该用户名下的全部权限
+			var user *model.User
+			if user, err = userService.Authenticate(c.Request.Context(), rawKey); err == nil {
+				key = &model.APIKey{ID: "session", UserID: user.ID, Name: user.Username, Scope: model.ScopeAdmin}
+				principal = &auth.Principal{UserID: user.ID, Admin: user.Role == model.RoleAdmin}
+			}
+		} else if key, err = apiKeyService.Authenticate(c.Request.Context(), rawKey); err == nil {
+			// 未归属用户的密钥（引导密钥或其创建的密钥）为系统身份
+			principal = &auth.Principal{Admin: true}
+			if key.UserID != "" {
+				var user *model.User
+				if user, err = userService.SelectByID(c.Request.Context(), key.UserID); err == nil {
+					principal = &auth.Principal{UserID: user.ID, Admin: user.Role == model.RoleAdmin}
+				} else {
+					err = service.ErrInvalidAPIKey
+				}
+			}
+		}
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidAPIKey) || errors.Is(err, service.ErrInvalidSession) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, model.Result{Success: false, Msg: err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, model.Result{Success: false, Msg: err.Error()})
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// Anonymous 未启用鉴权时使用：所有请求视为不受限的管理员
+func Anonymous() gin.HandlerFunc {
+	anonymous := &model.APIKey{ID: "anonymous", Name: "匿名访问", Scope: model.ScopeAdmin}
+	principal := &auth.Principal{Admin: true}
+	return func(c *gin.Context) {
+		c.Set(apiKeyContextKey, anonymous)
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// RequireScope 要求当前密钥至少具备指定权限
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !service.HasScope(CurrentAPIKey(c), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, model.Result{Success: false, Msg: "权限不足"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAssistant 要求当前密钥可访问路径参数中的助手
+func RequireAssistant(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !service.CanAccessAssistant(CurrentAPIKey(c), c.Param(param)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, model.Result{Success: false, Msg: "无权访问该助手"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdminUser 要求当前身份为管理员（用户管理等操作）
+func RequireAdminUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.IsAdmin(c.Request.Context()) {
+			c.AbortWithStatusJSON(http.StatusForbidden, model.Result{Success: false, Msg: "需要管理员权限"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUnrestricted 要求当前密钥未限定助手范围（用于密钥管理等全局操作）
+func RequireUnrestricted() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := CurrentAPIKey(c)
+		if key == nil || len(key.AssistantIDs) > 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, model.Result{Success: false, Msg: "限定助手范围的密钥无权执行该操作"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentAPIKey 获取当前请求的API密钥（未经过鉴权中间件时返回nil）
+func CurrentAPIKey(c *gin.Context) *model.APIKey {
+	v, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return nil
+	}
+	key, _ := v.(*model.APIKey)
+	return key
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"Voice_Assistant/internal/model"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`   // 允许的来源（"*"表示任意来源）
+	AllowedMethods   []string `yaml:"allowed_methods"`   // 允许的方法
+	AllowedHeaders   []string `yaml:"allowed_headers"`   // 允许的请求头
+	ExposedHeaders   []string `yaml:"exposed_headers"`   // 前端可读取的响应头
+	AllowCredentials bool     `yaml:"allow_credentials"` // 是否允许携带凭证
+	MaxAgeSec        int      `yaml:"max_age_sec"`       // 预检结果缓存时长
+}
+
+// CORS 跨域中间件（按配置回显匹配的来源，不匹配的预检请求返回403）
+func CORS(cfg CORSConfig) gin.HandlerFunc {
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", RequestIDHeader}
+	}
+	allowAll := false
+	origins := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			allowAll = true
+		}
+		origins[strings.TrimRight(o, "/")] = true
+	}
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			// 非跨域请求
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		if !allowAll && !origins[origin] {
+			if c.Request.Method == http.MethodOptions {
+				c.AbortWithStatusJSON(http.StatusForbidden, model.Result{Success: false, Msg: "不允许的跨域来源"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// 携带凭证时不能使用通配符，统一回显请求来源
+		if allowAll && !cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if exposed != "" {
+			h.Set("Access-Control-Expose-Headers", exposed)
+		}
+
+		if c.Request.Method == http.MethodOptions {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", headers)
+			if cfg.MaxAgeSec > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAgeSec))
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"Voice_Assistant/internal/model"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 超过该时长未访问的令牌桶会被清理
+const bucketIdleTTL = 10 * time.Minute
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimiter 令牌桶限流器（按客户端标识分桶）
+type RateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // 每秒补充的令牌数
+	burst     float64 // 桶容量
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter 创建限流器（ratePerMinute<=0时返回nil，表示不限流）
+func NewRateLimiter(ratePerMinute, burst int) *RateLimiter {
+	if ratePerMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:      float64(ratePerMinute) / 60,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow 尝试消耗一个令牌，失败时返回需要等待的时长
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	// 按经过的时间补充令牌
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*l.rate)
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep 定期清理长时间未访问的令牌桶（调用方需持有锁）
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleTTL {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > bucketIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// RateLimit 限流中间件（需放在鉴权中间件之后，以便按密钥/用户限流）
+func RateLimit(l *RateLimiter) gin.HandlerFunc {
+	return rateLimit(l, ClientKey)
+}
+
+// RateLimitByIP 按客户端IP限流（放在鉴权中间件之前，无效凭证的请求同样计数）
+func RateLimitByIP(l *RateLimiter) gin.HandlerFunc {
+	return rateLimit(l, func(c *gin.Context) string { return "ip:" + c.ClientIP() })
+}
+
+func rateLimit(l *RateLimiter, key func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil {
+			c.Next()
+			return
+		}
+		if ok, wait := l.Allow(key(c)); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, model.Result{Success: false, Msg: "请求过于频繁，请稍后重试"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ConcurrencyLimiter 限制每个客户端同时进行中的请求数（用于SSE流）
+type ConcurrencyLimiter struct {
+	mu       sync.Mutex
+	max      int // <=0 表示不限制（仍然计数，以便热更新开启限制后立即准确）
+	inFlight map[string]int
+}
+
+// NewConcurrencyLimiter 创建并发限制器（max<=0表示不限制）
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{max: max, inFlight: make(map[string]int)}
+}
+
+// SetMax 修改并发上限（热更新时调用，进行中的请求计数保持不变）
+func (l *ConcurrencyLimiter) SetMax(max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.max = max
+}
+
+func (l *ConcurrencyLimiter) acquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max > 0 && l.inFlight[key] >= l.max {
+		return false
+	}
+	l.inFlight[key]++
+	return true
+}
+
+func (l *ConcurrencyLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[key] <= 1 {
+		delete(l.inFlight, key)
+		return
+	}
+	l.inFlight[key]--
+}
+
+// LimitConcurrent 并发限制中间件（请求结束后释放名额）
+func LimitConcurrent(l *ConcurrencyLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := ClientKey(c)
+		if !l.acquire(key) {
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, model.Result{Success: false, Msg: "进行中的对话过多，请等待当前回复完成"})
+			return
+		}
+		defer l.release(key)
+		c.Next()
+	}
+}
+
+// ClientKey 客户端标识：优先用户，其次API密钥，最后客户端IP
+func ClientKey(c *gin.Context) string {
+	if key := CurrentAPIKey(c); key != nil && key.ID != "anonymous" {
+		if key.UserID != "" {
+			return "user:" + key.UserID
+		}
+		return "key:" + key.ID
+	}
+	return "ip:" + c.ClientIP()
+}
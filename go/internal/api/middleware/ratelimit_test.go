@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewRateLimiterDisabled(t *testing.T) {
+	if l := NewRateLimiter(0, 10); l != nil {
+		t.Error("ratePerMinute=0 应返回nil")
+	}
+	if l := NewRateLimiter(-1, 10); l != nil {
+		t.Error("ratePerMinute<0 应返回nil")
+	}
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	l := NewRateLimiter(60, 3)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("第%d次请求应在突发容量内", i+1)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok {
+		t.Fatal("超出突发容量后应被限流")
+	}
+	// 每秒补充1个令牌，等待时长不超过1秒
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("等待时长 = %v, want (0, 1s]", wait)
+	}
+	// 不同客户端独立计数
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("其他客户端不应受影响")
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l := NewRateLimiter(60, 2)
+	l.Allow("a")
+	l.Allow("a")
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("令牌耗尽后应被限流")
+	}
+
+	// 模拟经过1.5秒：补充1.5个令牌
+	l.buckets["a"].lastSeen = l.buckets["a"].lastSeen.Add(-1500 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("补充令牌后应允许请求")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("剩余0.5个令牌时应被限流")
+	}
+
+	// 长时间未访问时补充的令牌不超过容量
+	l.buckets["a"].lastSeen = l.buckets["a"].lastSeen.Add(-time.Hour)
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if ok, _ := l.Allow("a"); ok {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("令牌补满后允许%d次, want 2", allowed)
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	l := NewRateLimiter(60, 1)
+	l.Allow("idle")
+	l.Allow("active")
+	l.buckets["idle"].lastSeen = time.Now().Add(-2 * bucketIdleTTL)
+	l.lastSweep = time.Now().Add(-2 * bucketIdleTTL)
+
+	l.Allow("active")
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("长时间未访问的令牌桶应被清理")
+	}
+	if _, ok := l.buckets["active"]; !ok {
+		t.Error("活跃的令牌桶不应被清理")
+	}
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", RateLimitByIP(NewRateLimiter(60, 1)), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := do(); w.Code != http.StatusOK {
+		t.Fatalf("首次请求状态码 = %d, want 200", w.Code)
+	}
+	w := do()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("超限请求状态码 = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+}
+
+func TestRateLimitNil(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", RateLimit(nil), func(c *gin.Context) { c.Status(http.StatusOK) })
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("未启用限流时状态码 = %d, want 200", w.Code)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reloadable 可在运行时原子替换的中间件（用于配置热更新）
+type Reloadable struct {
+	handler atomic.Pointer[gin.HandlerFunc]
+}
+
+// NewReloadable 创建可替换中间件
+func NewReloadable(h gin.HandlerFunc) *Reloadable {
+	r := &Reloadable{}
+	r.Store(h)
+	return r
+}
+
+// Store 替换中间件（进行中的请求不受影响）
+func (r *Reloadable) Store(h gin.HandlerFunc) {
+	r.handler.Store(&h)
+}
+
+// Handle 调用当前中间件
+func (r *Reloadable) Handle(c *gin.Context) {
+	(*r.handler.Load())(c)
+}
@@ -2,44 +2,131 @@ package api
 
 import (
 	"Voice_Assistant/internal/api/handler"
+	"Voice_Assistant/internal/api/middleware"
+	"Voice_Assistant/internal/metrics"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/tracing"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(assistantHandler *handler.AssistantHandler, historyHandler *handler.HistoryHandler) http.Handler {
-	r := gin.Default()
+// Middlewares 由配置决定的中间件（及可选的前端资源处理器）
+type Middlewares struct {
+	Auth           gin.HandlerFunc                    // 鉴权（未启用时为匿名管理员）
+	RateLimit      func(group string) gin.HandlerFunc // 按路由分组限流
+	IPLimit        gin.HandlerFunc                    // 鉴权前按客户端IP限流
+	StreamLimit    gin.HandlerFunc                    // 流式对话并发限制
+	MetricsPath    string                             // Prometheus指标路径（为空时不暴露）
+	TrustedProxies []string                           // 可信的反向代理（为空时不采信X-Forwarded-For，客户端IP即连接地址）
+	CORS           gin.HandlerFunc                    // 跨域
+	Frontend       gin.HandlerFunc                    // 内嵌前端（为空时不提供）
+}
+
+func SetupRouter(assistantHandler *handler.AssistantHandler, historyHandler *handler.HistoryHandler, apiKeyHandler *handler.APIKeyHandler, userHandler *handler.UserHandler, quotaHandler *handler.QuotaHandler, archiveHandler *handler.ArchiveHandler, knowledgeHandler *handler.KnowledgeHandler, memoryHandler *handler.MemoryHandler, healthHandler *handler.HealthHandler, mw Middlewares) http.Handler {
+	// 访问日志由RequestID中间件以结构化日志输出，不使用gin默认Logger
+	r := gin.New()
+	// gin默认信任所有代理，客户端可伪造X-Forwarded-For绕过按IP限流
+	if err := r.SetTrustedProxies(mw.TrustedProxies); err != nil {
+		slog.Error("可信代理配置不合法，不信任任何代理", "error", err)
+		r.SetTrustedProxies(nil)
+	}
+	r.Use(gin.Recovery(), tracing.Middleware(), middleware.RequestID())
+	if mw.MetricsPath != "" {
+		r.Use(metrics.HTTPMiddleware())
+		r.GET(mw.MetricsPath, metrics.Handler())
+	}
+
+	// 健康检查（无需鉴权，供负载均衡/编排系统探测）
+	r.GET("/healthz", healthHandler.Live)
+	r.GET("/readyz", healthHandler.Ready)
+
+	// CORS 中间件（来源、方法、请求头由配置决定）
+	r.Use(mw.CORS)
 
-	// CORS 中间件（适配SSE）
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Type, X-Accel-Buffering")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+	authMiddleware := mw.Auth
+	// 鉴权前先按IP限流，无效凭证的请求同样受限
+	ipLimit := mw.IPLimit
+	read := middleware.RequireScope(model.ScopeRead)
+	chat := middleware.RequireScope(model.ScopeChat)
+	admin := middleware.RequireScope(model.ScopeAdmin)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
+	apiV1a := r.Group("/api/voice-robot/v1/assistant", ipLimit, authMiddleware, mw.RateLimit("assistant"))
+	{
+		apiV1a.GET("", read, assistantHandler.SelectAll)
+		apiV1a.DELETE("/:id", admin, middleware.RequireAssistant("id"), assistantHandler.DeleteByID)
+		apiV1a.POST("", admin, assistantHandler.Save)
+		apiV1a.PATCH("/:id", admin, middleware.RequireAssistant("id"), assistantHandler.UpdateByID)
+		apiV1a.GET("/:id/revisions", read, middleware.RequireAssistant("id"), assistantHandler.ListRevisions)
+		apiV1a.GET("/:id/revisions/diff", read, middleware.RequireAssistant("id"), assistantHandler.DiffRevisions)
+		apiV1a.POST("/:id/revisions/:revision/rollback", admin, middleware.RequireAssistant("id"), assistantHandler.Rollback)
+		// 知识库（未配置向量接口时不提供）
+		if knowledgeHandler != nil {
+			apiV1a.GET("/:id/knowledge", read, middleware.RequireAssistant("id"), knowledgeHandler.ListDocuments)
+			apiV1a.GET("/:id/knowledge/search", read, middleware.RequireAssistant("id"), knowledgeHandler.Search)
+			apiV1a.POST("/:id/knowledge", admin, middleware.RequireAssistant("id"), knowledgeHandler.Upload)
+			apiV1a.DELETE("/:id/knowledge/:document_id", admin, middleware.RequireAssistant("id"), knowledgeHandler.DeleteDocument)
 		}
+	}
 
-		c.Next()
-	})
+	apiV1h := r.Group("/api/voice-robot/v1/history", ipLimit, authMiddleware, mw.RateLimit("history"), middleware.RequireAssistant("assistant_id"))
+	{
+		apiV1h.GET("/:assistant_id", read, historyHandler.SelectByAssistantID)
+		apiV1h.GET("/:assistant_id/export", read, historyHandler.Export)
+		apiV1h.GET("/:assistant_id/vars", read, historyHandler.SelectVars)
+		apiV1h.PUT("/:assistant_id/vars", chat, historyHandler.SaveVars)
+		apiV1h.DELETE("/:assistant_id", chat, historyHandler.ResetByAssistantID)
+		apiV1h.POST("/:assistant_id", chat, historyHandler.SaveByAssistantID)
+		apiV1h.POST("/:assistant_id/stream-process", chat, mw.StreamLimit, historyHandler.StreamProcessMessage)
+		apiV1h.POST("/:assistant_id/process", chat, historyHandler.ProcessMessage)
+	}
 
-	apiV1a := r.Group("/api/voice-robot/v1/assistant")
+	apiV1k := r.Group("/api/voice-robot/v1/api-key", ipLimit, authMiddleware, mw.RateLimit("api_key"), admin, middleware.RequireUnrestricted())
 	{
-		apiV1a.GET("", assistantHandler.SelectAll)
-		apiV1a.DELETE("/:id", assistantHandler.DeleteByID)
-		apiV1a.POST("", assistantHandler.Save)
-		apiV1a.PATCH("/:id", assistantHandler.UpdateByID)
+		apiV1k.GET("", apiKeyHandler.SelectAll)
+		apiV1k.POST("", apiKeyHandler.Save)
+		apiV1k.DELETE("/:id", apiKeyHandler.DeleteByID)
 	}
 
-	apiV1h := r.Group("/api/voice-robot/v1/history")
+	apiV1q := r.Group("/api/voice-robot/v1/usage", ipLimit, authMiddleware, mw.RateLimit("usage"))
 	{
-		apiV1h.GET("/:assistant_id", historyHandler.SelectByAssistantID)
-		apiV1h.DELETE("/:assistant_id", historyHandler.ResetByAssistantID)
-		apiV1h.POST("/:assistant_id", historyHandler.SaveByAssistantID)
-		apiV1h.POST("/:assistant_id/stream-process", historyHandler.StreamProcessMessage)
+		apiV1q.GET("", read, quotaHandler.Report)
+	}
+
+	// 长期记忆（按用户隔离，未启用时不提供；记忆可能对所有助手生效，限定助手范围的密钥不可管理）
+	if memoryHandler != nil {
+		apiV1m := r.Group("/api/voice-robot/v1/memory", ipLimit, authMiddleware, mw.RateLimit("memory"), middleware.RequireUnrestricted())
+		{
+			apiV1m.GET("", read, memoryHandler.List)
+			apiV1m.POST("", chat, memoryHandler.Create)
+			apiV1m.PUT("/:id", chat, memoryHandler.Update)
+			apiV1m.DELETE("/:id", chat, memoryHandler.Delete)
+			apiV1m.DELETE("", chat, memoryHandler.Clear)
+		}
+	}
+
+	// 归档导出包含所有用户的会话，仅限管理员使用未限定助手范围的密钥
+	apiV1r := r.Group("/api/voice-robot/v1/archive", ipLimit, authMiddleware, mw.RateLimit("archive"), admin, middleware.RequireAdminUser(), middleware.RequireUnrestricted())
+	{
+		apiV1r.GET("", archiveHandler.Export)
+		apiV1r.GET("/:id", archiveHandler.ExportOne)
+		apiV1r.POST("/import", archiveHandler.Import)
+	}
+
+	apiV1u := r.Group("/api/voice-robot/v1/user", ipLimit, mw.RateLimit("user"))
+	{
+		apiV1u.POST("/register", userHandler.Register)
+		apiV1u.POST("/login", userHandler.Login)
+		apiV1u.POST("/logout", authMiddleware, userHandler.Logout)
+		apiV1u.GET("/me", authMiddleware, userHandler.Me)
+		apiV1u.GET("", authMiddleware, middleware.RequireAdminUser(), userHandler.SelectAll)
+		apiV1u.POST("", authMiddleware, admin, middleware.RequireAdminUser(), userHandler.Register)
+	}
+
+	// 未匹配的路径交给内嵌前端（SPA回退）
+	if mw.Frontend != nil {
+		r.NoRoute(mw.Frontend)
 	}
 
 	return r
@@ -0,0 +1,36 @@
+package auth
+
+import "context"
+
+// Principal 当前请求的身份（用于仓库层按用户过滤数据）
+type Principal struct {
+	UserID string // 用户ID（系统身份为空）
+	Admin  bool   // 是否管理员（管理员可访问全部助手）
+}
+
+type principalKey struct{}
+
+// WithPrincipal 将身份写入上下文
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext 从上下文读取身份
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// UserID 当前用户ID（无身份或系统身份时为空字符串）
+func UserID(ctx context.Context) string {
+	if p, ok := FromContext(ctx); ok {
+		return p.UserID
+	}
+	return ""
+}
+
+// IsAdmin 是否不受用户过滤（无身份的内部调用视为系统管理员）
+func IsAdmin(ctx context.Context) bool {
+	p, ok := FromContext(ctx)
+	return !ok || p.Admin
+}
@@ -3,22 +3,48 @@ package config
 import (
 	"Voice_Assistant/internal/api"
 	"Voice_Assistant/internal/api/handler"
+	"Voice_Assistant/internal/api/middleware"
 	"Voice_Assistant/internal/data/sqlite"
+	"Voice_Assistant/internal/logging"
+	"Voice_Assistant/internal/model"
 	"Voice_Assistant/internal/repository"
 	"Voice_Assistant/internal/service"
+	"Voice_Assistant/internal/tracing"
+	"Voice_Assistant/internal/web"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"gopkg.in/yaml.v3"
 )
 
 // Config 应用配置结构
 type Config struct {
 	Server struct {
-		Port string `yaml:"port"`
+		Port               string `yaml:"port"`
+		ShutdownTimeoutSec int    `yaml:"shutdown_timeout_sec"` // 优雅退出等待时长
+		// 超时设置（0表示不限制）；写超时对SSE流式接口不生效
+		ReadHeaderTimeoutSec int  `yaml:"read_header_timeout_sec"`
+		ReadTimeoutSec       int  `yaml:"read_timeout_sec"`
+		WriteTimeoutSec      int  `yaml:"write_timeout_sec"`
+		IdleTimeoutSec       int  `yaml:"idle_timeout_sec"`
+		HTTP2                bool `yaml:"http2"` // 是否启用HTTP/2（仅TLS下生效）
+		// 可信的反向代理地址或网段：只采信其转发的X-Forwarded-For/X-Real-IP，为空时以连接地址作为客户端IP
+		TrustedProxies []string `yaml:"trusted_proxies"`
+		TLS            struct {
+			CertFile string `yaml:"cert_file"`
+			KeyFile  string `yaml:"key_file"`
+		} `yaml:"tls"`
 	} `yaml:"server"`
+	CORS middleware.CORSConfig `yaml:"cors"`
 	Data struct {
 		DBPath string `yaml:"db_path"`
 	} `yaml:"data"`
@@ -32,62 +58,225 @@ type Config struct {
 	BOCHA struct {
 		APIKey string `yaml:"api_key"`
 	} `yaml:"bocha"`
+	Auth struct {
+		Enabled         bool   `yaml:"enabled"`
+		AdminKey        string `yaml:"admin_key"`
+		AllowRegister   bool   `yaml:"allow_register"`
+		SessionTTLHours int    `yaml:"session_ttl_hours"`
+	} `yaml:"auth"`
+	Quota     model.QuotaPolicy           `yaml:"quota"`
+	Pricing   map[string]model.ModelPrice `yaml:"pricing"`
+	RateLimit struct {
+		Enabled              bool                     `yaml:"enabled"`
+		PerIP                RateLimitRule            `yaml:"per_ip"` // 鉴权前按客户端IP限流（所有接口共用）
+		Groups               map[string]RateLimitRule `yaml:"groups"`
+		MaxConcurrentStreams int                      `yaml:"max_concurrent_streams"`
+	} `yaml:"rate_limit"`
+	Metrics struct {
+		Enabled bool   `yaml:"enabled"`
+		Path    string `yaml:"path"`
+	} `yaml:"metrics"`
+	Tracing tracing.Config `yaml:"tracing"`
+	Log     logging.Config `yaml:"log"`
+	History struct {
+		ConcurrentTurns string `yaml:"concurrent_turns"` // 同一会话并发轮次策略：queue/reject/allow
+		Timezone        string `yaml:"timezone"`         // 提示词模板中日期时间使用的时区（默认Asia/Shanghai）
+	} `yaml:"history"`
+	Web struct {
+		Enabled bool `yaml:"enabled"` // 是否提供内嵌的前端页面
+	} `yaml:"web"`
+	Reload struct {
+		Watch       bool `yaml:"watch"`        // 是否监听配置文件变化（SIGHUP始终可触发重新加载）
+		IntervalSec int  `yaml:"interval_sec"` // 检查间隔
+	} `yaml:"reload"`
+	Health struct {
+		CheckLLM bool `yaml:"check_llm"` // 就绪检查是否包含LLM可达性
+	} `yaml:"health"`
+	Prompt struct {
+		Preamble  *string `yaml:"preamble"`   // 助手提示词之前的全局说明（省略时使用内置默认值，空字符串为不使用）
+		ToolGuide *string `yaml:"tool_guide"` // 启用工具时附加在助手提示词之后的工具使用说明（同上）
+	} `yaml:"prompt"`
+	Assistants struct {
+		Dir   string `yaml:"dir"`   // YAML助手定义目录（为空时不同步）
+		Prune bool   `yaml:"prune"` // 文件删除后是否同时删除助手（否则仅解除只读）
+	} `yaml:"assistants"`
+	Embedding service.EmbeddingConfig `yaml:"embedding"` // 文本向量（未启用时不提供知识库，长期记忆不按相关度选取）
+	Knowledge service.KnowledgeConfig `yaml:"knowledge"`
+	Memory    service.MemoryConfig    `yaml:"memory"`
+	Archive   service.ArchiveConfig   `yaml:"archive"`
 }
 
-// LoadConfig 加载配置文件
-func LoadConfig() (*Config, error) {
-	configPath := filepath.Join("internal", "config", "application.yaml")
+// RateLimitRule 路由分组限流规则
+type RateLimitRule struct {
+	RatePerMinute int `yaml:"rate_per_minute"`
+	Burst         int `yaml:"burst"`
+}
+
+// Options 配置加载选项（来自命令行）
+type Options struct {
+	Path string   // --config 指定的配置文件
+	Sets []string // --set key=value 覆盖项
+}
+
+// LoadConfig 加载配置（优先级：--set > VA_前缀环境变量 > 配置文件），并校验所有字段
+func LoadConfig(opts Options) (*Config, error) {
+	configPath, err := resolveConfigPath(opts.Path)
+	if err != nil {
+		return nil, err
+	}
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("读取配置文件失败: %w", err)
 	}
 
+	// 解析后替换占位符（如${DASHSCOPE_API_KEY}、${PORT:-8080}）
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %w", configPath, err)
+	}
+	interpolate(&doc)
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	if err := doc.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %w", configPath, err)
+	}
+	resolvePaths(&cfg, configPath)
+	// 覆盖失败与校验失败一并返回，便于一次改完
+	if err := errors.Join(applyOverrides(&cfg, opts.Sets), cfg.Validate()); err != nil {
+		return nil, err
 	}
-
-	// 替换环境变量占位符（如${DASHSCOPE_API_KEY}）
-	cfg.LLM.APIKey = replaceEnvVar(cfg.LLM.APIKey)
-	cfg.BOCHA.APIKey = replaceEnvVar(cfg.BOCHA.APIKey)
 	return &cfg, nil
 }
 
-// 替换环境变量占位符
-func replaceEnvVar(value string) string {
-	if len(value) > 2 && value[0] == '$' && value[1] == '{' {
-		envKey := value[2 : len(value)-1]
-		if envVal := os.Getenv(envKey); envVal != "" {
-			return envVal
-		}
+func SetupApp(opts Options) (*App, error) {
+	// 1. 加载配置
+	cfg, err := LoadConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("加载配置失败: %w", err)
 	}
-	return value
-}
 
-func SetupApp() (http.Handler, *Config, error) {
-	// 1. 加载配置
-	cfg, err := LoadConfig()
+	// 2. 初始化日志与链路追踪
+	if err := logging.Setup(cfg.Log); err != nil {
+		return nil, fmt.Errorf("初始化日志失败: %w", err)
+	}
+	if err := tracing.Setup(cfg.Tracing); err != nil {
+		return nil, fmt.Errorf("初始化链路追踪失败: %w", err)
+	}
+
+	// 3. 初始化数据库与业务服务
+	svc, err := NewServices(cfg)
 	if err != nil {
-		return nil, nil, fmt.Errorf("加载配置失败: %w", err)
+		return nil, err
+	}
+	if err := syncAssistants(svc); err != nil {
+		svc.Close(context.Background())
+		return nil, err
+	}
+	healthService := service.NewHealthService(svc.DB.PingContext, svc.LLM, cfg.Health.CheckLLM)
+
+	// 4. 初始化API处理器（添加语音处理器）
+	assistantHandler := handler.NewAssistantHandler(svc.Assistant)
+	historyHandler := handler.NewHistoryHandler(svc.History)
+	apiKeyHandler := handler.NewAPIKeyHandler(svc.APIKey)
+	userHandler := handler.NewUserHandler(svc.User)
+	quotaHandler := handler.NewQuotaHandler(svc.Quota, svc.Assistant)
+	archiveHandler := handler.NewArchiveHandler(svc.Archive)
+	var knowledgeHandler *handler.KnowledgeHandler
+	if svc.Knowledge != nil {
+		knowledgeHandler = handler.NewKnowledgeHandler(svc.Knowledge)
+	}
+	var memoryHandler *handler.MemoryHandler
+	if svc.Memory != nil {
+		memoryHandler = handler.NewMemoryHandler(svc.Memory)
 	}
+	healthHandler := handler.NewHealthHandler(healthService)
 
-	// 2. 创建数据库目录
+	// 5. 初始化鉴权中间件（未启用时所有请求视为管理员）
+	authMiddleware := middleware.Anonymous()
+	if cfg.Auth.Enabled {
+		authMiddleware = middleware.Auth(svc.APIKey, svc.User)
+	}
+
+	// 6. 初始化限流、跨域与指标中间件（限流与跨域支持热更新，未配置的分组不限流）
+	app := &App{
+		Config:      cfg,
+		opts:        opts,
+		services:    svc,
+		current:     cfg,
+		cors:        middleware.NewReloadable(middleware.CORS(cfg.CORS)),
+		streamLimit: middleware.NewConcurrencyLimiter(maxConcurrentStreams(cfg)),
+		ipLimit:     middleware.NewReloadable(ipLimitHandler(cfg)),
+		rateLimits:  make(map[string]*middleware.Reloadable),
+	}
+	mw := api.Middlewares{
+		Auth: authMiddleware,
+		RateLimit: func(group string) gin.HandlerFunc {
+			r := middleware.NewReloadable(rateLimitHandler(cfg, group))
+			app.rateLimits[group] = r
+			return r.Handle
+		},
+		IPLimit:        app.ipLimit.Handle,
+		StreamLimit:    middleware.LimitConcurrent(app.streamLimit),
+		CORS:           app.cors.Handle,
+		TrustedProxies: cfg.Server.TrustedProxies,
+	}
+	if cfg.Web.Enabled {
+		if mw.Frontend = web.Handler(); mw.Frontend == nil {
+			slog.Warn("前端未构建（在vue目录执行 npm run build 后重新编译），不提供页面")
+		}
+	}
+	if cfg.Metrics.Enabled {
+		mw.MetricsPath = cfg.Metrics.Path
+		if mw.MetricsPath == "" {
+			mw.MetricsPath = "/metrics"
+		}
+	}
+
+	// 7. 初始化路由
+	app.Router = api.SetupRouter(assistantHandler, historyHandler, apiKeyHandler, userHandler, quotaHandler, archiveHandler, knowledgeHandler, memoryHandler, healthHandler, mw)
+	return app, nil
+}
+
+// Services 数据库连接与业务服务（HTTP服务与命令行工具共用）
+type Services struct {
+	DB          *sql.DB
+	LLM         service.LLMService
+	Quota       service.QuotaService
+	History     service.HistoryService
+	Assistant   service.AssistantService
+	APIKey      service.APIKeyService
+	User        service.UserService
+	Archive     service.ArchiveService
+	Maintenance repository.MaintenanceRepo
+	// 未配置assistants.dir时为nil
+	AssistantSync service.AssistantSyncService
+	// 未启用文本向量时为nil
+	Knowledge service.KnowledgeService
+	// 未启用长期记忆时为nil
+	Memory service.MemoryService
+}
+
+// NewServices 打开数据库（自动建表与迁移）并初始化所有业务服务
+func NewServices(cfg *Config) (*Services, error) {
+	// 1. 创建数据库目录
 	dbDir := filepath.Dir(cfg.Data.DBPath)
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		return nil, nil, fmt.Errorf("创建数据库目录失败: %w", err)
+		return nil, fmt.Errorf("创建数据库目录失败: %w", err)
 	}
 
-	// 3. 初始化SQLite数据库
+	// 2. 初始化SQLite数据库
 	db, err := sqlite.InitDB(cfg.Data.DBPath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("初始化数据库失败: %w", err)
+		return nil, fmt.Errorf("初始化数据库失败: %w", err)
 	}
 
-	// 4. 初始化数据仓库
+	// 3. 初始化数据仓库
 	assistantRepo := repository.NewAssistantRepo(db)
 	historyRepo := repository.NewHistoryRepo(db)
+	apiKeyRepo := repository.NewAPIKeyRepo(db)
+	userRepo := repository.NewUserRepo(db)
+	usageRepo := repository.NewUsageRepo(db)
 
-	// 5. 初始化大模型服务
+	// 4. 初始化大模型服务
 	llmService := service.NewLLMService(
 		cfg.LLM.APIKey,
 		cfg.LLM.BaseURL,
@@ -97,15 +286,182 @@ func SetupApp() (http.Handler, *Config, error) {
 		cfg.BOCHA.APIKey,
 	)
 
-	// 6. 初始化业务服务
-	historyService := service.NewHistoryService(historyRepo, assistantRepo, llmService)
-	assistantService := service.NewAssistantService(assistantRepo, historyService)
+	// 5. 初始化业务服务
+	quotaService := service.NewQuotaService(usageRepo, cfg.Quota, cfg.Pricing)
+	location, err := historyLocation(cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	// 知识库检索与remember作为工具注册到LLM服务（需在同步助手定义前完成，以便校验工具名称）
+	embedder := service.NewEmbedder(cfg.Embedding)
+	var knowledgeService service.KnowledgeService
+	if embedder != nil {
+		knowledgeService = service.NewKnowledgeService(repository.NewKnowledgeRepo(db), assistantRepo, embedder, cfg.Knowledge)
+		llmService.RegisterTool(knowledgeService)
+	}
+	var memoryService service.MemoryService
+	if cfg.Memory.Enabled {
+		memoryService = service.NewMemoryService(repository.NewMemoryRepo(db), assistantRepo, llmService, quotaService, embedder, cfg.Memory)
+		llmService.RegisterTool(memoryService)
+	}
+	historyService := service.NewHistoryService(historyRepo, assistantRepo, userRepo, llmService, quotaService, memoryService, cfg.History.ConcurrentTurns, location, systemPrompts(cfg))
+	var assistantSync service.AssistantSyncService
+	if cfg.Assistants.Dir != "" {
+		assistantSync = service.NewAssistantSyncService(cfg.Assistants.Dir, cfg.Assistants.Prune, assistantRepo, historyService, llmService)
+	}
+	return &Services{
+		DB:            db,
+		LLM:           llmService,
+		Quota:         quotaService,
+		History:       historyService,
+		Assistant:     service.NewAssistantService(assistantRepo, historyService),
+		APIKey:        service.NewAPIKeyService(apiKeyRepo, assistantRepo, cfg.Auth.AdminKey),
+		User:          service.NewUserService(userRepo, cfg.Auth.AllowRegister, cfg.Auth.SessionTTLHours),
+		Archive:       service.NewArchiveService(assistantRepo, repository.NewArchiveRepo(db), cfg.Archive),
+		Maintenance:   repository.NewMaintenanceRepo(db),
+		AssistantSync: assistantSync,
+		Knowledge:     knowledgeService,
+		Memory:        memoryService,
+	}, nil
+}
 
-	// 7. 初始化API处理器（添加语音处理器）
-	assistantHandler := handler.NewAssistantHandler(assistantService)
-	historyHandler := handler.NewHistoryHandler(historyService)
+// Close 等待进行中的历史写入完成后关闭数据库
+func (s *Services) Close(ctx context.Context) error {
+	var errs []error
+	if err := s.History.Wait(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("等待历史写入超时: %w", err))
+	}
+	if err := s.DB.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("关闭数据库失败: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// historyLocation 提示词模板使用的时区
+func historyLocation(cfg *Config) (*time.Location, error) {
+	name := cfg.History.Timezone
+	if name == "" {
+		name = "Asia/Shanghai"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("加载时区%s失败: %w", name, err)
+	}
+	return loc, nil
+}
+
+// systemPrompts 全局系统提示（未配置时使用内置默认值）
+func systemPrompts(cfg *Config) service.SystemPrompts {
+	prompts := service.SystemPrompts{Preamble: service.DefaultPreamble, ToolGuide: service.DefaultToolGuide}
+	if cfg.Prompt.Preamble != nil {
+		prompts.Preamble = *cfg.Prompt.Preamble
+	}
+	if cfg.Prompt.ToolGuide != nil {
+		prompts.ToolGuide = *cfg.Prompt.ToolGuide
+	}
+	return prompts
+}
+
+// syncAssistants 启动时将YAML助手定义同步到数据库（定义不合法时拒绝启动）
+func syncAssistants(svc *Services) error {
+	if svc.AssistantSync == nil {
+		return nil
+	}
+	plan, err := svc.AssistantSync.Sync(context.Background(), false)
+	if err != nil {
+		return fmt.Errorf("同步助手定义失败: %w", err)
+	}
+	slog.Info("助手定义已同步", "dir", plan.Dir,
+		"created", plan.Count(model.SyncCreate),
+		"updated", plan.Count(model.SyncUpdate),
+		"unchanged", plan.Count(model.SyncUnchanged),
+		"released", plan.Count(model.SyncRelease),
+		"deleted", plan.Count(model.SyncDelete),
+	)
+	for _, c := range plan.Changes {
+		if c.Action == model.SyncConflict {
+			slog.Warn("助手定义与通过API创建的助手ID相同，未接管（确认接管请在文件中设置adopt: true）", "source", c.Source, "id", c.ID)
+		}
+	}
+	return nil
+}
+
+// rateLimitHandler 按配置创建分组限流中间件
+func rateLimitHandler(cfg *Config, group string) gin.HandlerFunc {
+	if !cfg.RateLimit.Enabled {
+		return middleware.RateLimit(nil)
+	}
+	rule := cfg.RateLimit.Groups[group]
+	return middleware.RateLimit(middleware.NewRateLimiter(rule.RatePerMinute, rule.Burst))
+}
+
+// ipLimitHandler 按配置创建鉴权前的IP限流中间件
+func ipLimitHandler(cfg *Config) gin.HandlerFunc {
+	if !cfg.RateLimit.Enabled {
+		return middleware.RateLimitByIP(nil)
+	}
+	return middleware.RateLimitByIP(middleware.NewRateLimiter(cfg.RateLimit.PerIP.RatePerMinute, cfg.RateLimit.PerIP.Burst))
+}
+
+// maxConcurrentStreams 每个客户端的流式对话并发上限（0为不限制）
+func maxConcurrentStreams(cfg *Config) int {
+	if !cfg.RateLimit.Enabled {
+		return 0
+	}
+	return cfg.RateLimit.MaxConcurrentStreams
+}
+
+// App 初始化完成的应用（路由及退出时需要释放的资源）
+type App struct {
+	Router   http.Handler
+	Config   *Config // 启动时的配置（热更新不修改）
+	opts     Options
+	services *Services
+
+	// 热更新相关
+	mu          sync.Mutex
+	current     *Config // 当前生效的配置
+	cors        *middleware.Reloadable
+	streamLimit *middleware.ConcurrencyLimiter // 热更新只修改上限，进行中的流仍在同一计数器中释放
+	ipLimit     *middleware.Reloadable
+	rateLimits  map[string]*middleware.Reloadable // 按路由分组
+}
+
+// NewServer 按配置创建HTTP服务（超时与协议）
+func (a *App) NewServer() *http.Server {
+	s := a.Config.Server
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(s.HTTP2)
+	return &http.Server{
+		Addr:              s.Port,
+		Handler:           a.Router,
+		ReadHeaderTimeout: seconds(s.ReadHeaderTimeoutSec),
+		ReadTimeout:       seconds(s.ReadTimeoutSec),
+		WriteTimeout:      seconds(s.WriteTimeoutSec),
+		IdleTimeout:       seconds(s.IdleTimeoutSec),
+		Protocols:         protocols,
+	}
+}
+
+// TLSEnabled 是否配置了TLS证书
+func (a *App) TLSEnabled() bool {
+	return a.Config.Server.TLS.CertFile != "" && a.Config.Server.TLS.KeyFile != ""
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
 
-	// 8. 初始化路由
-	router := api.SetupRouter(assistantHandler, historyHandler)
-	return router, cfg, nil
+// Close 等待进行中的历史写入完成后关闭数据库，并刷新未导出的追踪数据
+func (a *App) Close(ctx context.Context) error {
+	var errs []error
+	if err := a.services.Close(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := tracing.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("关闭链路追踪失败: %w", err))
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 环境变量覆盖前缀：VA_ + yaml字段路径（层级用下划线连接，全大写），如 VA_SERVER_PORT、VA_LLM_API_KEY
+const envPrefix = "VA_"
+
+// 指定配置文件路径的环境变量（优先级低于--config）
+const configPathEnv = "VA_CONFIG"
+
+// ${VAR} 或 ${VAR:-default}
+var placeholderPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// resolveConfigPath 确定配置文件：--config > VA_CONFIG > 按顺序查找
+func resolveConfigPath(explicit string) (string, error) {
+	if explicit == "" {
+		explicit = os.Getenv(configPathEnv)
+	}
+	if explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return "", fmt.Errorf("配置文件不存在: %s", explicit)
+		}
+		return explicit, nil
+	}
+
+	candidates := searchPaths()
+	for _, p := range candidates {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("未找到配置文件，已查找: %s", strings.Join(candidates, ", "))
+}
+
+// searchPaths 未指定配置文件时的查找顺序（当前目录优先，其次可执行文件所在目录，最后系统目录）
+func searchPaths() []string {
+	rel := []string{
+		"application.yaml",
+		filepath.Join("config", "application.yaml"),
+		filepath.Join("internal", "config", "application.yaml"),
+	}
+	paths := append([]string{}, rel...)
+	if exe, err := os.Executable(); err == nil {
+		dir := filepath.Dir(exe)
+		for _, p := range rel {
+			paths = append(paths, filepath.Join(dir, p))
+		}
+	}
+	return append(paths, filepath.Join("/etc", "voice-assistant", "application.yaml"))
+}
+
+// interpolate 替换配置值中的占位符（变量未设置或为空时使用默认值，无默认值时替换为空）。
+// 在解析后的节点上替换，变量值中的引号、冒号、#或换行不会破坏文档结构
+func interpolate(n *yaml.Node) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if !placeholderPattern.MatchString(n.Value) {
+			return
+		}
+		n.Value = placeholderPattern.ReplaceAllStringFunc(n.Value, func(m string) string {
+			sub := placeholderPattern.FindStringSubmatch(m)
+			if v := os.Getenv(sub[1]); v != "" {
+				return v
+			}
+			return sub[2]
+		})
+		// 未加引号的值按替换后的内容重新推断类型（如 port: ${PORT:-8080}）
+		if n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			n.Tag = ""
+		}
+	case yaml.MappingNode:
+		// 只替换值，不替换键
+		for i := 1; i < len(n.Content); i += 2 {
+			interpolate(n.Content[i])
+		}
+	default:
+		for _, c := range n.Content {
+			interpolate(c)
+		}
+	}
+}
+
+// resolvePaths 配置文件中的相对路径按配置文件所在目录解析，与启动时的工作目录无关
+// （在应用环境变量与--set覆盖之前调用，覆盖值中的相对路径仍按工作目录解析）
+func resolvePaths(cfg *Config, configPath string) {
+	dir := filepath.Dir(configPath)
+	for _, p := range []*string{&cfg.Data.DBPath, &cfg.Server.TLS.CertFile, &cfg.Server.TLS.KeyFile, &cfg.Assistants.Dir} {
+		// SQLite的内存库与URI形式的路径原样保留
+		if *p == "" || filepath.IsAbs(*p) || *p == ":memory:" || strings.HasPrefix(*p, "file:") {
+			continue
+		}
+		*p = filepath.Join(dir, *p)
+	}
+}
+
+// configField 可覆盖的配置字段
+type configField struct {
+	path  string // yaml路径，如 server.port
+	value reflect.Value
+}
+
+// collectFields 递归收集所有叶子字段（映射、列表作为整体覆盖）
+func collectFields(v reflect.Value, prefix string, out *[]configField) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			collectFields(fv, path, out)
+			continue
+		}
+		*out = append(*out, configField{path: path, value: fv})
+	}
+}
+
+// envName 字段对应的环境变量名
+func envName(path string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// setField 按字段类型写入覆盖值（字符串及可选字符串原样写入，字符串列表支持逗号分隔，其余按YAML解析）
+func setField(v reflect.Value, raw string) error {
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+		return nil
+	case v.Kind() == reflect.Pointer && v.Type().Elem().Kind() == reflect.String:
+		// 可选字符串：空值表示显式设为空，而不是未设置
+		ptr := reflect.New(v.Type().Elem())
+		ptr.Elem().SetString(raw)
+		v.Set(ptr)
+		return nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(raw), "["):
+		var items []string
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+		return nil
+	}
+
+	ptr := reflect.New(v.Type())
+	if yaml.Unmarshal([]byte(raw), ptr.Interface()) != nil {
+		return fmt.Errorf("值 %q 无法解析为 %s", raw, v.Type())
+	}
+	v.Set(ptr.Elem())
+	return nil
+}
+
+// applyOverrides 依次应用环境变量与--set覆盖（后者优先），返回所有失败项
+func applyOverrides(cfg *Config, sets []string) error {
+	var fields []configField
+	collectFields(reflect.ValueOf(cfg).Elem(), "", &fields)
+
+	var errs []error
+	byPath := make(map[string]configField, len(fields))
+	for _, f := range fields {
+		byPath[f.path] = f
+		name := envName(f.path)
+		if raw, ok := os.LookupEnv(name); ok {
+			if err := setField(f.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("环境变量 %s: %w", name, err))
+			}
+		}
+	}
+
+	for _, s := range sets {
+		key, raw, ok := strings.Cut(s, "=")
+		if !ok {
+			errs = append(errs, fmt.Errorf("--set %s: 格式应为 key=value", s))
+			continue
+		}
+		f, ok := byPath[strings.TrimSpace(key)]
+		if !ok {
+			errs = append(errs, fmt.Errorf("--set %s: 未知的配置项", key))
+			continue
+		}
+		if err := setField(f.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("--set %s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("VA_TEST_KEY", `sk-"a": #b`)
+	t.Setenv("VA_TEST_PORT", "9090")
+	t.Setenv("VA_TEST_EMPTY", "")
+
+	src := `
+llm:
+  api_key: ${VA_TEST_KEY}
+  timeout_sec: ${VA_TEST_TIMEOUT:-30}
+server:
+  port: "${VA_TEST_PORT:-8080}"
+data:
+  db_path: ${VA_TEST_EMPTY:-data.db}
+bocha:
+  api_key: ${VA_TEST_UNSET}
+`
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		t.Fatalf("yaml.Unmarshal: %v", err)
+	}
+	interpolate(&doc)
+	var cfg Config
+	if err := doc.Decode(&cfg); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	// 变量值中的引号、冒号与#不影响文档结构
+	if cfg.LLM.APIKey != `sk-"a": #b` {
+		t.Errorf("llm.api_key = %q", cfg.LLM.APIKey)
+	}
+	// 未加引号的值按替换后的内容推断类型
+	if cfg.LLM.TimeoutSec != 30 {
+		t.Errorf("llm.timeout_sec = %d, want 30", cfg.LLM.TimeoutSec)
+	}
+	if cfg.Server.Port != "9090" {
+		t.Errorf("server.port = %q, want 9090", cfg.Server.Port)
+	}
+	// 变量为空时使用默认值
+	if cfg.Data.DBPath != "data.db" {
+		t.Errorf("data.db_path = %q, want data.db", cfg.Data.DBPath)
+	}
+	// 未设置且无默认值时替换为空
+	if cfg.BOCHA.APIKey != "" {
+		t.Errorf("bocha.api_key = %q, want 空", cfg.BOCHA.APIKey)
+	}
+}
+
+func TestInterpolateKeepsKeys(t *testing.T) {
+	t.Setenv("VA_TEST_NAME", "x")
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte("${VA_TEST_NAME}: ${VA_TEST_NAME}\n"), &doc); err != nil {
+		t.Fatalf("yaml.Unmarshal: %v", err)
+	}
+	interpolate(&doc)
+	var m map[string]string
+	if err := doc.Decode(&m); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if m["${VA_TEST_NAME}"] != "x" {
+		t.Errorf("got %v, want 只替换值不替换键", m)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := []struct{ path, want string }{
+		{"server.port", "VA_SERVER_PORT"},
+		{"llm.api_key", "VA_LLM_API_KEY"},
+		{"rate_limit.per_ip.burst", "VA_RATE_LIMIT_PER_IP_BURST"},
+	}
+	for _, tt := range tests {
+		if got := envName(tt.path); got != tt.want {
+			t.Errorf("envName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	t.Setenv("VA_SERVER_PORT", "7070")
+	t.Setenv("VA_LLM_MAX_TOKENS", "100")
+
+	var cfg Config
+	err := applyOverrides(&cfg, []string{
+		"llm.max_tokens=200", // --set优先于环境变量
+		"auth.enabled=true",
+		"cors.allowed_origins=https://a.example, https://b.example,",
+		"cors.allowed_methods=[GET, POST]",
+		"prompt.preamble=",
+		"llm.api_key=a=b",
+	})
+	if err != nil {
+		t.Fatalf("applyOverrides: %v", err)
+	}
+	if cfg.Server.Port != "7070" {
+		t.Errorf("server.port = %q, want 7070", cfg.Server.Port)
+	}
+	if cfg.LLM.MaxTokens != 200 {
+		t.Errorf("llm.max_tokens = %d, want 200", cfg.LLM.MaxTokens)
+	}
+	if !cfg.Auth.Enabled {
+		t.Error("auth.enabled = false, want true")
+	}
+	if want := []string{"https://a.example", "https://b.example"}; !slices.Equal(cfg.CORS.AllowedOrigins, want) {
+		t.Errorf("cors.allowed_origins = %q, want %q", cfg.CORS.AllowedOrigins, want)
+	}
+	if want := []string{"GET", "POST"}; !slices.Equal(cfg.CORS.AllowedMethods, want) {
+		t.Errorf("cors.allowed_methods = %q, want %q", cfg.CORS.AllowedMethods, want)
+	}
+	// 可选字符串显式设为空
+	if cfg.Prompt.Preamble == nil || *cfg.Prompt.Preamble != "" {
+		t.Errorf("prompt.preamble = %v, want 指向空字符串", cfg.Prompt.Preamble)
+	}
+	if cfg.Prompt.ToolGuide != nil {
+		t.Error("未覆盖的prompt.tool_guide应保持nil")
+	}
+	if cfg.LLM.APIKey != "a=b" {
+		t.Errorf("llm.api_key = %q, want a=b", cfg.LLM.APIKey)
+	}
+}
+
+func TestApplyOverridesErrors(t *testing.T) {
+	t.Setenv("VA_LLM_TIMEOUT_SEC", "abc")
+
+	var cfg Config
+	err := applyOverrides(&cfg, []string{"server.port", "no.such_field=1", "auth.enabled=maybe"})
+	if err == nil {
+		t.Fatal("applyOverrides 未返回错误")
+	}
+	// 所有失败项一并返回
+	for _, want := range []string{"VA_LLM_TIMEOUT_SEC", "格式应为 key=value", "未知的配置项", "auth.enabled"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误信息缺少%q: %v", want, err)
+		}
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	var cfg Config
+	cfg.Data.DBPath = "../data/va.db"
+	cfg.Server.TLS.CertFile = "/etc/ssl/cert.pem"
+	cfg.Server.TLS.KeyFile = "certs/key.pem"
+	cfg.Assistants.Dir = ""
+	resolvePaths(&cfg, filepath.Join("/opt", "va", "config", "application.yaml"))
+
+	tests := []struct{ field, got, want string }{
+		{"data.db_path", cfg.Data.DBPath, filepath.Join("/opt", "va", "data", "va.db")},
+		{"server.tls.cert_file", cfg.Server.TLS.CertFile, "/etc/ssl/cert.pem"},
+		{"server.tls.key_file", cfg.Server.TLS.KeyFile, filepath.Join("/opt", "va", "config", "certs", "key.pem")},
+		{"assistants.dir", cfg.Assistants.Dir, ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.field, tt.got, tt.want)
+		}
+	}
+
+	cfg.Data.DBPath = ":memory:"
+	resolvePaths(&cfg, "config/application.yaml")
+	if cfg.Data.DBPath != ":memory:" {
+		t.Errorf("内存库路径被修改为%q", cfg.Data.DBPath)
+	}
+}
+
+func TestLoadConfigRelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "application.yaml")
+	src := `
+server:
+  port: ":8080"
+llm:
+  base_url: "http://127.0.0.1:9999/v1/chat/completions"
+  model_name: "m"
+  max_tokens: 100
+data:
+  db_path: data/va.db
+assistants:
+  dir: assistants
+`
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "assistants"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// 工作目录不是配置文件所在目录
+	t.Chdir(t.TempDir())
+
+	cfg, err := LoadConfig(Options{Path: path})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if want := filepath.Join(dir, "data", "va.db"); cfg.Data.DBPath != want {
+		t.Errorf("data.db_path = %q, want %q", cfg.Data.DBPath, want)
+	}
+	// 校验按解析后的路径检查目录是否存在
+	if want := filepath.Join(dir, "assistants"); cfg.Assistants.Dir != want {
+		t.Errorf("assistants.dir = %q, want %q", cfg.Assistants.Dir, want)
+	}
+
+	// 覆盖值中的相对路径按工作目录解析
+	cfg, err = LoadConfig(Options{Path: path, Sets: []string{"data.db_path=local.db"}})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Data.DBPath != "local.db" {
+		t.Errorf("data.db_path = %q, want local.db", cfg.Data.DBPath)
+	}
+}
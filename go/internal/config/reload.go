@@ -0,0 +1,157 @@
+package config
+
+import (
+	"Voice_Assistant/internal/api/middleware"
+	"Voice_Assistant/internal/logging"
+	"context"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 可热更新的配置项（按yaml路径前缀匹配），其余字段变更需要重启
+var hotReloadable = []string{"llm", "bocha", "log", "rate_limit", "quota", "pricing", "cors", "prompt"}
+
+// 未配置时的配置文件检查间隔
+const defaultReloadInterval = 5 * time.Second
+
+// Reload 重新加载并校验配置，热更新可变字段；需要重启的字段变更被忽略并记录日志
+func (a *App) Reload() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	next, err := LoadConfig(a.opts)
+	if err != nil {
+		return err
+	}
+	merged, hot, restart := mergeReloadable(a.current, next)
+	if len(restart) > 0 {
+		slog.Warn("以下配置需要重启才能生效，本次已忽略", "fields", restart)
+	}
+	if len(hot) == 0 {
+		slog.Info("配置已重新加载，无可热更新的变更")
+		return nil
+	}
+
+	a.apply(merged, hot)
+	a.current = merged
+	slog.Info("配置已热更新", "fields", hot)
+	return nil
+}
+
+// apply 将变更的配置分发到各组件（仅替换发生变化的部分，避免无谓地重置限流状态）
+func (a *App) apply(cfg *Config, hot []string) {
+	if touched(hot, "log") {
+		// 已通过校验，不会失败
+		_ = logging.Setup(cfg.Log)
+	}
+	if touched(hot, "llm") || touched(hot, "bocha") {
+		a.services.LLM.Reconfigure(cfg.LLM.APIKey, cfg.LLM.BaseURL, cfg.LLM.ModelName, cfg.LLM.MaxTokens, cfg.LLM.TimeoutSec, cfg.BOCHA.APIKey)
+	}
+	if touched(hot, "quota") || touched(hot, "pricing") {
+		a.services.Quota.UpdatePolicy(cfg.Quota, cfg.Pricing)
+	}
+	if touched(hot, "prompt") {
+		a.services.History.UpdateSystemPrompts(systemPrompts(cfg))
+	}
+	if touched(hot, "cors") {
+		a.cors.Store(middleware.CORS(cfg.CORS))
+	}
+	if touched(hot, "rate_limit") {
+		for group, r := range a.rateLimits {
+			r.Store(rateLimitHandler(cfg, group))
+		}
+		a.streamLimit.SetMax(maxConcurrentStreams(cfg))
+		a.ipLimit.Store(ipLimitHandler(cfg))
+	}
+}
+
+// Watch 定期检查配置文件修改时间，变化时重新加载（ctx取消后退出）
+func (a *App) Watch(ctx context.Context) {
+	path, err := resolveConfigPath(a.opts.Path)
+	if err != nil {
+		slog.Error("监听配置文件失败", "error", err)
+		return
+	}
+	interval := defaultReloadInterval
+	if a.Config.Reload.IntervalSec > 0 {
+		interval = time.Duration(a.Config.Reload.IntervalSec) * time.Second
+	}
+
+	lastMod := modTime(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mod := modTime(path)
+			if mod.Equal(lastMod) {
+				continue
+			}
+			lastMod = mod
+			slog.Info("检测到配置文件变化，重新加载", "path", path)
+			if err := a.Reload(); err != nil {
+				slog.Error("重新加载配置失败，继续使用当前配置", "error", err)
+			}
+		}
+	}
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// mergeReloadable 以当前配置为基础合入可热更新字段的变更，返回合并结果、已合入字段与需重启字段
+func mergeReloadable(cur, next *Config) (*Config, []string, []string) {
+	merged := *cur
+	var mergedFields, nextFields []configField
+	collectFields(reflect.ValueOf(&merged).Elem(), "", &mergedFields)
+	collectFields(reflect.ValueOf(next).Elem(), "", &nextFields)
+
+	var hot, restart []string
+	for i, f := range mergedFields {
+		nv := nextFields[i].value
+		if reflect.DeepEqual(f.value.Interface(), nv.Interface()) {
+			continue
+		}
+		if isHotReloadable(f.path) {
+			f.value.Set(nv)
+			hot = append(hot, f.path)
+		} else {
+			restart = append(restart, f.path)
+		}
+	}
+	return &merged, hot, restart
+}
+
+// touched 判断变更字段中是否有属于某配置段的字段
+func touched(fields []string, section string) bool {
+	for _, f := range fields {
+		if inSection(f, section) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHotReloadable 判断字段是否可热更新
+func isHotReloadable(path string) bool {
+	for _, section := range hotReloadable {
+		if inSection(path, section) {
+			return true
+		}
+	}
+	return false
+}
+
+func inSection(path, section string) bool {
+	return path == section || strings.HasPrefix(path, section+".")
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"Voice_Assistant/internal/model"
+	"slices"
+	"testing"
+)
+
+func TestMergeReloadable(t *testing.T) {
+	cur := &Config{}
+	cur.Server.Port = "8080"
+	cur.Data.DBPath = "a.db"
+	cur.LLM.ModelName = "qwen-plus"
+	cur.LLM.MaxTokens = 1000
+	cur.RateLimit.Groups = map[string]RateLimitRule{"chat": {RatePerMinute: 10}}
+
+	next := &Config{}
+	*next = *cur
+	next.Server.Port = "9090"                                                     // 需要重启
+	next.LLM.ModelName = "qwen-max"                                               // 可热更新
+	next.RateLimit.Groups = map[string]RateLimitRule{"chat": {RatePerMinute: 20}} // 可热更新
+	next.Pricing = map[string]model.ModelPrice{"qwen-max": {}}                    // 可热更新
+
+	merged, hot, restart := mergeReloadable(cur, next)
+
+	if want := []string{"llm.model_name", "pricing", "rate_limit.groups"}; !slices.Equal(sorted(hot), want) {
+		t.Errorf("hot = %q, want %q", hot, want)
+	}
+	if want := []string{"server.port"}; !slices.Equal(restart, want) {
+		t.Errorf("restart = %q, want %q", restart, want)
+	}
+	// 可热更新字段取新值，需重启字段保留旧值，未变更字段不变
+	if merged.LLM.ModelName != "qwen-max" || merged.RateLimit.Groups["chat"].RatePerMinute != 20 || merged.Pricing == nil {
+		t.Errorf("热更新字段未合入: %+v", merged.LLM)
+	}
+	if merged.Server.Port != "8080" {
+		t.Errorf("server.port = %q, want 8080", merged.Server.Port)
+	}
+	if merged.Data.DBPath != "a.db" || merged.LLM.MaxTokens != 1000 {
+		t.Error("未变更字段被修改")
+	}
+	// 不修改当前配置
+	if cur.LLM.ModelName != "qwen-plus" || cur.RateLimit.Groups["chat"].RatePerMinute != 10 {
+		t.Error("mergeReloadable 修改了当前配置")
+	}
+}
+
+func TestMergeReloadableNoChange(t *testing.T) {
+	cur := &Config{}
+	cur.LLM.ModelName = "qwen-plus"
+	next := &Config{}
+	next.LLM.ModelName = "qwen-plus"
+	_, hot, restart := mergeReloadable(cur, next)
+	if len(hot) != 0 || len(restart) != 0 {
+		t.Errorf("无变更时 hot = %q, restart = %q", hot, restart)
+	}
+}
+
+func TestIsHotReloadable(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"llm.api_key", true},
+		{"rate_limit.per_ip.burst", true},
+		{"pricing", true},
+		{"prompt.preamble", true},
+		{"server.port", false},
+		{"data.db_path", false},
+		{"llmx.api_key", false},
+	}
+	for _, tt := range tests {
+		if got := isHotReloadable(tt.path); got != tt.want {
+			t.Errorf("isHotReloadable(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func sorted(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"Voice_Assistant/internal/logging"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/service"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// validator 收集所有校验失败的字段
+type validator struct {
+	errs []string
+}
+
+func (v *validator) check(ok bool, field, msg string) {
+	if !ok {
+		v.errs = append(v.errs, field+": "+msg)
+	}
+}
+
+func (v *validator) nonNegative(n float64, field string) {
+	v.check(n >= 0, field, "不能为负数")
+}
+
+func (v *validator) httpURL(raw, field string) {
+	u, err := url.Parse(raw)
+	v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", field, "不是合法的http(s)地址")
+}
+
+func (v *validator) quotaLimit(l model.QuotaLimit, field string) {
+	v.nonNegative(float64(l.DailyTokens), field+".daily_tokens")
+	v.nonNegative(float64(l.MonthlyTokens), field+".monthly_tokens")
+	v.nonNegative(l.DailyCost, field+".daily_cost")
+	v.nonNegative(l.MonthlyCost, field+".monthly_cost")
+}
+
+// Validate 校验配置，一次性列出所有不合法字段
+func (c *Config) Validate() error {
+	var v validator
+
+	// 服务
+	v.check(c.Server.Port != "", "server.port", "不能为空")
+	v.nonNegative(float64(c.Server.ShutdownTimeoutSec), "server.shutdown_timeout_sec")
+	v.nonNegative(float64(c.Server.ReadHeaderTimeoutSec), "server.read_header_timeout_sec")
+	v.nonNegative(float64(c.Server.ReadTimeoutSec), "server.read_timeout_sec")
+	v.nonNegative(float64(c.Server.WriteTimeoutSec), "server.write_timeout_sec")
+	v.nonNegative(float64(c.Server.IdleTimeoutSec), "server.idle_timeout_sec")
+	for i, p := range c.Server.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(p)
+		v.check(cidrErr == nil || net.ParseIP(p) != nil, fmt.Sprintf("server.trusted_proxies[%d]", i), "不是合法的IP地址或网段")
+	}
+	tls := c.Server.TLS
+	v.check((tls.CertFile == "") == (tls.KeyFile == ""), "server.tls", "cert_file与key_file需同时配置")
+	for field, path := range map[string]string{"server.tls.cert_file": tls.CertFile, "server.tls.key_file": tls.KeyFile} {
+		if path != "" {
+			_, err := os.Stat(path)
+			v.check(err == nil, field, "文件不存在")
+		}
+	}
+	for i, o := range c.CORS.AllowedOrigins {
+		if o != "*" {
+			v.httpURL(o, fmt.Sprintf("cors.allowed_origins[%d]", i))
+		}
+	}
+
+	// 数据与LLM
+	v.check(c.Data.DBPath != "", "data.db_path", "不能为空")
+	v.httpURL(c.LLM.BaseURL, "llm.base_url")
+	v.check(c.LLM.ModelName != "", "llm.model_name", "不能为空")
+	v.check(c.LLM.MaxTokens > 0, "llm.max_tokens", "必须大于0")
+	v.nonNegative(float64(c.LLM.TimeoutSec), "llm.timeout_sec")
+
+	// 鉴权与配额
+	v.nonNegative(float64(c.Auth.SessionTTLHours), "auth.session_ttl_hours")
+	v.quotaLimit(c.Quota.DefaultUser, "quota.default_user")
+	v.quotaLimit(c.Quota.DefaultAssistant, "quota.default_assistant")
+	for id, l := range c.Quota.Users {
+		v.quotaLimit(l, "quota.users."+id)
+	}
+	for id, l := range c.Quota.Assistants {
+		v.quotaLimit(l, "quota.assistants."+id)
+	}
+	for name, p := range c.Pricing {
+		v.nonNegative(p.InputPer1K, "pricing."+name+".input_per_1k")
+		v.nonNegative(p.OutputPer1K, "pricing."+name+".output_per_1k")
+	}
+	v.nonNegative(float64(c.RateLimit.PerIP.RatePerMinute), "rate_limit.per_ip.rate_per_minute")
+	v.nonNegative(float64(c.RateLimit.PerIP.Burst), "rate_limit.per_ip.burst")
+	for group, rule := range c.RateLimit.Groups {
+		v.nonNegative(float64(rule.RatePerMinute), "rate_limit.groups."+group+".rate_per_minute")
+		v.nonNegative(float64(rule.Burst), "rate_limit.groups."+group+".burst")
+	}
+	v.nonNegative(float64(c.RateLimit.MaxConcurrentStreams), "rate_limit.max_concurrent_streams")
+
+	// 可观测性
+	v.check(c.Metrics.Path == "" || strings.HasPrefix(c.Metrics.Path, "/"), "metrics.path", "必须以/开头")
+	switch c.Tracing.Exporter {
+	case "", "none", "stdout", "otlp":
+	default:
+		v.check(false, "tracing.exporter", "可选值：none、stdout、otlp")
+	}
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "取值范围为0~1")
+	_, err := logging.ParseLevel(c.Log.Level)
+	v.check(err == nil, "log.level", "可选值：debug、info、warn、error")
+	switch strings.ToLower(c.Log.Format) {
+	case "", "text", "json":
+	default:
+		v.check(false, "log.format", "可选值：text、json")
+	}
+	v.nonNegative(float64(c.Reload.IntervalSec), "reload.interval_sec")
+
+	// 对话
+	v.check(c.History.ConcurrentTurns == "" || service.IsValidTurnPolicy(c.History.ConcurrentTurns),
+		"history.concurrent_turns", "可选值：queue、reject、allow")
+	if c.History.Timezone != "" {
+		_, err := time.LoadLocation(c.History.Timezone)
+		v.check(err == nil, "history.timezone", "未知的时区")
+	}
+
+	// 声明式助手
+	if dir := c.Assistants.Dir; dir != "" {
+		info, err := os.Stat(dir)
+		v.check(err == nil && info.IsDir(), "assistants.dir", "目录不存在")
+	}
+
+	// 知识库
+	e := c.Embedding
+	v.check(service.IsValidEmbeddingProvider(e.Provider), "embedding.provider", "可选值：http、local、none")
+	if e.Provider == service.EmbeddingHTTP || (e.Provider == "" && e.BaseURL != "") {
+		v.httpURL(e.BaseURL, "embedding.base_url")
+		v.check(e.Model != "", "embedding.model", "不能为空")
+	}
+	v.nonNegative(float64(e.Dimensions), "embedding.dimensions")
+	v.nonNegative(float64(e.BatchSize), "embedding.batch_size")
+	v.nonNegative(float64(e.MaxRetries), "embedding.max_retries")
+	v.nonNegative(float64(e.TimeoutSec), "embedding.timeout_sec")
+	k := c.Knowledge
+	v.nonNegative(float64(k.ChunkSize), "knowledge.chunk_size")
+	v.nonNegative(float64(k.ChunkOverlap), "knowledge.chunk_overlap")
+	v.check(k.ChunkSize == 0 || k.ChunkOverlap < k.ChunkSize, "knowledge.chunk_overlap", "必须小于chunk_size")
+	v.nonNegative(float64(k.TopK), "knowledge.top_k")
+	v.check(k.MinScore >= 0 && k.MinScore < 1, "knowledge.min_score", "取值范围为0~1")
+	v.nonNegative(float64(k.MaxDocumentMB), "knowledge.max_document_mb")
+	m := c.Memory
+	v.nonNegative(float64(m.TopK), "memory.top_k")
+	v.check(m.MinScore >= 0 && m.MinScore < 1, "memory.min_score", "取值范围为0~1")
+	v.nonNegative(float64(m.MaxPerUser), "memory.max_per_user")
+
+	// 归档
+	v.nonNegative(float64(c.Archive.MaxImportMB), "archive.max_import_mb")
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	// map遍历顺序不固定，排序后输出便于比对
+	sort.Strings(v.errs)
+	return fmt.Errorf("配置校验失败（%d项）:\n  - %s", len(v.errs), strings.Join(v.errs, "\n  - "))
+}
@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/metrics"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/tracing"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// APIKeySQLiteRepo 实现APIKeyRepo接口
+type APIKeySQLiteRepo struct {
+	db *sql.DB
+}
+
+// NewAPIKeySQLiteRepo 创建实例
+func NewAPIKeySQLiteRepo(db *sql.DB) *APIKeySQLiteRepo {
+	return &APIKeySQLiteRepo{db: db}
+}
+
+// SelectAll 查询当前用户的所有API密钥（管理员查询全部）
+func (r *APIKeySQLiteRepo) SelectAll(ctx context.Context) ([]model.APIKey, error) {
+	defer metrics.ObserveQuery("api_key", "SelectAll")()
+	ctx, span := tracing.Start(ctx, "sqlite.api_key.SelectAll")
+	defer span.End()
+	query := "SELECT id, user_id, name, key_hash, prefix, scope, assistant_ids, gmt_create, gmt_last_used FROM api_keys"
+	var args []any
+	if !auth.IsAdmin(ctx) {
+		query += " WHERE user_id = ?"
+		args = append(args, auth.UserID(ctx))
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询API密钥失败: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []model.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// SelectByHash 按密钥哈希查询
+func (r *APIKeySQLiteRepo) SelectByHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	defer metrics.ObserveQuery("api_key", "SelectByHash")()
+	ctx, span := tracing.Start(ctx, "sqlite.api_key.SelectByHash")
+	defer span.End()
+	query := "SELECT id, user_id, name, key_hash, prefix, scope, assistant_ids, gmt_create, gmt_last_used FROM api_keys WHERE key_hash = ?"
+	return scanAPIKey(r.db.QueryRowContext(ctx, query, keyHash))
+}
+
+// Save 保存新API密钥
+func (r *APIKeySQLiteRepo) Save(ctx context.Context, k *model.APIKey) (*model.APIKey, error) {
+	defer metrics.ObserveQuery("api_key", "Save")()
+	ctx, span := tracing.Start(ctx, "sqlite.api_key.Save")
+	defer span.End()
+	assistantIDs, err := json.Marshal(k.AssistantIDs)
+	if err != nil {
+		return nil, fmt.Errorf("序列化助手范围失败: %w", err)
+	}
+	query := `
+	INSERT INTO api_keys (id, user_id, name, key_hash, prefix, scope, assistant_ids, gmt_create, gmt_last_used)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = r.db.ExecContext(ctx, query,
+		k.ID, k.UserID, k.Name, k.KeyHash, k.Prefix, k.Scope,
+		string(assistantIDs), k.GmtCreate, k.GmtLastUsed,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("保存API密钥失败: %w", err)
+	}
+	return k, nil
+}
+
+// DeleteByID 按ID删除API密钥（非管理员只能删除自己的密钥）
+func (r *APIKeySQLiteRepo) DeleteByID(ctx context.Context, id string) error {
+	defer metrics.ObserveQuery("api_key", "DeleteByID")()
+	ctx, span := tracing.Start(ctx, "sqlite.api_key.DeleteByID")
+	defer span.End()
+	query := "DELETE FROM api_keys WHERE id = ?"
+	args := []any{id}
+	if !auth.IsAdmin(ctx) {
+		query += " AND user_id = ?"
+		args = append(args, auth.UserID(ctx))
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("删除API密钥失败: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return errors.New("API密钥不存在")
+	}
+	return nil
+}
+
+// UpdateLastUsed 更新最近使用时间
+func (r *APIKeySQLiteRepo) UpdateLastUsed(ctx context.Context, id, lastUsed string) error {
+	defer metrics.ObserveQuery("api_key", "UpdateLastUsed")()
+	ctx, span := tracing.Start(ctx, "sqlite.api_key.UpdateLastUsed")
+	defer span.End()
+	if _, err := r.db.ExecContext(ctx,
+		"UPDATE api_keys SET gmt_last_used = ? WHERE id = ?",
+		lastUsed, id,
+	); err != nil {
+		return fmt.Errorf("更新API密钥使用时间失败: %w", err)
+	}
+	return nil
+}
+
+// scanAPIKey 扫描单行API密钥数据（兼容*sql.Row与*sql.Rows）
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (*model.APIKey, error) {
+	var k model.APIKey
+	var assistantIDs string
+	if err := row.Scan(
+		&k.ID, &k.UserID, &k.Name, &k.KeyHash, &k.Prefix, &k.Scope,
+		&assistantIDs, &k.GmtCreate, &k.GmtLastUsed,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("扫描API密钥数据失败: %w", err)
+	}
+	if assistantIDs != "" {
+		if err := json.Unmarshal([]byte(assistantIDs), &k.AssistantIDs); err != nil {
+			return nil, fmt.Errorf("解析助手范围失败: %w", err)
+		}
+	}
+	return &k, nil
+}
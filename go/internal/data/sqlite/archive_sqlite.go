@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/metrics"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/tracing"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ArchiveSQLiteRepo 实现ArchiveRepo接口
+type ArchiveSQLiteRepo struct {
+	db *sql.DB
+}
+
+// NewArchiveSQLiteRepo 创建实例
+func NewArchiveSQLiteRepo(db *sql.DB) *ArchiveSQLiteRepo {
+	return &ArchiveSQLiteRepo{db: db}
+}
+
+// SelectHistories 查询助手下所有用户的会话
+func (r *ArchiveSQLiteRepo) SelectHistories(ctx context.Context, assistantID string) ([]model.History, error) {
+	defer metrics.ObserveQuery("archive", "SelectHistories")()
+	ctx, span := tracing.Start(ctx, "sqlite.archive.SelectHistories")
+	defer span.End()
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT user_id, CAST(messages AS TEXT) FROM histories WHERE assistant_id = ? ORDER BY user_id", assistantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询会话失败: %w", err)
+	}
+	defer rows.Close()
+
+	histories := []model.History{}
+	for rows.Next() {
+		h := model.History{AssistantID: assistantID}
+		var messages string
+		if err := rows.Scan(&h.UserID, &messages); err != nil {
+			return nil, fmt.Errorf("扫描会话失败: %w", err)
+		}
+		if err := json.Unmarshal([]byte(messages), &h.Messages); err != nil {
+			return nil, fmt.Errorf("解析会话消息失败: %w", err)
+		}
+		histories = append(histories, h)
+	}
+	return histories, rows.Err()
+}
+
+// Restore 在一个事务中写入助手及其会话（同ID的助手原地覆盖，会话与版本以归档为准），任一失败则全部回滚
+func (r *ArchiveSQLiteRepo) Restore(ctx context.Context, assistants []model.ArchivedAssistant) error {
+	defer metrics.ObserveQuery("archive", "Restore")()
+	ctx, span := tracing.Start(ctx, "sqlite.archive.Restore")
+	defer span.End()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, a := range assistants {
+		// 不删除助手本身（删除会级联清除不在归档中的知识库与会话变量），只替换归档包含的会话与版本
+		if _, err := tx.ExecContext(ctx, "DELETE FROM histories WHERE assistant_id = ?", a.ID); err != nil {
+			return fmt.Errorf("删除助手%s的会话失败: %w", a.ID, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM assistant_revisions WHERE assistant_id = ?", a.ID); err != nil {
+			return fmt.Errorf("删除助手%s的版本失败: %w", a.ID, err)
+		}
+
+		sharedWith, modelParams, tools, err := marshalAssistantJSON(&a.Assistant)
+		if err != nil {
+			return err
+		}
+		// 导入的助手不再由原机器的YAML文件管理，source置空；版本历史不随归档迁移，以导入内容作为当前版本
+		revision := max(a.Revision, 1)
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO assistants (id, name, description, prompt, gmt_create, gmt_modified, time_stamp, owner_id, visibility, shared_with, model_params, tools, revision, source)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, '')
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name, description = excluded.description, prompt = excluded.prompt,
+			gmt_create = excluded.gmt_create, gmt_modified = excluded.gmt_modified, time_stamp = excluded.time_stamp,
+			owner_id = excluded.owner_id, visibility = excluded.visibility, shared_with = excluded.shared_with,
+			model_params = excluded.model_params, tools = excluded.tools, revision = excluded.revision, source = ''
+		`, a.ID, a.Name, a.Description, a.Prompt,
+			a.GmtCreate, a.GmtModified, a.TimeStamp,
+			a.OwnerID, a.Visibility, sharedWith,
+			modelParams, tools, revision,
+		); err != nil {
+			return fmt.Errorf("写入助手%s失败: %w", a.ID, err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO assistant_revisions (assistant_id, revision, name, description, prompt, model_params, tools, author_id, note, gmt_create)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, '归档导入', ?)
+		`, a.ID, revision, a.Name, a.Description, a.Prompt,
+			modelParams, tools, auth.UserID(ctx), time.Now().Format("2006-01-02 15:04:05"),
+		); err != nil {
+			return fmt.Errorf("写入助手%s的版本失败: %w", a.ID, err)
+		}
+
+		for _, h := range a.Histories {
+			messages := h.Messages
+			if messages == nil {
+				messages = []model.Message{}
+			}
+			data, err := json.Marshal(messages)
+			if err != nil {
+				return fmt.Errorf("序列化会话失败: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO histories (assistant_id, user_id, messages) VALUES (?, ?, ?)",
+				a.ID, h.UserID, string(data),
+			); err != nil {
+				return fmt.Errorf("写入助手%s的会话失败: %w", a.ID, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
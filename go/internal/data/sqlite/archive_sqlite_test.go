@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"Voice_Assistant/internal/model"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRestoreOverwriteKeepsKnowledgeAndVars(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	assistants := NewAssistantSQLiteRepo(db)
+	knowledge := NewKnowledgeSQLiteRepo(db)
+	histories := NewHistorySQLiteRepo(db)
+
+	if _, err := assistants.Save(ctx, &model.Assistant{ID: "a1", Name: "旧名称", Prompt: "旧提示词", Source: "a1.yaml", Revision: 3}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	doc := &model.KnowledgeDocument{ID: "d1", AssistantID: "a1", Title: "手册", Chunks: 1, EmbeddingModel: "m"}
+	if err := knowledge.SaveDocument(ctx, doc, []model.KnowledgeChunk{{Seq: 0, Content: "内容", Embedding: []float32{1, 0}}}); err != nil {
+		t.Fatalf("SaveDocument: %v", err)
+	}
+	if err := histories.SaveVars(ctx, "a1", &model.ConversationVars{Title: "会话", Variables: map[string]string{"city": "杭州"}}); err != nil {
+		t.Fatalf("SaveVars: %v", err)
+	}
+	if err := histories.SaveByAssistantID(ctx, "a1", model.Message{Input: model.Input{Send: "旧消息"}}); err != nil {
+		t.Fatalf("SaveByAssistantID: %v", err)
+	}
+
+	archived := model.ArchivedAssistant{
+		Assistant: model.Assistant{ID: "a1", Name: "新名称", Prompt: "新提示词", Visibility: model.VisibilityPrivate, Revision: 7},
+		Histories: []model.History{{UserID: "", Messages: []model.Message{{Input: model.Input{Send: "归档消息"}}}}},
+	}
+	if err := NewArchiveSQLiteRepo(db).Restore(ctx, []model.ArchivedAssistant{archived}); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	var name, prompt, source string
+	var revision int
+	if err := db.QueryRow("SELECT name, prompt, source, revision FROM assistants WHERE id = 'a1'").Scan(&name, &prompt, &source, &revision); err != nil {
+		t.Fatalf("查询助手: %v", err)
+	}
+	if name != "新名称" || prompt != "新提示词" || source != "" || revision != 7 {
+		t.Errorf("助手 = (%q, %q, %q, %d), want 归档内容且source为空", name, prompt, source, revision)
+	}
+
+	// 知识库与会话变量不在归档中，覆盖后保留
+	docs, err := knowledge.SelectDocuments(ctx, "a1")
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("覆盖后文档 = %v, err = %v, want 保留1篇", docs, err)
+	}
+	chunks, err := knowledge.SelectChunks(ctx, "a1")
+	if err != nil || len(chunks) != 1 {
+		t.Fatalf("覆盖后片段 = %d, err = %v, want 保留1段", len(chunks), err)
+	}
+	vars, err := histories.SelectVars(ctx, "a1")
+	if err != nil || vars.Variables["city"] != "杭州" {
+		t.Fatalf("覆盖后会话变量 = %v, err = %v, want 保留", vars, err)
+	}
+
+	// 会话与版本以归档为准
+	h, err := histories.SelectByAssistantID(ctx, "a1")
+	if err != nil {
+		t.Fatalf("SelectByAssistantID: %v", err)
+	}
+	if len(h.Messages) != 1 || h.Messages[0].Input.Send != "归档消息" {
+		t.Errorf("会话 = %+v, want 只有归档消息", h.Messages)
+	}
+	revisions, err := assistants.SelectRevisions(ctx, "a1")
+	if err != nil || len(revisions) != 1 || revisions[0].Revision != 7 {
+		t.Errorf("版本 = %+v, err = %v, want 只有导入的第7版", revisions, err)
+	}
+}
+
+func TestRestoreNewAssistant(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	archived := model.ArchivedAssistant{Assistant: model.Assistant{ID: "a2", Name: "新助手", Prompt: "p"}}
+	if err := NewArchiveSQLiteRepo(db).Restore(ctx, []model.ArchivedAssistant{archived}); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	var revision int
+	if err := db.QueryRow("SELECT revision FROM assistants WHERE id = 'a2'").Scan(&revision); err != nil {
+		t.Fatalf("查询助手: %v", err)
+	}
+	// 未记录版本号的归档从第1版开始
+	if revision != 1 {
+		t.Errorf("revision = %d, want 1", revision)
+	}
+}
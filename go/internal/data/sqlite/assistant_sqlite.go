@@ -1,13 +1,24 @@
 package sqlite
 
 import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/metrics"
 	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/tracing"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 )
 
+// 助手查询字段
+const assistantColumns = "id, name, description, prompt, gmt_create, gmt_modified, time_stamp, owner_id, visibility, shared_with, model_params, tools, source, revision"
+
+// 非管理员可见的助手：自己的、公开的、共享给自己的
+const assistantVisibleFilter = `(owner_id = ? OR visibility = 'public' OR
+	(visibility = 'shared' AND EXISTS (SELECT 1 FROM json_each(assistants.shared_with) WHERE value = ?)))`
+
 // AssistantSQLiteRepo 实现AssistantRepo接口
 type AssistantSQLiteRepo struct {
 	db *sql.DB
@@ -18,10 +29,19 @@ func NewAssistantSQLiteRepo(db *sql.DB) *AssistantSQLiteRepo {
 	return &AssistantSQLiteRepo{db: db}
 }
 
-// SelectAll 查询所有助手
+// SelectAll 查询当前用户可见的所有助手
 func (r *AssistantSQLiteRepo) SelectAll(ctx context.Context) ([]model.Assistant, error) {
-	query := "SELECT id, name, description, prompt, gmt_create, gmt_modified, time_stamp FROM assistants;"
-	rows, err := r.db.QueryContext(ctx, query)
+	defer metrics.ObserveQuery("assistant", "SelectAll")()
+	ctx, span := tracing.Start(ctx, "sqlite.assistant.SelectAll")
+	defer span.End()
+	query := "SELECT " + assistantColumns + " FROM assistants"
+	var args []any
+	if !auth.IsAdmin(ctx) {
+		uid := auth.UserID(ctx)
+		query += " WHERE " + assistantVisibleFilter
+		args = append(args, uid, uid)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("查询助手失败: %w", err)
 	}
@@ -30,19 +50,33 @@ func (r *AssistantSQLiteRepo) SelectAll(ctx context.Context) ([]model.Assistant,
 	var assistants []model.Assistant
 	for rows.Next() {
 		var a model.Assistant
+		var sharedWith, modelParams, tools string
 		if err := rows.Scan(
 			&a.ID, &a.Name, &a.Description, &a.Prompt,
 			&a.GmtCreate, &a.GmtModified, &a.TimeStamp,
+			&a.OwnerID, &a.Visibility, &sharedWith,
+			&modelParams, &tools, &a.Source, &a.Revision,
 		); err != nil {
 			return nil, fmt.Errorf("扫描助手数据失败: %w", err)
 		}
+		if sharedWith != "" {
+			if err := json.Unmarshal([]byte(sharedWith), &a.SharedWith); err != nil {
+				return nil, fmt.Errorf("解析共享用户失败: %w", err)
+			}
+		}
+		if err := unmarshalModelParams(modelParams, tools, &a.ModelParams, &a.Tools); err != nil {
+			return nil, err
+		}
 		assistants = append(assistants, a)
 	}
 	return assistants, rows.Err()
 }
 
-// DeleteByID 按ID删除助手（级联删除历史）
+// DeleteByID 按ID删除助手（级联删除历史，非管理员只能删除自己的助手）
 func (r *AssistantSQLiteRepo) DeleteByID(ctx context.Context, id string) error {
+	defer metrics.ObserveQuery("assistant", "DeleteByID")()
+	ctx, span := tracing.Start(ctx, "sqlite.assistant.DeleteByID")
+	defer span.End()
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("开启事务失败: %w", err)
@@ -50,7 +84,8 @@ func (r *AssistantSQLiteRepo) DeleteByID(ctx context.Context, id string) error {
 	defer tx.Rollback()
 
 	// 删除助手
-	res, err := tx.ExecContext(ctx, "DELETE FROM assistants WHERE id = ?", id)
+	query, args := ownedFilter(ctx, "DELETE FROM assistants WHERE id = ?", id)
+	res, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("删除助手失败: %w", err)
 	}
@@ -58,19 +93,33 @@ func (r *AssistantSQLiteRepo) DeleteByID(ctx context.Context, id string) error {
 		return errors.New("助手不存在")
 	}
 
-	// 提交事务（历史记录通过外键级联删除）
+	// 专属记忆没有外键，显式删除（历史记录通过外键级联删除）
+	if _, err := tx.ExecContext(ctx, "DELETE FROM memories WHERE assistant_id = ?", id); err != nil {
+		return fmt.Errorf("删除助手记忆失败: %w", err)
+	}
+
+	// 提交事务
 	return tx.Commit()
 }
 
 // Save 保存新助手
 func (r *AssistantSQLiteRepo) Save(ctx context.Context, a *model.Assistant) (*model.Assistant, error) {
+	defer metrics.ObserveQuery("assistant", "Save")()
+	ctx, span := tracing.Start(ctx, "sqlite.assistant.Save")
+	defer span.End()
+	sharedWith, modelParams, tools, err := marshalAssistantJSON(a)
+	if err != nil {
+		return nil, err
+	}
 	query := `
-	INSERT INTO assistants (id, name, description, prompt, gmt_create, gmt_modified, time_stamp)
-	VALUES (?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO assistants (id, name, description, prompt, gmt_create, gmt_modified, time_stamp, owner_id, visibility, shared_with, model_params, tools, source, revision)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = r.db.ExecContext(ctx, query,
 		a.ID, a.Name, a.Description, a.Prompt,
 		a.GmtCreate, a.GmtModified, a.TimeStamp,
+		a.OwnerID, a.Visibility, sharedWith,
+		modelParams, tools, a.Source, a.Revision,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("保存助手失败: %w", err)
@@ -78,17 +127,25 @@ func (r *AssistantSQLiteRepo) Save(ctx context.Context, a *model.Assistant) (*mo
 	return a, nil
 }
 
-// UpdateByID 按ID更新助手
+// UpdateByID 按ID更新助手（非管理员只能更新自己的助手）
 func (r *AssistantSQLiteRepo) UpdateByID(ctx context.Context, id string, a *model.Assistant) (*model.Assistant, error) {
-	query := `
-	UPDATE assistants SET name = ?, description = ?, prompt = ?, 
-	gmt_create = ?, gmt_modified = ?, time_stamp = ? 
+	defer metrics.ObserveQuery("assistant", "UpdateByID")()
+	ctx, span := tracing.Start(ctx, "sqlite.assistant.UpdateByID")
+	defer span.End()
+	sharedWith, modelParams, tools, err := marshalAssistantJSON(a)
+	if err != nil {
+		return nil, err
+	}
+	query, args := ownedFilter(ctx, `
+	UPDATE assistants SET name = ?, description = ?, prompt = ?,
+	gmt_create = ?, gmt_modified = ?, time_stamp = ?, visibility = ?, shared_with = ?,
+	model_params = ?, tools = ?, source = ?, revision = ?
 	WHERE id = ?
-	`
-	res, err := r.db.ExecContext(ctx, query,
-		a.Name, a.Description, a.Prompt,
-		a.GmtCreate, a.GmtModified, a.TimeStamp, id,
+	`, a.Name, a.Description, a.Prompt,
+		a.GmtCreate, a.GmtModified, a.TimeStamp, a.Visibility, sharedWith,
+		modelParams, tools, a.Source, a.Revision, id,
 	)
+	res, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("更新助手失败: %w", err)
 	}
@@ -97,3 +154,163 @@ func (r *AssistantSQLiteRepo) UpdateByID(ctx context.Context, id string, a *mode
 	}
 	return a, nil
 }
+
+// UpdateWithRevision 按版本号乐观更新助手，并在同一事务中追加版本记录（rev为nil时只更新助手）。
+// 助手不存在或版本号已不等于expected（被并发修改）时返回sql.ErrNoRows
+func (r *AssistantSQLiteRepo) UpdateWithRevision(ctx context.Context, a *model.Assistant, expected int, rev *model.AssistantRevision) error {
+	defer metrics.ObserveQuery("assistant", "UpdateWithRevision")()
+	ctx, span := tracing.Start(ctx, "sqlite.assistant.UpdateWithRevision")
+	defer span.End()
+	sharedWith, modelParams, tools, err := marshalAssistantJSON(a)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	query, args := ownedFilter(ctx, `
+	UPDATE assistants SET name = ?, description = ?, prompt = ?,
+	gmt_create = ?, gmt_modified = ?, time_stamp = ?, visibility = ?, shared_with = ?,
+	model_params = ?, tools = ?, source = ?, revision = ?
+	WHERE id = ? AND revision = ?
+	`, a.Name, a.Description, a.Prompt,
+		a.GmtCreate, a.GmtModified, a.TimeStamp, a.Visibility, sharedWith,
+		modelParams, tools, a.Source, a.Revision, a.ID, expected,
+	)
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("更新助手失败: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	if rev != nil {
+		revParams, revTools, err := marshalModelParams(rev.ModelParams, rev.Tools)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO assistant_revisions (assistant_id, revision, name, description, prompt, model_params, tools, author_id, note, gmt_create)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, rev.AssistantID, rev.Revision, rev.Name, rev.Description, rev.Prompt,
+			revParams, revTools, rev.AuthorID, rev.Note, rev.GmtCreate,
+		); err != nil {
+			return fmt.Errorf("保存助手版本失败: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SaveRevision 追加助手版本（同一版本号已存在时报错，版本不可修改）
+func (r *AssistantSQLiteRepo) SaveRevision(ctx context.Context, rev *model.AssistantRevision) error {
+	defer metrics.ObserveQuery("assistant", "SaveRevision")()
+	ctx, span := tracing.Start(ctx, "sqlite.assistant.SaveRevision")
+	defer span.End()
+	modelParams, tools, err := marshalModelParams(rev.ModelParams, rev.Tools)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+	INSERT INTO assistant_revisions (assistant_id, revision, name, description, prompt, model_params, tools, author_id, note, gmt_create)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, rev.AssistantID, rev.Revision, rev.Name, rev.Description, rev.Prompt,
+		modelParams, tools, rev.AuthorID, rev.Note, rev.GmtCreate,
+	)
+	if err != nil {
+		return fmt.Errorf("保存助手版本失败: %w", err)
+	}
+	return nil
+}
+
+// SelectRevisions 按版本号倒序查询助手的所有版本
+func (r *AssistantSQLiteRepo) SelectRevisions(ctx context.Context, assistantID string) ([]model.AssistantRevision, error) {
+	defer metrics.ObserveQuery("assistant", "SelectRevisions")()
+	ctx, span := tracing.Start(ctx, "sqlite.assistant.SelectRevisions")
+	defer span.End()
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT assistant_id, revision, name, description, prompt, model_params, tools, author_id, note, gmt_create
+	FROM assistant_revisions WHERE assistant_id = ? ORDER BY revision DESC
+	`, assistantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询助手版本失败: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []model.AssistantRevision{}
+	for rows.Next() {
+		var rev model.AssistantRevision
+		var modelParams, tools string
+		if err := rows.Scan(
+			&rev.AssistantID, &rev.Revision, &rev.Name, &rev.Description, &rev.Prompt,
+			&modelParams, &tools, &rev.AuthorID, &rev.Note, &rev.GmtCreate,
+		); err != nil {
+			return nil, fmt.Errorf("扫描助手版本失败: %w", err)
+		}
+		if err := unmarshalModelParams(modelParams, tools, &rev.ModelParams, &rev.Tools); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// ownedFilter 非管理员追加所有者条件
+func ownedFilter(ctx context.Context, query string, args ...any) (string, []any) {
+	if auth.IsAdmin(ctx) {
+		return query, args
+	}
+	return query + " AND owner_id = ?", append(args, auth.UserID(ctx))
+}
+
+func marshalSharedWith(ids []string) (string, error) {
+	if ids == nil {
+		ids = []string{}
+	}
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return "", fmt.Errorf("序列化共享用户失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// marshalAssistantJSON 序列化助手的JSON字段（共享用户、模型参数、工具列表）
+func marshalAssistantJSON(a *model.Assistant) (sharedWith, modelParams, tools string, err error) {
+	if sharedWith, err = marshalSharedWith(a.SharedWith); err != nil {
+		return "", "", "", err
+	}
+	if modelParams, tools, err = marshalModelParams(a.ModelParams, a.Tools); err != nil {
+		return "", "", "", err
+	}
+	return sharedWith, modelParams, tools, nil
+}
+
+func marshalModelParams(p model.ModelParams, toolNames []string) (modelParams, tools string, err error) {
+	params, err := json.Marshal(p)
+	if err != nil {
+		return "", "", fmt.Errorf("序列化模型参数失败: %w", err)
+	}
+	// nil保存为null（全部工具），空切片保存为[]（不使用工具）
+	toolList, err := json.Marshal(toolNames)
+	if err != nil {
+		return "", "", fmt.Errorf("序列化工具列表失败: %w", err)
+	}
+	return string(params), string(toolList), nil
+}
+
+func unmarshalModelParams(modelParams, tools string, p *model.ModelParams, toolNames *[]string) error {
+	if modelParams != "" {
+		if err := json.Unmarshal([]byte(modelParams), p); err != nil {
+			return fmt.Errorf("解析模型参数失败: %w", err)
+		}
+	}
+	if tools != "" {
+		if err := json.Unmarshal([]byte(tools), toolNames); err != nil {
+			return fmt.Errorf("解析工具列表失败: %w", err)
+		}
+	}
+	return nil
+}
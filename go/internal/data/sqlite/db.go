@@ -3,6 +3,7 @@ package sqlite
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -10,7 +11,12 @@ import (
 // InitDB 初始化数据库连接并创建表结构（返回数据库连接）
 func InitDB(dbPath string) (*sql.DB, error) {
 	// 1. 打开数据库连接（文件不存在会自动创建）
-	db, err := sql.Open("sqlite3", dbPath)
+	// 外键约束（SQLite默认禁用）通过DSN为连接池中的每个连接启用，级联删除依赖于此
+	dsn := dbPath + "?_foreign_keys=on"
+	if strings.Contains(dbPath, "?") {
+		dsn = dbPath + "&_foreign_keys=on"
+	}
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("打开数据库失败: %w", err)
 	}
@@ -20,11 +26,6 @@ func InitDB(dbPath string) (*sql.DB, error) {
 		return nil, fmt.Errorf("数据库连接失败: %w", err)
 	}
 
-	// 启用外键约束（SQLite默认禁用）
-	if _, err := db.Exec("PRAGMA foreign_keys = ON;"); err != nil {
-		return nil, fmt.Errorf("启用外键约束失败: %w", err)
-	}
-
 	// 3. 创建必要的表结构
 	if err := createTables(db); err != nil {
 		return nil, fmt.Errorf("创建表结构失败: %w", err)
@@ -44,22 +45,288 @@ func createTables(db *sql.DB) error {
 		prompt TEXT,                           -- 提示词
 		gmt_create TEXT,                       -- 创建时间
 		gmt_modified TEXT,                     -- 修改时间
-		time_stamp TEXT                        -- 时间戳
+		time_stamp TEXT,                       -- 时间戳
+		owner_id TEXT DEFAULT '',              -- 所有者用户ID
+		visibility TEXT DEFAULT 'private',     -- 可见性：private/shared/public
+		shared_with TEXT DEFAULT '[]',         -- 共享用户ID（JSON数组）
+		model_params TEXT DEFAULT '{}',        -- 模型参数（JSON对象）
+		tools TEXT DEFAULT 'null',             -- 可用工具（JSON数组，null为全部）
+		source TEXT DEFAULT '',                -- 声明该助手的YAML文件（非空时只读）
+		revision INTEGER DEFAULT 0             -- 当前版本号
 	);`
 	if _, err := db.Exec(assistantTableSQL); err != nil {
 		return fmt.Errorf("创建assistants表失败: %w", err)
 	}
 
-	// 历史记录表（与助手关联，级联删除）
+	// 历史记录表（按助手+用户区分会话，级联删除）
 	historyTableSQL := `
 	CREATE TABLE IF NOT EXISTS histories (
-		assistant_id TEXT PRIMARY KEY,  -- 直接用助手ID作为主键
+		assistant_id TEXT NOT NULL,     -- 助手ID
+		user_id TEXT NOT NULL DEFAULT '', -- 用户ID（系统身份为空）
 		messages TEXT NOT NULL,         -- JSON格式的消息列表
+		PRIMARY KEY(assistant_id, user_id),
 		FOREIGN KEY(assistant_id) REFERENCES assistants(id) ON DELETE CASCADE
 	);`
 	if _, err := db.Exec(historyTableSQL); err != nil {
 		return fmt.Errorf("创建histories表失败: %w", err)
 	}
 
+	// 助手版本表（只追加，随助手级联删除）
+	revisionTableSQL := `
+	CREATE TABLE IF NOT EXISTS assistant_revisions (
+		assistant_id TEXT NOT NULL,            -- 助手ID
+		revision INTEGER NOT NULL,             -- 版本号（从1开始）
+		name TEXT,                             -- 助手名称
+		description TEXT,                      -- 助手描述
+		prompt TEXT,                           -- 提示词
+		model_params TEXT DEFAULT '{}',        -- 模型参数（JSON对象）
+		tools TEXT DEFAULT 'null',             -- 可用工具（JSON数组，null为全部）
+		author_id TEXT DEFAULT '',             -- 产生该版本的用户ID
+		note TEXT DEFAULT '',                  -- 版本说明
+		gmt_create TEXT,                       -- 创建时间
+		PRIMARY KEY(assistant_id, revision),
+		FOREIGN KEY(assistant_id) REFERENCES assistants(id) ON DELETE CASCADE
+	);`
+	if _, err := db.Exec(revisionTableSQL); err != nil {
+		return fmt.Errorf("创建assistant_revisions表失败: %w", err)
+	}
+
+	// 会话变量表（提示词模板中的会话标题与自定义变量，重置对话后保留）
+	conversationVarsTableSQL := `
+	CREATE TABLE IF NOT EXISTS conversation_vars (
+		assistant_id TEXT NOT NULL,            -- 助手ID
+		user_id TEXT NOT NULL DEFAULT '',      -- 用户ID（系统身份为空）
+		title TEXT DEFAULT '',                 -- 会话标题
+		variables TEXT DEFAULT '{}',           -- 自定义变量（JSON对象）
+		gmt_modified TEXT,                     -- 修改时间
+		PRIMARY KEY(assistant_id, user_id),
+		FOREIGN KEY(assistant_id) REFERENCES assistants(id) ON DELETE CASCADE
+	);`
+	if _, err := db.Exec(conversationVarsTableSQL); err != nil {
+		return fmt.Errorf("创建conversation_vars表失败: %w", err)
+	}
+
+	// 知识库文档表与片段表（原文不保存，片段随文档、文档随助手级联删除）
+	knowledgeTableSQL := `
+	CREATE TABLE IF NOT EXISTS knowledge_documents (
+		id TEXT PRIMARY KEY,                   -- 文档唯一标识
+		assistant_id TEXT NOT NULL,            -- 所属助手ID
+		title TEXT,                            -- 文档标题
+		filename TEXT,                         -- 上传的文件名
+		content_type TEXT,                     -- 文档类型：markdown/text/html/pdf
+		size INTEGER DEFAULT 0,                -- 原文件字节数
+		chunks INTEGER DEFAULT 0,              -- 片段数量
+		embedding_model TEXT DEFAULT '',       -- 生成向量的模型
+		uploader_id TEXT DEFAULT '',           -- 上传者用户ID
+		gmt_create TEXT,                       -- 上传时间
+		FOREIGN KEY(assistant_id) REFERENCES assistants(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_knowledge_documents_assistant ON knowledge_documents(assistant_id);
+	CREATE TABLE IF NOT EXISTS knowledge_chunks (
+		document_id TEXT NOT NULL,             -- 文档ID
+		seq INTEGER NOT NULL,                  -- 片段序号
+		assistant_id TEXT NOT NULL,            -- 所属助手ID（检索时按助手过滤）
+		content TEXT NOT NULL,                 -- 片段文本
+		embedding BLOB NOT NULL,               -- 向量（小端float32）
+		PRIMARY KEY(document_id, seq),
+		FOREIGN KEY(document_id) REFERENCES knowledge_documents(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_assistant ON knowledge_chunks(assistant_id);`
+	if _, err := db.Exec(knowledgeTableSQL); err != nil {
+		return fmt.Errorf("创建知识库表失败: %w", err)
+	}
+
+	// 长期记忆表（按用户保存；共享记忆的assistant_id为空，因此不设外键，删除助手时显式删除其专属记忆）
+	memoryTableSQL := `
+	CREATE TABLE IF NOT EXISTS memories (
+		id TEXT PRIMARY KEY,                   -- 记忆唯一标识
+		user_id TEXT NOT NULL DEFAULT '',      -- 用户ID（系统身份为空）
+		assistant_id TEXT NOT NULL DEFAULT '', -- 专属助手ID（为空时对该用户的所有助手生效）
+		content TEXT NOT NULL,                 -- 记忆内容
+		source TEXT DEFAULT '',                -- 来源：manual/tool/extracted
+		embedding_model TEXT DEFAULT '',       -- 生成向量的模型（未启用文本向量时为空）
+		embedding BLOB,                        -- 向量（小端float32）
+		gmt_create TEXT,                       -- 创建时间
+		gmt_modified TEXT                      -- 修改时间
+	);
+	CREATE INDEX IF NOT EXISTS idx_memories_user ON memories(user_id, assistant_id);`
+	if _, err := db.Exec(memoryTableSQL); err != nil {
+		return fmt.Errorf("创建memories表失败: %w", err)
+	}
+
+	// API密钥表（仅保存哈希，不保存明文）
+	apiKeyTableSQL := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id TEXT PRIMARY KEY,                   -- 密钥唯一标识
+		user_id TEXT DEFAULT '',               -- 所属用户ID（空表示系统密钥）
+		name TEXT,                             -- 密钥名称
+		key_hash TEXT NOT NULL UNIQUE,         -- 密钥SHA-256哈希
+		prefix TEXT,                           -- 密钥前缀（便于识别）
+		scope TEXT NOT NULL,                   -- 权限范围：read/chat/admin
+		assistant_ids TEXT,                    -- 允许访问的助手ID（JSON数组，空表示全部）
+		gmt_create TEXT,                       -- 创建时间
+		gmt_last_used TEXT                     -- 最近使用时间
+	);`
+	if _, err := db.Exec(apiKeyTableSQL); err != nil {
+		return fmt.Errorf("创建api_keys表失败: %w", err)
+	}
+
+	// 用户表
+	userTableSQL := `
+	CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,                   -- 用户唯一标识
+		username TEXT NOT NULL UNIQUE,         -- 登录名
+		password_hash TEXT NOT NULL,           -- bcrypt密码哈希
+		display_name TEXT,                     -- 显示名称
+		role TEXT NOT NULL,                    -- 角色：user/admin
+		gmt_create TEXT                        -- 创建时间
+	);`
+	if _, err := db.Exec(userTableSQL); err != nil {
+		return fmt.Errorf("创建users表失败: %w", err)
+	}
+
+	// 会话表（仅保存令牌哈希，随用户级联删除）
+	sessionTableSQL := `
+	CREATE TABLE IF NOT EXISTS sessions (
+		token_hash TEXT PRIMARY KEY,           -- 会话令牌SHA-256哈希
+		user_id TEXT NOT NULL,                 -- 用户ID
+		expires_at TEXT NOT NULL,              -- 过期时间
+		gmt_create TEXT,                       -- 创建时间
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+	if _, err := db.Exec(sessionTableSQL); err != nil {
+		return fmt.Errorf("创建sessions表失败: %w", err)
+	}
+
+	// 用量记录表（用于配额统计，不随助手删除）
+	usageTableSQL := `
+	CREATE TABLE IF NOT EXISTS usage_records (
+		id TEXT PRIMARY KEY,                   -- 记录唯一标识
+		user_id TEXT NOT NULL DEFAULT '',      -- 用户ID（系统身份为空）
+		assistant_id TEXT NOT NULL,            -- 助手ID
+		model TEXT,                            -- 模型名称
+		input_tokens INTEGER NOT NULL,         -- 输入token数
+		output_tokens INTEGER NOT NULL,        -- 输出token数
+		total_tokens INTEGER NOT NULL,         -- 总token数
+		cost REAL NOT NULL DEFAULT 0,          -- 费用
+		gmt_create TEXT NOT NULL               -- 创建时间
+	);
+	CREATE INDEX IF NOT EXISTS idx_usage_user ON usage_records(user_id, gmt_create);
+	CREATE INDEX IF NOT EXISTS idx_usage_assistant ON usage_records(assistant_id, gmt_create);`
+	if _, err := db.Exec(usageTableSQL); err != nil {
+		return fmt.Errorf("创建usage_records表失败: %w", err)
+	}
+
+	return migrate(db)
+}
+
+// migrate 为旧版本数据库补齐字段（已是最新结构时不做任何修改）
+func migrate(db *sql.DB) error {
+	// 旧助手没有所有者，设为public保证升级后仍可访问
+	assistantColumns := []struct{ name, ddl string }{
+		{"owner_id", "ALTER TABLE assistants ADD COLUMN owner_id TEXT DEFAULT ''"},
+		{"visibility", "ALTER TABLE assistants ADD COLUMN visibility TEXT DEFAULT 'public'"},
+		{"shared_with", "ALTER TABLE assistants ADD COLUMN shared_with TEXT DEFAULT '[]'"},
+		{"model_params", "ALTER TABLE assistants ADD COLUMN model_params TEXT DEFAULT '{}'"},
+		{"tools", "ALTER TABLE assistants ADD COLUMN tools TEXT DEFAULT 'null'"},
+		{"source", "ALTER TABLE assistants ADD COLUMN source TEXT DEFAULT ''"},
+		{"revision", "ALTER TABLE assistants ADD COLUMN revision INTEGER DEFAULT 0"},
+	}
+	for _, col := range assistantColumns {
+		if err := addColumnIfMissing(db, "assistants", col.name, col.ddl); err != nil {
+			return err
+		}
+	}
+
+	// 没有版本记录的旧助手以当前内容作为版本1
+	if _, err := db.Exec(`
+	INSERT OR IGNORE INTO assistant_revisions (assistant_id, revision, name, description, prompt, model_params, tools, author_id, note, gmt_create)
+	SELECT id, 1, name, description, prompt, model_params, tools, owner_id, '初始版本', gmt_modified FROM assistants WHERE revision = 0;
+	UPDATE assistants SET revision = 1 WHERE revision = 0;`); err != nil {
+		return fmt.Errorf("初始化助手版本失败: %w", err)
+	}
+
+	if err := migrateVectors(db); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(db, "api_keys", "user_id", "ALTER TABLE api_keys ADD COLUMN user_id TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// 旧历史表以assistant_id为主键，需重建为(assistant_id, user_id)复合主键
+	exists, err := hasColumn(db, "histories", "user_id")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("开启迁移事务失败: %w", err)
+		}
+		defer tx.Rollback()
+
+		stmts := []string{
+			"ALTER TABLE histories RENAME TO histories_old",
+			`CREATE TABLE histories (
+				assistant_id TEXT NOT NULL,
+				user_id TEXT NOT NULL DEFAULT '',
+				messages TEXT NOT NULL,
+				PRIMARY KEY(assistant_id, user_id),
+				FOREIGN KEY(assistant_id) REFERENCES assistants(id) ON DELETE CASCADE
+			)`,
+			// 旧库未启用外键时可能残留孤儿记录，迁移时丢弃
+			"INSERT INTO histories (assistant_id, user_id, messages) SELECT assistant_id, '', messages FROM histories_old WHERE assistant_id IN (SELECT id FROM assistants)",
+			"DROP TABLE histories_old",
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("迁移histories表失败: %w", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("提交迁移事务失败: %w", err)
+		}
+	}
+
 	return nil
 }
+
+// addColumnIfMissing 字段不存在时执行ALTER TABLE
+func addColumnIfMissing(db *sql.DB, table, column, ddl string) error {
+	exists, err := hasColumn(db, table, column)
+	if err != nil || exists {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("为%s表添加%s字段失败: %w", table, column, err)
+	}
+	return nil
+}
+
+// hasColumn 判断表中是否存在指定字段
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("查询%s表结构失败: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, fmt.Errorf("扫描%s表结构失败: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
@@ -1,13 +1,16 @@
 package sqlite
 
 import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/metrics"
 	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/tracing"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 )
 
 // HistorySQLiteRepo 实现HistoryRepo接口
@@ -20,10 +23,15 @@ func NewHistorySQLiteRepo(db *sql.DB) *HistorySQLiteRepo {
 	return &HistorySQLiteRepo{db: db}
 }
 
+// SelectByAssistantID 查询当前用户与助手的会话历史
 func (r *HistorySQLiteRepo) SelectByAssistantID(ctx context.Context, aid string) (*model.History, error) {
-	query := "SELECT messages FROM histories WHERE assistant_id = ?"
+	defer metrics.ObserveQuery("history", "SelectByAssistantID")()
+	ctx, span := tracing.Start(ctx, "sqlite.history.SelectByAssistantID")
+	defer span.End()
+	query := "SELECT messages FROM histories WHERE assistant_id = ? AND user_id = ?"
+	uid := auth.UserID(ctx)
 	var messagesJSON string
-	err := r.db.QueryRowContext(ctx, query, aid).Scan(&messagesJSON)
+	err := r.db.QueryRowContext(ctx, query, aid, uid).Scan(&messagesJSON)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, err // 使用包级错误变量
@@ -40,92 +48,112 @@ func (r *HistorySQLiteRepo) SelectByAssistantID(ctx context.Context, aid string)
 
 	return &model.History{
 		AssistantID: aid,
+		UserID:      uid,
 		Messages:    messages, // 正确赋值切片
 	}, nil
 }
 
-// DeleteByAssistantID 按助手ID删除历史（核心修改：不存在时不报错）
+// DeleteByAssistantID 按助手ID删除当前用户的历史（核心修改：不存在时不报错）
 func (r *HistorySQLiteRepo) DeleteByAssistantID(ctx context.Context, aid string) error {
-	log.Printf("[SQLite] 删除助手 %s 的所有历史消息", aid)
-
-	_, err := r.db.ExecContext(ctx, "DELETE FROM histories WHERE assistant_id = ?", aid)
+	defer metrics.ObserveQuery("history", "DeleteByAssistantID")()
+	ctx, span := tracing.Start(ctx, "sqlite.history.DeleteByAssistantID")
+	defer span.End()
+	_, err := r.db.ExecContext(ctx, "DELETE FROM histories WHERE assistant_id = ? AND user_id = ?", aid, auth.UserID(ctx))
 	if err != nil {
-		log.Printf("[SQLite] 删除历史失败: %v", err)
 		return fmt.Errorf("删除历史失败: %w", err)
 	}
 
-	log.Printf("[SQLite] 成功删除助手 %s 的历史记录", aid)
+	slog.DebugContext(ctx, "已删除历史记录", "assistant_id", aid)
 	return nil
 }
 
-// SaveByAssistantID 追加消息到历史（恢复原设计）
+// SaveByAssistantID 追加消息到历史（单条upsert语句完成，避免并发读改写互相覆盖）
 func (r *HistorySQLiteRepo) SaveByAssistantID(ctx context.Context, aid string, msg model.Message) error {
-	log.Printf("[SQLite] 开始保存助手 %s 的新消息", aid)
-
-	// 1. 查询现有历史
-	history, err := r.SelectByAssistantID(ctx, aid)
-	var messages []model.Message
-
-	if err == nil {
-		messages = history.Messages
-		log.Printf("[SQLite] 找到助手 %s 的历史记录，共 %d 条消息", aid, len(messages))
-	} else if errors.Is(err, sql.ErrNoRows) {
-		log.Printf("[SQLite] 助手 %s 无历史记录，将创建新记录", aid)
-		messages = []model.Message{}
-	} else {
-		log.Printf("[SQLite] 查询历史记录失败: %v", err)
-		return fmt.Errorf("查询历史失败: %w", err)
-	}
-
-	// 2. 追加新消息并序列化完整历史
-	messages = append(messages, msg)
-	messagesJSON, err := json.Marshal(messages)
+	defer metrics.ObserveQuery("history", "SaveByAssistantID")()
+	ctx, span := tracing.Start(ctx, "sqlite.history.SaveByAssistantID")
+	defer span.End()
+	msgJSON, err := json.Marshal(msg)
 	if err != nil {
-		log.Printf("[SQLite] 序列化消息失败: %v", err)
 		return fmt.Errorf("序列化消息失败: %w", err)
 	}
 
-	// 3. 更新或插入完整历史
-	if history != nil {
-		// 更新现有记录
-		_, err = r.db.ExecContext(ctx,
-			"UPDATE histories SET messages = ? WHERE assistant_id = ?",
-			messagesJSON, aid)
-	} else {
-		// 插入新记录
-		_, err = r.db.ExecContext(ctx,
-			"INSERT INTO histories (assistant_id, messages) VALUES (?, ?)",
-			aid, messagesJSON)
-	}
-
+	// 旧数据可能以BLOB存储，先转为TEXT再按JSON追加到数组末尾
+	_, err = r.db.ExecContext(ctx, `
+	INSERT INTO histories (assistant_id, user_id, messages) VALUES (?, ?, json_array(json(?)))
+	ON CONFLICT(assistant_id, user_id) DO UPDATE SET messages = json_insert(CAST(messages AS TEXT), '$[#]', json(?))
+	`, aid, auth.UserID(ctx), string(msgJSON), string(msgJSON))
 	if err != nil {
-		log.Printf("[SQLite] 保存历史记录失败: %v", err)
 		return fmt.Errorf("保存历史失败: %w", err)
 	}
 
-	log.Printf("[SQLite] 成功保存助手 %s 的新消息", aid)
+	slog.DebugContext(ctx, "已保存历史消息", "assistant_id", aid)
 	return nil
 }
 
 // UpdateAssistantTimestamp 更新助手时间戳
 func (r *HistorySQLiteRepo) UpdateAssistantTimestamp(ctx context.Context, aid, timestamp string) error {
-	log.Printf("[SQLite] 更新助手 %s 的时间戳为: %s", aid, timestamp)
-
+	defer metrics.ObserveQuery("history", "UpdateAssistantTimestamp")()
+	ctx, span := tracing.Start(ctx, "sqlite.history.UpdateAssistantTimestamp")
+	defer span.End()
 	res, err := r.db.ExecContext(ctx,
 		"UPDATE assistants SET time_stamp = ? WHERE id = ?",
 		timestamp, aid,
 	)
 
 	if err != nil {
-		log.Printf("[SQLite] 更新时间戳失败: %v", err)
 		return fmt.Errorf("更新时间戳失败: %w", err)
 	}
 
 	if rows, _ := res.RowsAffected(); rows == 0 {
-		log.Printf("[SQLite] 更新时间戳失败: 未找到助手 %s", aid)
 		return errors.New("助手不存在")
 	}
 
-	log.Printf("[SQLite] 成功更新助手 %s 的时间戳", aid)
+	slog.DebugContext(ctx, "已更新助手时间戳", "assistant_id", aid, "time_stamp", timestamp)
+	return nil
+}
+
+// SelectVars 查询当前用户与助手的会话变量
+func (r *HistorySQLiteRepo) SelectVars(ctx context.Context, aid string) (*model.ConversationVars, error) {
+	defer metrics.ObserveQuery("history", "SelectVars")()
+	ctx, span := tracing.Start(ctx, "sqlite.history.SelectVars")
+	defer span.End()
+	var vars model.ConversationVars
+	var variables string
+	err := r.db.QueryRowContext(ctx,
+		"SELECT title, variables, gmt_modified FROM conversation_vars WHERE assistant_id = ? AND user_id = ?",
+		aid, auth.UserID(ctx),
+	).Scan(&vars.Title, &variables, &vars.GmtModified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("查询会话变量失败: %w", err)
+	}
+	if err := json.Unmarshal([]byte(variables), &vars.Variables); err != nil {
+		return nil, fmt.Errorf("解析会话变量失败: %w", err)
+	}
+	return &vars, nil
+}
+
+// SaveVars 保存当前用户与助手的会话变量（整体覆盖）
+func (r *HistorySQLiteRepo) SaveVars(ctx context.Context, aid string, vars *model.ConversationVars) error {
+	defer metrics.ObserveQuery("history", "SaveVars")()
+	ctx, span := tracing.Start(ctx, "sqlite.history.SaveVars")
+	defer span.End()
+	variables := vars.Variables
+	if variables == nil {
+		variables = map[string]string{}
+	}
+	data, err := json.Marshal(variables)
+	if err != nil {
+		return fmt.Errorf("序列化会话变量失败: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx, `
+	INSERT INTO conversation_vars (assistant_id, user_id, title, variables, gmt_modified) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT(assistant_id, user_id) DO UPDATE SET title = excluded.title, variables = excluded.variables, gmt_modified = excluded.gmt_modified
+	`, aid, auth.UserID(ctx), vars.Title, string(data), vars.GmtModified)
+	if err != nil {
+		return fmt.Errorf("保存会话变量失败: %w", err)
+	}
 	return nil
 }
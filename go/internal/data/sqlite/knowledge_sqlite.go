@@ -0,0 +1,137 @@
+package sqlite
+
+import (
+	"Voice_Assistant/internal/metrics"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/tracing"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// KnowledgeSQLiteRepo 实现KnowledgeRepo接口
+type KnowledgeSQLiteRepo struct {
+	db *sql.DB
+}
+
+// NewKnowledgeSQLiteRepo 创建实例
+func NewKnowledgeSQLiteRepo(db *sql.DB) *KnowledgeSQLiteRepo {
+	return &KnowledgeSQLiteRepo{db: db}
+}
+
+// SaveDocument 在一个事务中保存文档及其全部片段
+func (r *KnowledgeSQLiteRepo) SaveDocument(ctx context.Context, doc *model.KnowledgeDocument, chunks []model.KnowledgeChunk) error {
+	defer metrics.ObserveQuery("knowledge", "SaveDocument")()
+	ctx, span := tracing.Start(ctx, "sqlite.knowledge.SaveDocument")
+	defer span.End()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+	INSERT INTO knowledge_documents (id, assistant_id, title, filename, content_type, size, chunks, embedding_model, uploader_id, gmt_create)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, doc.ID, doc.AssistantID, doc.Title, doc.Filename, doc.ContentType,
+		doc.Size, doc.Chunks, doc.EmbeddingModel, doc.UploaderID, doc.GmtCreate,
+	); err != nil {
+		return fmt.Errorf("保存文档失败: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx,
+		"INSERT INTO knowledge_chunks (document_id, seq, assistant_id, content, embedding) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("准备写入片段失败: %w", err)
+	}
+	defer stmt.Close()
+	for _, c := range chunks {
+		if _, err := stmt.ExecContext(ctx, doc.ID, c.Seq, doc.AssistantID, c.Content, encodeVector(c.Embedding)); err != nil {
+			return fmt.Errorf("保存片段失败: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SelectDocuments 查询助手知识库的文档（按上传时间倒序）
+func (r *KnowledgeSQLiteRepo) SelectDocuments(ctx context.Context, assistantID string) ([]model.KnowledgeDocument, error) {
+	defer metrics.ObserveQuery("knowledge", "SelectDocuments")()
+	ctx, span := tracing.Start(ctx, "sqlite.knowledge.SelectDocuments")
+	defer span.End()
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT id, assistant_id, title, filename, content_type, size, chunks, embedding_model, uploader_id, gmt_create
+	FROM knowledge_documents WHERE assistant_id = ? ORDER BY gmt_create DESC, id
+	`, assistantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询文档失败: %w", err)
+	}
+	defer rows.Close()
+
+	docs := []model.KnowledgeDocument{}
+	for rows.Next() {
+		var d model.KnowledgeDocument
+		if err := rows.Scan(&d.ID, &d.AssistantID, &d.Title, &d.Filename, &d.ContentType,
+			&d.Size, &d.Chunks, &d.EmbeddingModel, &d.UploaderID, &d.GmtCreate); err != nil {
+			return nil, fmt.Errorf("扫描文档失败: %w", err)
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// DeleteDocument 删除文档（片段随外键级联删除；文档不存在时返回sql.ErrNoRows）
+func (r *KnowledgeSQLiteRepo) DeleteDocument(ctx context.Context, assistantID, documentID string) error {
+	defer metrics.ObserveQuery("knowledge", "DeleteDocument")()
+	ctx, span := tracing.Start(ctx, "sqlite.knowledge.DeleteDocument")
+	defer span.End()
+	res, err := r.db.ExecContext(ctx, "DELETE FROM knowledge_documents WHERE id = ? AND assistant_id = ?", documentID, assistantID)
+	if err != nil {
+		return fmt.Errorf("删除文档失败: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SelectChunks 查询助手知识库的全部片段（含向量）
+func (r *KnowledgeSQLiteRepo) SelectChunks(ctx context.Context, assistantID string) ([]model.KnowledgeChunk, error) {
+	defer metrics.ObserveQuery("knowledge", "SelectChunks")()
+	ctx, span := tracing.Start(ctx, "sqlite.knowledge.SelectChunks")
+	defer span.End()
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT c.document_id, c.seq, d.title, d.embedding_model, c.content, c.embedding
+	FROM knowledge_chunks c JOIN knowledge_documents d ON d.id = c.document_id
+	WHERE c.assistant_id = ?
+	`, assistantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询片段失败: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []model.KnowledgeChunk
+	for rows.Next() {
+		var c model.KnowledgeChunk
+		var embedding []byte
+		if err := rows.Scan(&c.DocumentID, &c.Seq, &c.Title, &c.EmbeddingModel, &c.Content, &embedding); err != nil {
+			return nil, fmt.Errorf("扫描片段失败: %w", err)
+		}
+		if c.Embedding, err = decodeVector(embedding); err != nil {
+			return nil, fmt.Errorf("解析文档%s第%d段的向量失败: %w", c.DocumentID, c.Seq, err)
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+// CountDocuments 助手知识库的文档数量
+func (r *KnowledgeSQLiteRepo) CountDocuments(ctx context.Context, assistantID string) (int, error) {
+	defer metrics.ObserveQuery("knowledge", "CountDocuments")()
+	ctx, span := tracing.Start(ctx, "sqlite.knowledge.CountDocuments")
+	defer span.End()
+	var n int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM knowledge_documents WHERE assistant_id = ?", assistantID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("查询文档数量失败: %w", err)
+	}
+	return n, nil
+}
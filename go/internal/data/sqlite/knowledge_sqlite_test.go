@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"Voice_Assistant/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestDeleteDocumentCascadesChunks(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	if _, err := NewAssistantSQLiteRepo(db).Save(ctx, &model.Assistant{ID: "a1", Name: "助手"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	repo := NewKnowledgeSQLiteRepo(db)
+	chunks := []model.KnowledgeChunk{{Seq: 0, Content: "一", Embedding: []float32{1}}, {Seq: 1, Content: "二", Embedding: []float32{0}}}
+	for _, id := range []string{"d1", "d2"} {
+		if err := repo.SaveDocument(ctx, &model.KnowledgeDocument{ID: id, AssistantID: "a1"}, chunks); err != nil {
+			t.Fatalf("SaveDocument: %v", err)
+		}
+	}
+
+	if err := repo.DeleteDocument(ctx, "a1", "d1"); err != nil {
+		t.Fatalf("DeleteDocument: %v", err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM knowledge_chunks WHERE document_id = 'd1'").Scan(&n); err != nil || n != 0 {
+		t.Errorf("已删除文档的片段数 = %d, err = %v, want 0", n, err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM knowledge_chunks WHERE document_id = 'd2'").Scan(&n); err != nil || n != 2 {
+		t.Errorf("其他文档的片段数 = %d, err = %v, want 2", n, err)
+	}
+
+	// 不存在或不属于该助手的文档
+	if err := repo.DeleteDocument(ctx, "a1", "d1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("重复删除 err = %v, want sql.ErrNoRows", err)
+	}
+	if err := repo.DeleteDocument(ctx, "other", "d2"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("删除其他助手的文档 err = %v, want sql.ErrNoRows", err)
+	}
+}
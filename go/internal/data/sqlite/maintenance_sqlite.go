@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"Voice_Assistant/internal/metrics"
+	"Voice_Assistant/internal/tracing"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+// MaintenanceSQLiteRepo 实现MaintenanceRepo接口
+type MaintenanceSQLiteRepo struct {
+	db *sql.DB
+}
+
+// NewMaintenanceSQLiteRepo 创建实例
+func NewMaintenanceSQLiteRepo(db *sql.DB) *MaintenanceSQLiteRepo {
+	return &MaintenanceSQLiteRepo{db: db}
+}
+
+// Backup 在线备份数据库到指定文件（VACUUM INTO得到一致的快照，服务运行中也可执行）
+func (r *MaintenanceSQLiteRepo) Backup(ctx context.Context, path string) error {
+	defer metrics.ObserveQuery("maintenance", "Backup")()
+	ctx, span := tracing.Start(ctx, "sqlite.maintenance.Backup")
+	defer span.End()
+	// VACUUM INTO要求目标文件不存在
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("备份文件已存在: %s", path)
+	}
+	if _, err := r.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("备份数据库失败: %w", err)
+	}
+	return nil
+}
+
+// Vacuum 整理数据库文件，回收已删除数据占用的空间
+func (r *MaintenanceSQLiteRepo) Vacuum(ctx context.Context) error {
+	defer metrics.ObserveQuery("maintenance", "Vacuum")()
+	ctx, span := tracing.Start(ctx, "sqlite.maintenance.Vacuum")
+	defer span.End()
+	if _, err := r.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("整理数据库失败: %w", err)
+	}
+	return nil
+}
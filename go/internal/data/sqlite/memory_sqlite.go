@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"Voice_Assistant/internal/metrics"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/tracing"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// MemorySQLiteRepo 实现MemoryRepo接口
+type MemorySQLiteRepo struct {
+	db *sql.DB
+}
+
+// NewMemorySQLiteRepo 创建实例
+func NewMemorySQLiteRepo(db *sql.DB) *MemorySQLiteRepo {
+	return &MemorySQLiteRepo{db: db}
+}
+
+const memoryColumns = "id, user_id, assistant_id, content, source, embedding_model, embedding, gmt_create, gmt_modified"
+
+// Save 保存新记忆
+func (r *MemorySQLiteRepo) Save(ctx context.Context, m *model.Memory) error {
+	defer metrics.ObserveQuery("memory", "Save")()
+	ctx, span := tracing.Start(ctx, "sqlite.memory.Save")
+	defer span.End()
+	if _, err := r.db.ExecContext(ctx,
+		"INSERT INTO memories ("+memoryColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		m.ID, m.UserID, m.AssistantID, m.Content, m.Source, m.EmbeddingModel, encodeVector(m.Embedding), m.GmtCreate, m.GmtModified,
+	); err != nil {
+		return fmt.Errorf("保存记忆失败: %w", err)
+	}
+	return nil
+}
+
+// Update 修改记忆内容、适用助手与向量（记忆不存在时返回sql.ErrNoRows）
+func (r *MemorySQLiteRepo) Update(ctx context.Context, m *model.Memory) error {
+	defer metrics.ObserveQuery("memory", "Update")()
+	ctx, span := tracing.Start(ctx, "sqlite.memory.Update")
+	defer span.End()
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE memories SET assistant_id = ?, content = ?, source = ?, embedding_model = ?, embedding = ?, gmt_modified = ?
+	WHERE id = ? AND user_id = ?
+	`, m.AssistantID, m.Content, m.Source, m.EmbeddingModel, encodeVector(m.Embedding), m.GmtModified, m.ID, m.UserID)
+	if err != nil {
+		return fmt.Errorf("修改记忆失败: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateEmbedding 仅更新向量（不改变修改时间）
+func (r *MemorySQLiteRepo) UpdateEmbedding(ctx context.Context, m *model.Memory) error {
+	defer metrics.ObserveQuery("memory", "UpdateEmbedding")()
+	ctx, span := tracing.Start(ctx, "sqlite.memory.UpdateEmbedding")
+	defer span.End()
+	if _, err := r.db.ExecContext(ctx,
+		"UPDATE memories SET embedding_model = ?, embedding = ? WHERE id = ? AND user_id = ?",
+		m.EmbeddingModel, encodeVector(m.Embedding), m.ID, m.UserID,
+	); err != nil {
+		return fmt.Errorf("更新记忆向量失败: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除记忆（记忆不存在时返回sql.ErrNoRows）
+func (r *MemorySQLiteRepo) Delete(ctx context.Context, userID, id string) error {
+	defer metrics.ObserveQuery("memory", "Delete")()
+	ctx, span := tracing.Start(ctx, "sqlite.memory.Delete")
+	defer span.End()
+	res, err := r.db.ExecContext(ctx, "DELETE FROM memories WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return fmt.Errorf("删除记忆失败: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteAll 清空用户的记忆（assistantID非空时只删除该助手专属的记忆）
+func (r *MemorySQLiteRepo) DeleteAll(ctx context.Context, userID, assistantID string) (int, error) {
+	defer metrics.ObserveQuery("memory", "DeleteAll")()
+	ctx, span := tracing.Start(ctx, "sqlite.memory.DeleteAll")
+	defer span.End()
+	query, args := "DELETE FROM memories WHERE user_id = ?", []any{userID}
+	if assistantID != "" {
+		query += " AND assistant_id = ?"
+		args = append(args, assistantID)
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("删除记忆失败: %w", err)
+	}
+	rows, _ := res.RowsAffected()
+	return int(rows), nil
+}
+
+// SelectByID 按ID查询用户的记忆（不存在时返回sql.ErrNoRows）
+func (r *MemorySQLiteRepo) SelectByID(ctx context.Context, userID, id string) (*model.Memory, error) {
+	defer metrics.ObserveQuery("memory", "SelectByID")()
+	ctx, span := tracing.Start(ctx, "sqlite.memory.SelectByID")
+	defer span.End()
+	row := r.db.QueryRowContext(ctx, "SELECT "+memoryColumns+" FROM memories WHERE id = ? AND user_id = ?", id, userID)
+	return scanMemory(row)
+}
+
+// SelectByUser 查询用户的记忆（按修改时间倒序）
+func (r *MemorySQLiteRepo) SelectByUser(ctx context.Context, userID, assistantID string) ([]model.Memory, error) {
+	defer metrics.ObserveQuery("memory", "SelectByUser")()
+	ctx, span := tracing.Start(ctx, "sqlite.memory.SelectByUser")
+	defer span.End()
+	query, args := "SELECT "+memoryColumns+" FROM memories WHERE user_id = ?", []any{userID}
+	if assistantID != "" {
+		query += " AND assistant_id IN ('', ?)"
+		args = append(args, assistantID)
+	}
+	rows, err := r.db.QueryContext(ctx, query+" ORDER BY gmt_modified DESC, id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询记忆失败: %w", err)
+	}
+	defer rows.Close()
+
+	memories := []model.Memory{}
+	for rows.Next() {
+		m, err := scanMemory(rows)
+		if err != nil {
+			return nil, err
+		}
+		memories = append(memories, *m)
+	}
+	return memories, rows.Err()
+}
+
+// scanMemory 扫描单行记忆数据（兼容*sql.Row与*sql.Rows）
+func scanMemory(row interface{ Scan(dest ...any) error }) (*model.Memory, error) {
+	var m model.Memory
+	var embedding []byte
+	if err := row.Scan(&m.ID, &m.UserID, &m.AssistantID, &m.Content, &m.Source,
+		&m.EmbeddingModel, &embedding, &m.GmtCreate, &m.GmtModified); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("扫描记忆失败: %w", err)
+	}
+	v, err := decodeVector(embedding)
+	if err != nil {
+		return nil, fmt.Errorf("解析记忆%s的向量失败: %w", m.ID, err)
+	}
+	m.Embedding = v
+	return &m, nil
+}
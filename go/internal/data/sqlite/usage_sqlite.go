@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"Voice_Assistant/internal/metrics"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/tracing"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// UsageSQLiteRepo 实现UsageRepo接口
+type UsageSQLiteRepo struct {
+	db *sql.DB
+}
+
+// NewUsageSQLiteRepo 创建实例
+func NewUsageSQLiteRepo(db *sql.DB) *UsageSQLiteRepo {
+	return &UsageSQLiteRepo{db: db}
+}
+
+// Save 保存用量记录
+func (r *UsageSQLiteRepo) Save(ctx context.Context, u *model.UsageRecord) error {
+	defer metrics.ObserveQuery("usage", "Save")()
+	ctx, span := tracing.Start(ctx, "sqlite.usage.Save")
+	defer span.End()
+	query := `
+	INSERT INTO usage_records (id, user_id, assistant_id, model, input_tokens, output_tokens, total_tokens, cost, gmt_create)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	if _, err := r.db.ExecContext(ctx, query,
+		u.ID, u.UserID, u.AssistantID, u.Model,
+		u.InputTokens, u.OutputTokens, u.TotalTokens, u.Cost, u.GmtCreate,
+	); err != nil {
+		return fmt.Errorf("保存用量记录失败: %w", err)
+	}
+	return nil
+}
+
+// SumByUserSince 统计用户自since以来的token数与费用
+func (r *UsageSQLiteRepo) SumByUserSince(ctx context.Context, userID, since string) (int, float64, error) {
+	defer metrics.ObserveQuery("usage", "SumByUserSince")()
+	ctx, span := tracing.Start(ctx, "sqlite.usage.SumByUserSince")
+	defer span.End()
+	return r.sum(ctx, "user_id", userID, since)
+}
+
+// SumByAssistantSince 统计助手自since以来的token数与费用（所有用户合计）
+func (r *UsageSQLiteRepo) SumByAssistantSince(ctx context.Context, assistantID, since string) (int, float64, error) {
+	defer metrics.ObserveQuery("usage", "SumByAssistantSince")()
+	ctx, span := tracing.Start(ctx, "sqlite.usage.SumByAssistantSince")
+	defer span.End()
+	return r.sum(ctx, "assistant_id", assistantID, since)
+}
+
+func (r *UsageSQLiteRepo) sum(ctx context.Context, column, id, since string) (int, float64, error) {
+	query := fmt.Sprintf(
+		"SELECT COALESCE(SUM(total_tokens), 0), COALESCE(SUM(cost), 0) FROM usage_records WHERE %s = ? AND gmt_create >= ?",
+		column,
+	)
+	var (
+		tokens int
+		cost   sql.NullFloat64
+	)
+	if err := r.db.QueryRowContext(ctx, query, id, since).Scan(&tokens, &cost); err != nil {
+		return 0, 0, fmt.Errorf("统计用量失败: %w", err)
+	}
+	return tokens, cost.Float64, nil
+}
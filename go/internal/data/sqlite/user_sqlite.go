@@ -0,0 +1,146 @@
+package sqlite
+
+import (
+	"Voice_Assistant/internal/metrics"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/tracing"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// UserSQLiteRepo 实现UserRepo接口
+type UserSQLiteRepo struct {
+	db *sql.DB
+}
+
+// NewUserSQLiteRepo 创建实例
+func NewUserSQLiteRepo(db *sql.DB) *UserSQLiteRepo {
+	return &UserSQLiteRepo{db: db}
+}
+
+// SelectAll 查询所有用户
+func (r *UserSQLiteRepo) SelectAll(ctx context.Context) ([]model.User, error) {
+	defer metrics.ObserveQuery("user", "SelectAll")()
+	ctx, span := tracing.Start(ctx, "sqlite.user.SelectAll")
+	defer span.End()
+	rows, err := r.db.QueryContext(ctx, "SELECT id, username, password_hash, display_name, role, gmt_create FROM users;")
+	if err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// SelectByID 按ID查询用户
+func (r *UserSQLiteRepo) SelectByID(ctx context.Context, id string) (*model.User, error) {
+	defer metrics.ObserveQuery("user", "SelectByID")()
+	ctx, span := tracing.Start(ctx, "sqlite.user.SelectByID")
+	defer span.End()
+	query := "SELECT id, username, password_hash, display_name, role, gmt_create FROM users WHERE id = ?"
+	return scanUser(r.db.QueryRowContext(ctx, query, id))
+}
+
+// SelectByUsername 按登录名查询用户
+func (r *UserSQLiteRepo) SelectByUsername(ctx context.Context, username string) (*model.User, error) {
+	defer metrics.ObserveQuery("user", "SelectByUsername")()
+	ctx, span := tracing.Start(ctx, "sqlite.user.SelectByUsername")
+	defer span.End()
+	query := "SELECT id, username, password_hash, display_name, role, gmt_create FROM users WHERE username = ?"
+	return scanUser(r.db.QueryRowContext(ctx, query, username))
+}
+
+// Count 用户总数
+func (r *UserSQLiteRepo) Count(ctx context.Context) (int, error) {
+	defer metrics.ObserveQuery("user", "Count")()
+	ctx, span := tracing.Start(ctx, "sqlite.user.Count")
+	defer span.End()
+	var n int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+		return 0, fmt.Errorf("统计用户失败: %w", err)
+	}
+	return n, nil
+}
+
+// Save 保存新用户
+func (r *UserSQLiteRepo) Save(ctx context.Context, u *model.User) (*model.User, error) {
+	defer metrics.ObserveQuery("user", "Save")()
+	ctx, span := tracing.Start(ctx, "sqlite.user.Save")
+	defer span.End()
+	query := `
+	INSERT INTO users (id, username, password_hash, display_name, role, gmt_create)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
+	if _, err := r.db.ExecContext(ctx, query,
+		u.ID, u.Username, u.PasswordHash, u.DisplayName, u.Role, u.GmtCreate,
+	); err != nil {
+		return nil, fmt.Errorf("保存用户失败: %w", err)
+	}
+	return u, nil
+}
+
+// SaveSession 保存会话
+func (r *UserSQLiteRepo) SaveSession(ctx context.Context, s *model.Session) error {
+	defer metrics.ObserveQuery("user", "SaveSession")()
+	ctx, span := tracing.Start(ctx, "sqlite.user.SaveSession")
+	defer span.End()
+	if _, err := r.db.ExecContext(ctx,
+		"INSERT INTO sessions (token_hash, user_id, expires_at, gmt_create) VALUES (?, ?, ?, ?)",
+		s.TokenHash, s.UserID, s.ExpiresAt, s.GmtCreate,
+	); err != nil {
+		return fmt.Errorf("保存会话失败: %w", err)
+	}
+	return nil
+}
+
+// SelectSessionByHash 按令牌哈希查询会话
+func (r *UserSQLiteRepo) SelectSessionByHash(ctx context.Context, tokenHash string) (*model.Session, error) {
+	defer metrics.ObserveQuery("user", "SelectSessionByHash")()
+	ctx, span := tracing.Start(ctx, "sqlite.user.SelectSessionByHash")
+	defer span.End()
+	var s model.Session
+	err := r.db.QueryRowContext(ctx,
+		"SELECT token_hash, user_id, expires_at, gmt_create FROM sessions WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&s.TokenHash, &s.UserID, &s.ExpiresAt, &s.GmtCreate)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("查询会话失败: %w", err)
+	}
+	return &s, nil
+}
+
+// DeleteSessionByHash 删除会话（不存在时不报错）
+func (r *UserSQLiteRepo) DeleteSessionByHash(ctx context.Context, tokenHash string) error {
+	defer metrics.ObserveQuery("user", "DeleteSessionByHash")()
+	ctx, span := tracing.Start(ctx, "sqlite.user.DeleteSessionByHash")
+	defer span.End()
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM sessions WHERE token_hash = ?", tokenHash); err != nil {
+		return fmt.Errorf("删除会话失败: %w", err)
+	}
+	return nil
+}
+
+// scanUser 扫描单行用户数据（兼容*sql.Row与*sql.Rows）
+func scanUser(row interface{ Scan(dest ...any) error }) (*model.User, error) {
+	var u model.User
+	if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.DisplayName, &u.Role, &u.GmtCreate); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("扫描用户数据失败: %w", err)
+	}
+	return &u, nil
+}
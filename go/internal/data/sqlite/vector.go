@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// encodeVector 向量序列化为小端float32字节（每维4字节）
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+// decodeVector 解析encodeVector生成的字节
+func decodeVector(buf []byte) ([]float32, error) {
+	if len(buf)%4 != 0 {
+		return nil, fmt.Errorf("向量长度%d不是4的倍数", len(buf))
+	}
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v, nil
+}
+
+// migrateVectors 旧版本以JSON文本保存的片段向量转为二进制
+func migrateVectors(db *sql.DB) error {
+	rows, err := db.Query("SELECT document_id, seq, embedding FROM knowledge_chunks WHERE typeof(embedding) = 'text'")
+	if err != nil {
+		return fmt.Errorf("查询待迁移的向量失败: %w", err)
+	}
+	type chunk struct {
+		documentID string
+		seq        int
+		embedding  []byte
+	}
+	var chunks []chunk
+	for rows.Next() {
+		var c chunk
+		var text string
+		if err := rows.Scan(&c.documentID, &c.seq, &text); err != nil {
+			rows.Close()
+			return fmt.Errorf("扫描待迁移的向量失败: %w", err)
+		}
+		var v []float32
+		if err := json.Unmarshal([]byte(text), &v); err != nil {
+			rows.Close()
+			return fmt.Errorf("解析文档%s第%d段的向量失败: %w", c.documentID, c.seq, err)
+		}
+		c.embedding = encodeVector(v)
+		chunks = append(chunks, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("查询待迁移的向量失败: %w", err)
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启迁移事务失败: %w", err)
+	}
+	defer tx.Rollback()
+	for _, c := range chunks {
+		if _, err := tx.Exec("UPDATE knowledge_chunks SET embedding = ? WHERE document_id = ? AND seq = ?", c.embedding, c.documentID, c.seq); err != nil {
+			return fmt.Errorf("迁移向量失败: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交迁移事务失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestVectorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		v    []float32
+	}{
+		{"空向量", []float32{}},
+		{"单个元素", []float32{1}},
+		{"正负与小数", []float32{0.5, -0.25, 3.1415926, -1e-7}},
+		{"极值", []float32{math.MaxFloat32, -math.MaxFloat32, math.SmallestNonzeroFloat32}},
+		{"无穷", []float32{float32(math.Inf(1)), float32(math.Inf(-1))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := encodeVector(tt.v)
+			if len(buf) != 4*len(tt.v) {
+				t.Fatalf("编码长度 = %d, want %d", len(buf), 4*len(tt.v))
+			}
+			got, err := decodeVector(buf)
+			if err != nil {
+				t.Fatalf("decodeVector: %v", err)
+			}
+			if !slices.Equal(got, tt.v) {
+				t.Errorf("decodeVector = %v, want %v", got, tt.v)
+			}
+		})
+	}
+}
+
+func TestEncodeVectorLittleEndian(t *testing.T) {
+	// 1.0 = 0x3f800000
+	got := encodeVector([]float32{1})
+	want := []byte{0x00, 0x00, 0x80, 0x3f}
+	if !slices.Equal(got, want) {
+		t.Errorf("encodeVector(1) = % x, want % x", got, want)
+	}
+}
+
+func TestDecodeVectorInvalidLength(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 7} {
+		if _, err := decodeVector(make([]byte, n)); err == nil {
+			t.Errorf("decodeVector(%d字节) 未返回错误", n)
+		}
+	}
+}
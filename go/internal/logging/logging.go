@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config 日志配置
+type Config struct {
+	Level  string `yaml:"level"`  // debug/info/warn/error
+	Format string `yaml:"format"` // text/json
+}
+
+// 需要脱敏的字段：消息内容类只保留长度，密钥类只保留前缀
+var (
+	contentKeys = map[string]bool{"content": true, "input": true, "prompt": true, "query": true, "body": true, "chunk": true}
+	secretKeys  = map[string]bool{"api_key": true, "token": true, "authorization": true}
+)
+
+// 调试模式下不脱敏
+var debug atomic.Bool
+
+// Setup 初始化全局slog日志（标准库log的输出也会转发到slog）
+func Setup(cfg Config) error {
+	return SetupTo(cfg, os.Stdout)
+}
+
+// SetupTo 同Setup，日志写入指定输出（命令行工具写到stderr，避免混入导出数据）
+func SetupTo(cfg Config, w io.Writer) error {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	debug.Store(level <= slog.LevelDebug)
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("不支持的日志格式: %s", cfg.Format)
+	}
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// Debug 是否处于调试模式（调试模式下日志不脱敏）
+func Debug() bool {
+	return debug.Load()
+}
+
+// ParseLevel 解析日志级别名称
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("不支持的日志级别: %s", s)
+	}
+}
+
+// redact 非调试模式下脱敏消息内容与密钥
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if debug.Load() || a.Value.Kind() != slog.KindString {
+		return a
+	}
+	s := a.Value.String()
+	switch {
+	case contentKeys[a.Key]:
+		return slog.String(a.Key, fmt.Sprintf("[已脱敏 %d字]", utf8.RuneCountInString(s)))
+	case secretKeys[a.Key]:
+		return slog.String(a.Key, maskSecret(s))
+	}
+	return a
+}
+
+// maskSecret 只保留密钥前缀（va_/vs_ + 4位），便于排查又不泄露明文
+func maskSecret(s string) string {
+	const keep = 7
+	if len(s) <= keep {
+		return "***"
+	}
+	return s[:keep] + "***"
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 将请求ID写入上下文
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 读取上下文中的请求ID（不存在时返回空串）
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler 从上下文中提取请求ID与追踪ID附加到每条日志
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
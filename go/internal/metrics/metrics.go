@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"Voice_Assistant/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "voice_assistant"
+
+var (
+	// HTTP请求
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP请求总数",
+	}, []string{"method", "route", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP请求耗时（SSE请求包含整个流的时长）",
+		Buckets:   []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"method", "route"})
+
+	// LLM调用
+	llmTTFT = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_time_to_first_token_seconds",
+		Help:      "流式调用首个chunk到达耗时",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 16, 32},
+	}, []string{"model"})
+	llmDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "单轮LLM调用总耗时",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 4, 8, 16, 32, 64, 128},
+	}, []string{"model", "stream"})
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "LLM消耗的token数",
+	}, []string{"model", "direction"})
+	llmErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_errors_total",
+		Help:      "LLM调用错误数（status为HTTP状态码或错误类别）",
+	}, []string{"model", "status"})
+
+	// 工具调用
+	toolExecutions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_executions_total",
+		Help:      "工具调用次数",
+	}, []string{"tool", "outcome"})
+	bochaRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bocha_search_retries_total",
+		Help:      "博查搜索重试次数",
+	})
+
+	// 文本向量
+	embeddingRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "embedding_requests_total",
+		Help:      "向量接口请求次数（含重试，status为ok、HTTP状态码或错误类别）",
+	}, []string{"model", "status"})
+	embeddingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "embedding_request_duration_seconds",
+		Help:      "单次向量接口请求耗时",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8, 16},
+	}, []string{"model"})
+
+	// 数据库
+	dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sqlite_query_duration_seconds",
+		Help:      "SQLite仓库方法耗时",
+		Buckets:   []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.5, 1},
+	}, []string{"repo", "op"})
+
+	// SSE
+	activeStreams = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sse_active_streams",
+		Help:      "进行中的SSE流数量",
+	})
+)
+
+// Handler /metrics端点
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// HTTPMiddleware 记录请求数与耗时（按路由模板区分，未匹配路由记为unmatched）
+func HTTPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveLLMFirstToken 记录首个chunk耗时
+func ObserveLLMFirstToken(modelName string, start time.Time) {
+	llmTTFT.WithLabelValues(modelName).Observe(time.Since(start).Seconds())
+}
+
+// ObserveLLMDuration 记录单轮调用耗时
+func ObserveLLMDuration(modelName string, stream bool, start time.Time) {
+	llmDuration.WithLabelValues(modelName, strconv.FormatBool(stream)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveLLMTokens 累加token用量
+func ObserveLLMTokens(modelName string, usage model.Usage) {
+	llmTokens.WithLabelValues(modelName, "input").Add(float64(usage.InputTokens))
+	llmTokens.WithLabelValues(modelName, "output").Add(float64(usage.OutputTokens))
+}
+
+// IncLLMError 记录LLM调用错误
+func IncLLMError(modelName, status string) {
+	llmErrors.WithLabelValues(modelName, status).Inc()
+}
+
+// IncToolExecution 记录工具调用结果（outcome: success/error/empty/unsupported）
+func IncToolExecution(tool, outcome string) {
+	toolExecutions.WithLabelValues(tool, outcome).Inc()
+}
+
+// IncBochaRetry 记录博查搜索重试
+func IncBochaRetry() {
+	bochaRetries.Inc()
+}
+
+// ObserveEmbedding 记录单次向量接口请求的结果与耗时
+func ObserveEmbedding(modelName, status string, start time.Time) {
+	embeddingRequests.WithLabelValues(modelName, status).Inc()
+	embeddingDuration.WithLabelValues(modelName).Observe(time.Since(start).Seconds())
+}
+
+// ObserveQuery 记录仓库方法耗时，用法：defer metrics.ObserveQuery("assistant", "SelectAll")()
+func ObserveQuery(repo, op string) func() {
+	start := time.Now()
+	return func() {
+		dbDuration.WithLabelValues(repo, op).Observe(time.Since(start).Seconds())
+	}
+}
+
+// StreamStarted SSE流开始，返回结束时调用的函数
+func StreamStarted() func() {
+	activeStreams.Inc()
+	return activeStreams.Dec
+}
@@ -0,0 +1,20 @@
+package model
+
+// API密钥权限范围（按权限从低到高）
+const (
+	ScopeRead  = "read"  // 只读：查询助手与历史
+	ScopeChat  = "chat"  // 对话：只读权限+发送消息、重置对话
+	ScopeAdmin = "admin" // 管理：全部权限
+)
+
+type APIKey struct {
+	ID           string   `json:"id"`
+	UserID       string   `json:"user_id"`
+	Name         string   `json:"name"`
+	KeyHash      string   `json:"-"`
+	Prefix       string   `json:"prefix"`
+	Scope        string   `json:"scope"`
+	AssistantIDs []string `json:"assistant_ids"`
+	GmtCreate    string   `json:"gmt_create"`
+	GmtLastUsed  string   `json:"gmt_last_used"`
+}
@@ -0,0 +1,49 @@
+package model
+
+// ArchiveVersion 归档格式版本（结构不兼容时递增，导入时校验）
+const ArchiveVersion = 1
+
+// 导入时的ID冲突处理方式
+const (
+	ConflictSkip      = "skip"      // 跳过已存在的助手
+	ConflictOverwrite = "overwrite" // 覆盖已存在的助手及其全部会话（知识库与会话变量保留）
+	ConflictNewID     = "new_id"    // 以新ID导入为副本
+)
+
+// 单个助手的导入处理结果
+const (
+	ImportCreated     = "created"
+	ImportOverwritten = "overwritten"
+	ImportSkipped     = "skipped"
+)
+
+// Archive 可移植的助手归档（助手设置及全部用户的会话）
+type Archive struct {
+	Version    int                 `json:"version"`
+	ExportedAt string              `json:"exported_at"`
+	Assistants []ArchivedAssistant `json:"assistants"`
+}
+
+// ArchivedAssistant 归档中的助手
+type ArchivedAssistant struct {
+	Assistant
+	Histories []History `json:"histories"`
+}
+
+// ImportReport 导入结果
+type ImportReport struct {
+	DryRun      bool         `json:"dry_run"`
+	Created     int          `json:"created"`
+	Overwritten int          `json:"overwritten"`
+	Skipped     int          `json:"skipped"`
+	Items       []ImportItem `json:"items"`
+}
+
+// ImportItem 单个助手的导入结果
+type ImportItem struct {
+	SourceID string `json:"source_id"` // 归档中的ID
+	ID       string `json:"id"`        // 导入后的ID（new_id时与SourceID不同）
+	Name     string `json:"name"`
+	Action   string `json:"action"` // ImportCreated/ImportOverwritten/ImportSkipped
+	Messages int    `json:"messages"`
+}
@@ -1,11 +1,48 @@
 package model
 
 type Assistant struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Prompt      string `json:"prompt"`
-	GmtCreate   string `json:"gmt_create"`
-	GmtModified string `json:"gmt_modified"`
-	TimeStamp   string `json:"time_stamp"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Prompt      string      `json:"prompt"`
+	GmtCreate   string      `json:"gmt_create"`
+	GmtModified string      `json:"gmt_modified"`
+	TimeStamp   string      `json:"time_stamp"`
+	OwnerID     string      `json:"owner_id"`
+	Visibility  string      `json:"visibility"`
+	SharedWith  []string    `json:"shared_with"`
+	ModelParams ModelParams `json:"model_params"`
+	Tools       []string    `json:"tools"`            // 可用工具（null为全部，空数组为不使用工具）
+	Source      string      `json:"source,omitempty"` // 声明该助手的YAML文件（非空时只读）
+	Revision    int         `json:"revision"`         // 当前版本号（提示词或参数变化时递增）
+}
+
+// ModelParams 助手级模型参数与系统提示（零值表示使用全局配置）
+type ModelParams struct {
+	Model       string   `json:"model,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Preamble    *string  `json:"preamble,omitempty"`   // 系统提示前言（nil使用全局配置，空字符串为不使用）
+	ToolGuide   *string  `json:"tool_guide,omitempty"` // 工具使用说明（同上）
+}
+
+// AssistantRevision 助手提示词与参数的历史版本（只追加，不修改）
+type AssistantRevision struct {
+	AssistantID string      `json:"assistant_id"`
+	Revision    int         `json:"revision"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Prompt      string      `json:"prompt"`
+	ModelParams ModelParams `json:"model_params"`
+	Tools       []string    `json:"tools"`
+	AuthorID    string      `json:"author_id"` // 产生该版本的用户（系统身份为空）
+	Note        string      `json:"note"`      // 版本说明，如“回滚到版本3”
+	GmtCreate   string      `json:"gmt_create"`
+}
+
+// RevisionDiff 两个版本之间的差异
+type RevisionDiff struct {
+	From   int         `json:"from"`
+	To     int         `json:"to"`
+	Fields []FieldDiff `json:"fields"`
 }
@@ -0,0 +1,46 @@
+package model
+
+// 声明式助手同步的变更类型
+const (
+	SyncCreate    = "create"    // 新文件，创建助手
+	SyncUpdate    = "update"    // 文件内容与数据库不一致，更新助手
+	SyncUnchanged = "unchanged" // 无变化
+	SyncRelease   = "release"   // 文件已删除，解除管理（助手保留，可通过API修改）
+	SyncDelete    = "delete"    // 文件已删除且开启prune，删除助手及其会话
+	SyncConflict  = "conflict"  // id与通过API创建的助手相同，文件未声明adopt，不接管
+)
+
+// SyncPlan 声明式助手的同步计划（dry-run时只计算不执行）
+type SyncPlan struct {
+	Dir     string       `json:"dir"`
+	DryRun  bool         `json:"dry_run"`
+	Changes []SyncChange `json:"changes"`
+}
+
+// SyncChange 单个助手的变更
+type SyncChange struct {
+	Action string      `json:"action"`
+	ID     string      `json:"id"`
+	Name   string      `json:"name"`
+	Source string      `json:"source"` // 声明该助手的文件（相对目录）
+	Fields []FieldDiff `json:"fields,omitempty"`
+}
+
+// FieldDiff 字段变化（多行字段附带逐行对比）
+type FieldDiff struct {
+	Field string   `json:"field"`
+	Old   string   `json:"old"`
+	New   string   `json:"new"`
+	Diff  []string `json:"diff,omitempty"`
+}
+
+// Count 统计指定类型的变更数
+func (p *SyncPlan) Count(action string) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
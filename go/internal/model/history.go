@@ -2,14 +2,25 @@ package model
 
 type History struct {
 	AssistantID string    `json:"assistant_id"`
+	UserID      string    `json:"user_id"`
 	Messages    []Message `json:"messages"`
 }
 
+// ConversationVars 会话级提示词变量（重置对话后保留）
+type ConversationVars struct {
+	Title       string            `json:"title"`
+	Variables   map[string]string `json:"variables"`
+	GmtModified string            `json:"gmt_modified"`
+}
+
 type Message struct {
-	Input     Input  `json:"input"`
-	Output    Output `json:"output"`
-	Usage     Usage  `json:"usage"`
-	GmtCreate string `json:"gmt_create"`
+	Input     Input      `json:"input"`
+	Output    Output     `json:"output"`
+	Usage     Usage      `json:"usage"`
+	GmtCreate string     `json:"gmt_create"`
+	ToolCalls []ToolUse  `json:"tool_calls,omitempty"` // 本轮调用的工具
+	Citations []Citation `json:"citations,omitempty"`  // 搜索引用的来源
+	Revision  int        `json:"revision,omitempty"`   // 生成该回复的助手版本
 }
 
 type Input struct {
@@ -27,3 +38,17 @@ type Usage struct {
 	OutputTokens int `json:"output_tokens"`
 	TotalTokens  int `json:"total_tokens"`
 }
+
+// ToolUse 工具调用记录
+type ToolUse struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Citation 搜索结果引用
+type Citation struct {
+	Title         string `json:"title"`
+	URL           string `json:"url"`
+	Snippet       string `json:"snippet"`
+	DatePublished string `json:"date_published"`
+}
@@ -0,0 +1,42 @@
+package model
+
+// 知识库文档类型
+const (
+	DocumentMarkdown = "markdown"
+	DocumentText     = "text"
+	DocumentHTML     = "html"
+	DocumentPDF      = "pdf"
+)
+
+// KnowledgeDocument 上传到助手知识库的文档（原文不保存，只保存切分后的片段）
+type KnowledgeDocument struct {
+	ID             string `json:"id"`
+	AssistantID    string `json:"assistant_id"`
+	Title          string `json:"title"`
+	Filename       string `json:"filename"`
+	ContentType    string `json:"content_type"`    // markdown/text/html/pdf
+	Size           int    `json:"size"`            // 原文件字节数
+	Chunks         int    `json:"chunks"`          // 片段数量
+	EmbeddingModel string `json:"embedding_model"` // 生成向量的模型（更换模型后需重新上传）
+	UploaderID     string `json:"uploader_id"`
+	GmtCreate      string `json:"gmt_create"`
+}
+
+// KnowledgeChunk 文档片段及其向量
+type KnowledgeChunk struct {
+	DocumentID     string    `json:"document_id"`
+	Seq            int       `json:"seq"` // 在文档中的序号（从0开始）
+	Title          string    `json:"title"`
+	Content        string    `json:"content"`
+	EmbeddingModel string    `json:"-"` // 所属文档生成向量的模型（查询时读取）
+	Embedding      []float32 `json:"-"`
+}
+
+// KnowledgeMatch 检索命中的片段
+type KnowledgeMatch struct {
+	DocumentID string  `json:"document_id"`
+	Title      string  `json:"title"`
+	Seq        int     `json:"seq"`
+	Content    string  `json:"content"`
+	Score      float64 `json:"score"` // 余弦相似度
+}
@@ -0,0 +1,21 @@
+package model
+
+// 记忆来源
+const (
+	MemorySourceManual    = "manual"    // 通过接口添加或修改
+	MemorySourceTool      = "tool"      // 模型调用remember工具保存
+	MemorySourceExtracted = "extracted" // 重置对话时从会话中提取
+)
+
+// Memory 关于用户或项目的长期记忆（按用户保存，重置对话后仍然保留）
+type Memory struct {
+	ID             string    `json:"id"`
+	UserID         string    `json:"user_id"`
+	AssistantID    string    `json:"assistant_id"` // 为空时对该用户的所有助手生效
+	Content        string    `json:"content"`
+	Source         string    `json:"source"` // manual/tool/extracted
+	EmbeddingModel string    `json:"-"`      // 生成向量的模型（未启用文本向量时为空）
+	Embedding      []float32 `json:"-"`
+	GmtCreate      string    `json:"gmt_create"`
+	GmtModified    string    `json:"gmt_modified"`
+}
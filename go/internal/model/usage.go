@@ -0,0 +1,53 @@
+package model
+
+// UsageRecord 单次LLM调用的用量记录
+type UsageRecord struct {
+	ID           string  `json:"id"`
+	UserID       string  `json:"user_id"`
+	AssistantID  string  `json:"assistant_id"`
+	Model        string  `json:"model"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	TotalTokens  int     `json:"total_tokens"`
+	Cost         float64 `json:"cost"`
+	GmtCreate    string  `json:"gmt_create"`
+}
+
+// QuotaLimit 配额上限（0表示不限制）
+type QuotaLimit struct {
+	DailyTokens   int     `yaml:"daily_tokens" json:"daily_tokens"`
+	MonthlyTokens int     `yaml:"monthly_tokens" json:"monthly_tokens"`
+	DailyCost     float64 `yaml:"daily_cost" json:"daily_cost"`
+	MonthlyCost   float64 `yaml:"monthly_cost" json:"monthly_cost"`
+}
+
+// QuotaPolicy 配额策略（按用户、按助手，未单独配置时使用默认值）
+type QuotaPolicy struct {
+	Enabled          bool                  `yaml:"enabled"`
+	DefaultUser      QuotaLimit            `yaml:"default_user"`
+	DefaultAssistant QuotaLimit            `yaml:"default_assistant"`
+	Users            map[string]QuotaLimit `yaml:"users"`
+	Assistants       map[string]QuotaLimit `yaml:"assistants"`
+}
+
+// ModelPrice 模型单价（每千token）
+type ModelPrice struct {
+	InputPer1K  float64 `yaml:"input_per_1k" json:"input_per_1k"`
+	OutputPer1K float64 `yaml:"output_per_1k" json:"output_per_1k"`
+}
+
+// UsageSummary 某一周期内的用量与上限
+type UsageSummary struct {
+	Tokens     int     `json:"tokens"`
+	Cost       float64 `json:"cost"`
+	TokenLimit int     `json:"token_limit"`
+	CostLimit  float64 `json:"cost_limit"`
+}
+
+// QuotaReport 当前用量与配额对比
+type QuotaReport struct {
+	Scope   string       `json:"scope"` // user/assistant
+	ID      string       `json:"id"`
+	Daily   UsageSummary `json:"daily"`
+	Monthly UsageSummary `json:"monthly"`
+}
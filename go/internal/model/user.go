@@ -0,0 +1,30 @@
+package model
+
+// 用户角色
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// 助手可见性
+const (
+	VisibilityPrivate = "private" // 仅所有者可见
+	VisibilityShared  = "shared"  // 所有者及shared_with中的用户可见
+	VisibilityPublic  = "public"  // 所有用户可见
+)
+
+type User struct {
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"-"`
+	DisplayName  string `json:"display_name"`
+	Role         string `json:"role"`
+	GmtCreate    string `json:"gmt_create"`
+}
+
+type Session struct {
+	TokenHash string `json:"-"`
+	UserID    string `json:"user_id"`
+	ExpiresAt string `json:"expires_at"`
+	GmtCreate string `json:"gmt_create"`
+}
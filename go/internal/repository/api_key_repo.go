@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"Voice_Assistant/internal/data/sqlite"
+	"Voice_Assistant/internal/model"
+	"context"
+	"database/sql"
+)
+
+// APIKeyRepo API密钥数据访问接口
+type APIKeyRepo interface {
+	SelectAll(ctx context.Context) ([]model.APIKey, error)
+	SelectByHash(ctx context.Context, keyHash string) (*model.APIKey, error)
+	Save(ctx context.Context, key *model.APIKey) (*model.APIKey, error)
+	DeleteByID(ctx context.Context, id string) error
+	UpdateLastUsed(ctx context.Context, id string, lastUsed string) error
+}
+
+// NewAPIKeyRepo 创建API密钥仓库实例（依赖注入）
+func NewAPIKeyRepo(db *sql.DB) APIKeyRepo {
+	return sqlite.NewAPIKeySQLiteRepo(db)
+}
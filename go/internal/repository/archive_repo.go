@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"Voice_Assistant/internal/data/sqlite"
+	"Voice_Assistant/internal/model"
+	"context"
+	"database/sql"
+)
+
+// ArchiveRepo 归档数据访问接口（跨用户读取会话、事务性写入）
+type ArchiveRepo interface {
+	SelectHistories(ctx context.Context, assistantID string) ([]model.History, error)
+	Restore(ctx context.Context, assistants []model.ArchivedAssistant) error
+}
+
+// NewArchiveRepo 创建归档仓库实例（依赖注入）
+func NewArchiveRepo(db *sql.DB) ArchiveRepo {
+	return sqlite.NewArchiveSQLiteRepo(db)
+}
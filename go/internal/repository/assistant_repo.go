@@ -13,6 +13,11 @@ type AssistantRepo interface {
 	DeleteByID(ctx context.Context, id string) error
 	Save(ctx context.Context, assistant *model.Assistant) (*model.Assistant, error)
 	UpdateByID(ctx context.Context, id string, assistant *model.Assistant) (*model.Assistant, error)
+	// 版本号等于expected时更新助手并追加版本记录（同一事务，revision可为nil）；否则返回sql.ErrNoRows
+	UpdateWithRevision(ctx context.Context, assistant *model.Assistant, expected int, revision *model.AssistantRevision) error
+	// 助手版本（只追加）
+	SaveRevision(ctx context.Context, revision *model.AssistantRevision) error
+	SelectRevisions(ctx context.Context, assistantID string) ([]model.AssistantRevision, error)
 }
 
 // NewAssistantRepo 创建助手仓库实例（依赖注入）
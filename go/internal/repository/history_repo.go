@@ -13,6 +13,9 @@ type HistoryRepo interface {
 	DeleteByAssistantID(ctx context.Context, assistantID string) error
 	SaveByAssistantID(ctx context.Context, assistantID string, message model.Message) error
 	UpdateAssistantTimestamp(ctx context.Context, assistantID string, timestamp string) error
+	// 当前用户在该助手下的会话变量（未设置时返回sql.ErrNoRows）
+	SelectVars(ctx context.Context, assistantID string) (*model.ConversationVars, error)
+	SaveVars(ctx context.Context, assistantID string, vars *model.ConversationVars) error
 }
 
 // NewHistoryRepo 创建历史仓库实例（依赖注入）
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"Voice_Assistant/internal/data/sqlite"
+	"Voice_Assistant/internal/model"
+	"context"
+	"database/sql"
+)
+
+// KnowledgeRepo 知识库数据访问接口
+type KnowledgeRepo interface {
+	// 在一个事务中保存文档及其全部片段
+	SaveDocument(ctx context.Context, doc *model.KnowledgeDocument, chunks []model.KnowledgeChunk) error
+	SelectDocuments(ctx context.Context, assistantID string) ([]model.KnowledgeDocument, error)
+	// 文档不存在时返回sql.ErrNoRows
+	DeleteDocument(ctx context.Context, assistantID, documentID string) error
+	// 助手知识库的全部片段（含向量，用于暴力检索）
+	SelectChunks(ctx context.Context, assistantID string) ([]model.KnowledgeChunk, error)
+	CountDocuments(ctx context.Context, assistantID string) (int, error)
+}
+
+// NewKnowledgeRepo 创建知识库仓库实例（依赖注入）
+func NewKnowledgeRepo(db *sql.DB) KnowledgeRepo {
+	return sqlite.NewKnowledgeSQLiteRepo(db)
+}
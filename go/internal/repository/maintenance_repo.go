@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"Voice_Assistant/internal/data/sqlite"
+	"context"
+	"database/sql"
+)
+
+// MaintenanceRepo 数据库维护接口（备份、整理）
+type MaintenanceRepo interface {
+	Backup(ctx context.Context, path string) error
+	Vacuum(ctx context.Context) error
+}
+
+// NewMaintenanceRepo 创建维护仓库实例（依赖注入）
+func NewMaintenanceRepo(db *sql.DB) MaintenanceRepo {
+	return sqlite.NewMaintenanceSQLiteRepo(db)
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"Voice_Assistant/internal/data/sqlite"
+	"Voice_Assistant/internal/model"
+	"context"
+	"database/sql"
+)
+
+// MemoryRepo 长期记忆数据访问接口（均按用户隔离）
+type MemoryRepo interface {
+	Save(ctx context.Context, m *model.Memory) error
+	// 修改内容、适用助手与向量（记忆不存在时返回sql.ErrNoRows）
+	Update(ctx context.Context, m *model.Memory) error
+	// 仅更新向量（更换向量模型后重新生成）
+	UpdateEmbedding(ctx context.Context, m *model.Memory) error
+	// 记忆不存在时返回sql.ErrNoRows
+	Delete(ctx context.Context, userID, id string) error
+	// assistantID为空时删除该用户的全部记忆，否则只删除该助手专属的记忆；返回删除条数
+	DeleteAll(ctx context.Context, userID, assistantID string) (int, error)
+	SelectByID(ctx context.Context, userID, id string) (*model.Memory, error)
+	// 用户的记忆（按修改时间倒序，含向量）；assistantID非空时只返回该助手可用的记忆（共享+专属）
+	SelectByUser(ctx context.Context, userID, assistantID string) ([]model.Memory, error)
+}
+
+// NewMemoryRepo 创建长期记忆仓库实例（依赖注入）
+func NewMemoryRepo(db *sql.DB) MemoryRepo {
+	return sqlite.NewMemorySQLiteRepo(db)
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"Voice_Assistant/internal/data/sqlite"
+	"Voice_Assistant/internal/model"
+	"context"
+	"database/sql"
+)
+
+// UsageRepo 用量记录数据访问接口
+type UsageRepo interface {
+	Save(ctx context.Context, record *model.UsageRecord) error
+	SumByUserSince(ctx context.Context, userID string, since string) (int, float64, error)
+	SumByAssistantSince(ctx context.Context, assistantID string, since string) (int, float64, error)
+}
+
+// NewUsageRepo 创建用量仓库实例（依赖注入）
+func NewUsageRepo(db *sql.DB) UsageRepo {
+	return sqlite.NewUsageSQLiteRepo(db)
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"Voice_Assistant/internal/data/sqlite"
+	"Voice_Assistant/internal/model"
+	"context"
+	"database/sql"
+)
+
+// UserRepo 用户与会话数据访问接口
+type UserRepo interface {
+	SelectAll(ctx context.Context) ([]model.User, error)
+	SelectByID(ctx context.Context, id string) (*model.User, error)
+	SelectByUsername(ctx context.Context, username string) (*model.User, error)
+	Count(ctx context.Context) (int, error)
+	Save(ctx context.Context, user *model.User) (*model.User, error)
+	SaveSession(ctx context.Context, session *model.Session) error
+	SelectSessionByHash(ctx context.Context, tokenHash string) (*model.Session, error)
+	DeleteSessionByHash(ctx context.Context, tokenHash string) error
+}
+
+// NewUserRepo 创建用户仓库实例（依赖注入）
+func NewUserRepo(db *sql.DB) UserRepo {
+	return sqlite.NewUserSQLiteRepo(db)
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/repository"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 明文密钥前缀
+const apiKeyPrefix = "va_"
+
+// 引导管理员密钥（来自配置文件，不入库）的固定ID
+const BootstrapKeyID = "bootstrap-admin"
+
+var (
+	ErrInvalidAPIKey = errors.New("无效的API密钥")
+	ErrInvalidScope  = errors.New("无效的权限范围，可选值：read、chat、admin")
+)
+
+// APIKeyService API密钥业务接口
+type APIKeyService interface {
+	// 查询所有密钥（不含明文）
+	SelectAll(ctx context.Context) ([]model.APIKey, error)
+	// 创建密钥（明文仅在创建时返回一次）
+	Create(ctx context.Context, name, scope string, assistantIDs []string) (*model.APIKey, string, error)
+	// 按ID删除（吊销）密钥
+	DeleteByID(ctx context.Context, id string) error
+	// 校验明文密钥，返回对应密钥信息
+	Authenticate(ctx context.Context, rawKey string) (*model.APIKey, error)
+}
+
+type apiKeyServiceImpl struct {
+	apiKeyRepo    repository.APIKeyRepo
+	assistantRepo repository.AssistantRepo
+	bootstrapKey  string // 配置中的引导管理员密钥
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepo, assistantRepo repository.AssistantRepo, bootstrapKey string) APIKeyService {
+	if bootstrapKey == "" {
+		slog.Warn("未配置引导管理员密钥，只能使用数据库中已有的密钥访问")
+	}
+	return &apiKeyServiceImpl{
+		apiKeyRepo:    apiKeyRepo,
+		assistantRepo: assistantRepo,
+		bootstrapKey:  bootstrapKey,
+	}
+}
+
+// SelectAll 查询所有密钥
+func (s *apiKeyServiceImpl) SelectAll(ctx context.Context) ([]model.APIKey, error) {
+	keys, err := s.apiKeyRepo.SelectAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询API密钥失败: %w", err)
+	}
+	return keys, nil
+}
+
+// Create 创建密钥（校验权限范围与助手是否存在）
+func (s *apiKeyServiceImpl) Create(ctx context.Context, name, scope string, assistantIDs []string) (*model.APIKey, string, error) {
+	if !IsValidScope(scope) {
+		return nil, "", ErrInvalidScope
+	}
+
+	if len(assistantIDs) > 0 {
+		assistants, err := s.assistantRepo.SelectAll(ctx)
+		if err != nil {
+			return nil, "", fmt.Errorf("查询助手失败: %w", err)
+		}
+		exists := make(map[string]bool, len(assistants))
+		for _, a := range assistants {
+			exists[a.ID] = true
+		}
+		for _, id := range assistantIDs {
+			if !exists[id] {
+				return nil, "", fmt.Errorf("助手不存在: %s", id)
+			}
+		}
+	}
+
+	raw, err := generateRawKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &model.APIKey{
+		ID:           uuid.New().String(),
+		UserID:       auth.UserID(ctx),
+		Name:         name,
+		KeyHash:      hashAPIKey(raw),
+		Prefix:       raw[:len(apiKeyPrefix)+6],
+		Scope:        scope,
+		AssistantIDs: assistantIDs,
+		GmtCreate:    time.Now().Format("2006-01-02 15:04:05"),
+	}
+	saved, err := s.apiKeyRepo.Save(ctx, key)
+	if err != nil {
+		return nil, "", fmt.Errorf("保存API密钥失败: %w", err)
+	}
+	return saved, raw, nil
+}
+
+// DeleteByID 删除密钥
+func (s *apiKeyServiceImpl) DeleteByID(ctx context.Context, id string) error {
+	if err := s.apiKeyRepo.DeleteByID(ctx, id); err != nil {
+		return fmt.Errorf("删除API密钥失败: %w", err)
+	}
+	return nil
+}
+
+// Authenticate 校验密钥（优先匹配引导管理员密钥，再查库）
+func (s *apiKeyServiceImpl) Authenticate(ctx context.Context, rawKey string) (*model.APIKey, error) {
+	if rawKey == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if s.bootstrapKey != "" && subtle.ConstantTimeCompare([]byte(rawKey), []byte(s.bootstrapKey)) == 1 {
+		return &model.APIKey{ID: BootstrapKeyID, Name: "引导管理员密钥", Scope: model.ScopeAdmin}, nil
+	}
+
+	key, err := s.apiKeyRepo.SelectByHash(ctx, hashAPIKey(rawKey))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("校验API密钥失败: %w", err)
+	}
+
+	// 记录使用时间失败不影响本次请求
+	if err := s.apiKeyRepo.UpdateLastUsed(ctx, key.ID, time.Now().Format("2006-01-02 15:04:05")); err != nil {
+		slog.WarnContext(ctx, "更新API密钥使用时间失败", "key_id", key.ID, "error", err)
+	}
+	return key, nil
+}
+
+// IsValidScope 判断权限范围是否合法
+func IsValidScope(scope string) bool {
+	return scopeLevel(scope) > 0
+}
+
+// HasScope 判断密钥权限是否满足要求（admin ⊃ chat ⊃ read）
+func HasScope(key *model.APIKey, required string) bool {
+	return key != nil && scopeLevel(key.Scope) >= scopeLevel(required)
+}
+
+// CanAccessAssistant 判断密钥是否可访问指定助手（未限定助手时可访问全部）
+func CanAccessAssistant(key *model.APIKey, assistantID string) bool {
+	if key == nil {
+		return false
+	}
+	if len(key.AssistantIDs) == 0 {
+		return true
+	}
+	for _, id := range key.AssistantIDs {
+		if id == assistantID {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeLevel(scope string) int {
+	switch scope {
+	case model.ScopeRead:
+		return 1
+	case model.ScopeChat:
+		return 2
+	case model.ScopeAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// 生成随机明文密钥
+func generateRawKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成API密钥失败: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// 计算密钥哈希（密钥为高熵随机串，SHA-256即可）
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
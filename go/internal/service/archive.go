@@ -0,0 +1,213 @@
+package service
+
+import (
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidArchive  = errors.New("归档文件不合法")
+	ErrInvalidConflict = errors.New("无效的冲突处理方式，可选值：skip、overwrite、new_id")
+)
+
+// ArchiveConfig 归档导入参数（零值使用默认值）
+type ArchiveConfig struct {
+	MaxImportMB int `yaml:"max_import_mb"` // 导入归档文件大小上限
+}
+
+func (c ArchiveConfig) withDefaults() ArchiveConfig {
+	if c.MaxImportMB <= 0 {
+		c.MaxImportMB = 50
+	}
+	return c
+}
+
+// ArchiveService 助手归档（跨机器迁移助手及其会话）
+type ArchiveService interface {
+	// 导出助手设置及全部用户的会话（未指定ID时导出全部）
+	Export(ctx context.Context, assistantIDs []string) (*model.Archive, error)
+	// 导入归档（按conflict处理ID冲突；dryRun时只返回处理结果，不写库）
+	Import(ctx context.Context, archive *model.Archive, conflict string, dryRun bool) (*model.ImportReport, error)
+	// 导入归档文件大小上限（字节）
+	MaxImportSize() int64
+}
+
+type archiveServiceImpl struct {
+	assistantRepo repository.AssistantRepo
+	archiveRepo   repository.ArchiveRepo
+	cfg           ArchiveConfig
+}
+
+func NewArchiveService(assistantRepo repository.AssistantRepo, archiveRepo repository.ArchiveRepo, cfg ArchiveConfig) ArchiveService {
+	return &archiveServiceImpl{assistantRepo: assistantRepo, archiveRepo: archiveRepo, cfg: cfg.withDefaults()}
+}
+
+// MaxImportSize 导入归档文件大小上限（字节）
+func (s *archiveServiceImpl) MaxImportSize() int64 {
+	return int64(s.cfg.MaxImportMB) << 20
+}
+
+// 导出归档
+func (s *archiveServiceImpl) Export(ctx context.Context, assistantIDs []string) (*model.Archive, error) {
+	assistants, err := s.assistantRepo.SelectAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询助手失败: %w", err)
+	}
+	byID := make(map[string]model.Assistant, len(assistants))
+	for _, a := range assistants {
+		byID[a.ID] = a
+	}
+	if len(assistantIDs) > 0 {
+		selected := make([]model.Assistant, 0, len(assistantIDs))
+		for _, id := range assistantIDs {
+			a, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("助手不存在: %s", id)
+			}
+			selected = append(selected, a)
+		}
+		assistants = selected
+	}
+
+	archive := &model.Archive{
+		Version:    model.ArchiveVersion,
+		ExportedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Assistants: make([]model.ArchivedAssistant, 0, len(assistants)),
+	}
+	for _, a := range assistants {
+		histories, err := s.archiveRepo.SelectHistories(ctx, a.ID)
+		if err != nil {
+			return nil, err
+		}
+		archive.Assistants = append(archive.Assistants, model.ArchivedAssistant{Assistant: a, Histories: histories})
+	}
+	return archive, nil
+}
+
+// 导入归档（整个归档在一个事务中写入，失败时不留下部分数据）
+func (s *archiveServiceImpl) Import(ctx context.Context, archive *model.Archive, conflict string, dryRun bool) (*model.ImportReport, error) {
+	if conflict == "" {
+		conflict = model.ConflictSkip
+	}
+	if conflict != model.ConflictSkip && conflict != model.ConflictOverwrite && conflict != model.ConflictNewID {
+		return nil, ErrInvalidConflict
+	}
+	if err := validateArchive(archive); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.assistantRepo.SelectAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询助手失败: %w", err)
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, a := range existing {
+		exists[a.ID] = true
+	}
+
+	report := &model.ImportReport{DryRun: dryRun, Items: make([]model.ImportItem, 0, len(archive.Assistants))}
+	var restore []model.ArchivedAssistant
+	for _, a := range archive.Assistants {
+		item := model.ImportItem{SourceID: a.ID, ID: a.ID, Name: a.Name, Action: model.ImportCreated, Messages: countMessages(a.Histories)}
+		if exists[a.ID] {
+			switch conflict {
+			case model.ConflictSkip:
+				item.Action = model.ImportSkipped
+				report.Skipped++
+				report.Items = append(report.Items, item)
+				continue
+			case model.ConflictOverwrite:
+				item.Action = model.ImportOverwritten
+			case model.ConflictNewID:
+				item.ID = uuid.New().String()
+			}
+		}
+		if item.Action == model.ImportOverwritten {
+			report.Overwritten++
+		} else {
+			report.Created++
+		}
+		report.Items = append(report.Items, item)
+
+		a.ID = item.ID
+		if a.Visibility == "" {
+			a.Visibility = model.VisibilityPrivate
+		}
+		for i := range a.Histories {
+			a.Histories[i].AssistantID = item.ID
+		}
+		restore = append(restore, a)
+	}
+
+	if dryRun || len(restore) == 0 {
+		return report, nil
+	}
+	if err := s.archiveRepo.Restore(ctx, restore); err != nil {
+		return nil, fmt.Errorf("导入失败: %w", err)
+	}
+	return report, nil
+}
+
+// validateArchive 校验版本与内容，一次性列出所有问题
+func validateArchive(archive *model.Archive) error {
+	if archive == nil {
+		return fmt.Errorf("%w: 内容为空", ErrInvalidArchive)
+	}
+	switch {
+	case archive.Version == 0:
+		return fmt.Errorf("%w: 缺少version字段", ErrInvalidArchive)
+	case archive.Version > model.ArchiveVersion:
+		return fmt.Errorf("%w: 归档版本%d高于当前支持的版本%d，请升级服务后再导入", ErrInvalidArchive, archive.Version, model.ArchiveVersion)
+	case archive.Version < 1:
+		return fmt.Errorf("%w: 未知的归档版本%d", ErrInvalidArchive, archive.Version)
+	}
+
+	var problems []string
+	seen := make(map[string]bool, len(archive.Assistants))
+	for i, a := range archive.Assistants {
+		field := fmt.Sprintf("assistants[%d]", i)
+		if _, err := uuid.Parse(a.ID); err != nil {
+			problems = append(problems, field+".id: 不是合法的UUID")
+		} else if seen[a.ID] {
+			problems = append(problems, field+".id: 与其他助手重复")
+		}
+		seen[a.ID] = true
+		if a.Name == "" {
+			problems = append(problems, field+".name: 不能为空")
+		}
+		if a.Prompt == "" {
+			problems = append(problems, field+".prompt: 不能为空")
+		} else if err := ValidatePrompt(a.Prompt); err != nil {
+			problems = append(problems, field+".prompt: "+err.Error())
+		}
+		if a.Visibility != "" && !isValidVisibility(a.Visibility) {
+			problems = append(problems, field+".visibility: 可选值：private、shared、public")
+		}
+		users := make(map[string]bool, len(a.Histories))
+		for j, h := range a.Histories {
+			if users[h.UserID] {
+				problems = append(problems, fmt.Sprintf("%s.histories[%d].user_id: 同一用户的会话重复", field, j))
+			}
+			users[h.UserID] = true
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w（%d项）:\n  - %s", ErrInvalidArchive, len(problems), strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+func countMessages(histories []model.History) int {
+	n := 0
+	for _, h := range histories {
+		n += len(h.Messages)
+	}
+	return n
+}
@@ -1,16 +1,27 @@
 package service
 
 import (
+	"Voice_Assistant/internal/auth"
 	"Voice_Assistant/internal/model"
 	"Voice_Assistant/internal/repository"
 	"context"
+	"database/sql"
 	"errors"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrManagedAssistant 由YAML文件声明的助手只能通过修改文件变更
+var ErrManagedAssistant = errors.New("该助手由配置文件管理，只读，请修改对应的YAML文件")
+
+// ErrAssistantNotOwner 共享或公开的助手对其他用户可见，但只有所有者（及管理员）可以修改
+var ErrAssistantNotOwner = errors.New("只有助手的所有者可以修改该助手")
+
+// ErrAssistantConflict 读取后助手已被其他请求修改（版本号不一致）
+var ErrAssistantConflict = errors.New("助手已被其他请求修改，请刷新后重试")
+
 // AssistantService 定义业务接口（包含业务逻辑）
 type AssistantService interface {
 	// 查询所有助手（可添加业务过滤逻辑）
@@ -21,6 +32,12 @@ type AssistantService interface {
 	Save(ctx context.Context, assistant *model.Assistant) (*model.Assistant, error)
 	// 更新助手（包含业务校验，如更新权限、数据合法性）
 	UpdateByID(ctx context.Context, id string, assistant *model.Assistant) (*model.Assistant, error)
+	// 版本历史（按版本号倒序）
+	ListRevisions(ctx context.Context, id string) ([]model.AssistantRevision, error)
+	// 比较两个版本（to为0时与当前版本比较）
+	DiffRevisions(ctx context.Context, id string, from, to int) (*model.RevisionDiff, error)
+	// 回滚到指定版本（以该版本内容生成新版本，历史版本保留）
+	Rollback(ctx context.Context, id string, revision int) (*model.Assistant, error)
 }
 
 // assistantServiceImpl 实现业务逻辑
@@ -59,6 +76,9 @@ func (s *assistantServiceImpl) DeleteByID(ctx context.Context, id string) error
 	exists := false
 	for _, a := range assistants {
 		if a.ID == id {
+			if a.Source != "" {
+				return ErrManagedAssistant
+			}
 			exists = true
 			break
 		}
@@ -85,6 +105,20 @@ func (s *assistantServiceImpl) Save(ctx context.Context, assistant *model.Assist
 	if assistant.Prompt == "" {
 		return nil, errors.New("assistant prompt is required")
 	}
+	if err := ValidatePrompt(assistant.Prompt); err != nil {
+		return nil, err
+	}
+	// 业务校验2：可见性（默认仅所有者可见）
+	if assistant.Visibility == "" {
+		assistant.Visibility = model.VisibilityPrivate
+	}
+	if !isValidVisibility(assistant.Visibility) {
+		return nil, errors.New("invalid visibility, must be private, shared or public")
+	}
+
+	// 业务逻辑：所有者为当前用户（通过API创建的助手不受配置文件管理）
+	assistant.OwnerID = auth.UserID(ctx)
+	assistant.Source = ""
 
 	// 业务逻辑：生成UUID（业务层负责ID生成，而非数据层）
 	id := uuid.New().String()
@@ -95,6 +129,7 @@ func (s *assistantServiceImpl) Save(ctx context.Context, assistant *model.Assist
 	assistant.GmtCreate = now
 	assistant.GmtModified = now
 	assistant.TimeStamp = now
+	assistant.Revision = 1
 
 	// 调用数据层保存
 	saved, err := s.assistantRepo.Save(ctx, assistant)
@@ -102,27 +137,14 @@ func (s *assistantServiceImpl) Save(ctx context.Context, assistant *model.Assist
 		return nil, errors.New("failed to save assistant: " + err.Error())
 	}
 
-	// 添加默认欢迎消息
-	defaultMessage := model.Message{
-		Input: model.Input{
-			Prompt: saved.Prompt,
-			Send:   "",
-		},
-		Output: model.Output{
-			FinishReason: "stop",
-			Content:      "欢迎使用" + saved.Name + "！我已准备好为你提供帮助~",
-		},
-		Usage: model.Usage{
-			InputTokens:  0,
-			OutputTokens: 0,
-			TotalTokens:  0,
-		},
-		GmtCreate: saved.GmtCreate, // 与助手创建时间一致
+	if err := s.assistantRepo.SaveRevision(ctx, newRevision(ctx, saved, "创建")); err != nil {
+		return nil, errors.New("failed to save assistant revision: " + err.Error())
 	}
 
-	if err := s.historyService.SaveByAssistantID(ctx, saved.ID, defaultMessage); err != nil {
+	// 添加默认欢迎消息
+	if err := s.historyService.SaveByAssistantID(ctx, saved.ID, welcomeMessage(saved)); err != nil {
 		// 注意：默认消息添加失败不影响助手创建，仅记录警告日志
-		log.Printf("警告：助手创建成功，但默认消息添加失败: %v", err)
+		slog.WarnContext(ctx, "助手创建成功，但默认消息添加失败", "assistant_id", saved.ID, "error", err)
 	}
 
 	return saved, nil
@@ -138,6 +160,12 @@ func (s *assistantServiceImpl) UpdateByID(ctx context.Context, id string, assist
 	if assistant.Name == "" {
 		return nil, errors.New("assistant name cannot be empty")
 	}
+	if err := ValidatePrompt(assistant.Prompt); err != nil {
+		return nil, err
+	}
+	if assistant.Visibility != "" && !isValidVisibility(assistant.Visibility) {
+		return nil, errors.New("invalid visibility, must be private, shared or public")
+	}
 
 	// 业务逻辑：查询原数据（确保存在）
 	assistants, err := s.assistantRepo.SelectAll(ctx)
@@ -156,6 +184,29 @@ func (s *assistantServiceImpl) UpdateByID(ctx context.Context, id string, assist
 	if !found {
 		return nil, errors.New("assistant not found")
 	}
+	if original.Source != "" {
+		return nil, ErrManagedAssistant
+	}
+	if err := checkOwner(ctx, &original); err != nil {
+		return nil, err
+	}
+
+	// 未传可见性时保持原值
+	visibility, sharedWith := original.Visibility, original.SharedWith
+	if assistant.Visibility != "" {
+		visibility = assistant.Visibility
+	}
+	if assistant.SharedWith != nil {
+		sharedWith = assistant.SharedWith
+	}
+	// 未传模型参数、工具列表时保持原值
+	modelParams, tools := original.ModelParams, original.Tools
+	if assistant.ModelParams != (model.ModelParams{}) {
+		modelParams = assistant.ModelParams
+	}
+	if assistant.Tools != nil {
+		tools = assistant.Tools
+	}
 
 	// 业务逻辑：更新字段（只允许更新指定字段，避免非法修改）
 	updated := model.Assistant{
@@ -166,12 +217,57 @@ func (s *assistantServiceImpl) UpdateByID(ctx context.Context, id string, assist
 		GmtCreate:   original.GmtCreate,                       // 创建时间不可改
 		GmtModified: time.Now().Format("2006-01-02 15:04:05"), // 更新修改时间
 		TimeStamp:   time.Now().Format("2006-01-02 15:04:05"), // 同步时间戳
+		OwnerID:     original.OwnerID,                         // 所有者不可改
+		Visibility:  visibility,                               // 允许更新可见性
+		SharedWith:  sharedWith,                               // 允许更新共享用户
+		ModelParams: modelParams,                              // 允许更新模型参数
+		Tools:       tools,                                    // 允许更新工具列表
+		Revision:    original.Revision,                        // 内容变化时递增
 	}
+	return s.update(ctx, &original, &updated, "更新")
+}
 
-	// 调用数据层执行更新
-	result, err := s.assistantRepo.UpdateByID(ctx, id, &updated)
+// checkOwner 校验当前用户可以修改助手（调用方已确认助手可见）；
+// 写入时的所有者条件不再区分无权修改与并发修改，sql.ErrNoRows只表示版本号已变化
+func checkOwner(ctx context.Context, a *model.Assistant) error {
+	if !auth.IsAdmin(ctx) && a.OwnerID != auth.UserID(ctx) {
+		return ErrAssistantNotOwner
+	}
+	return nil
+}
+
+// update 写入更新，提示词或参数变化时在同一事务中生成新版本（读取后被并发修改时返回ErrAssistantConflict）
+func (s *assistantServiceImpl) update(ctx context.Context, original, updated *model.Assistant, note string) (*model.Assistant, error) {
+	var revision *model.AssistantRevision
+	if revisionChanged(original, updated) {
+		updated.Revision = original.Revision + 1
+		revision = newRevision(ctx, updated, note)
+	}
+	err := s.assistantRepo.UpdateWithRevision(ctx, updated, original.Revision, revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAssistantConflict
+	}
 	if err != nil {
 		return nil, errors.New("failed to update assistant: " + err.Error())
 	}
-	return result, nil
+	return updated, nil
+}
+
+func isValidVisibility(v string) bool {
+	return v == model.VisibilityPrivate || v == model.VisibilityShared || v == model.VisibilityPublic
+}
+
+// welcomeMessage 新助手的默认欢迎消息（与助手创建时间一致）
+func welcomeMessage(a *model.Assistant) model.Message {
+	return model.Message{
+		Input: model.Input{
+			Prompt: a.Prompt,
+			Send:   "",
+		},
+		Output: model.Output{
+			FinishReason: "stop",
+			Content:      "欢迎使用" + a.Name + "！我已准备好为你提供帮助~",
+		},
+		GmtCreate: a.GmtCreate,
+	}
 }
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrRevisionNotFound = errors.New("版本不存在")
+
+// 版本历史
+func (s *assistantServiceImpl) ListRevisions(ctx context.Context, id string) ([]model.AssistantRevision, error) {
+	if _, err := s.findAssistant(ctx, id); err != nil {
+		return nil, err
+	}
+	revisions, err := s.assistantRepo.SelectRevisions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("查询版本失败: %w", err)
+	}
+	return revisions, nil
+}
+
+// 比较两个版本
+func (s *assistantServiceImpl) DiffRevisions(ctx context.Context, id string, from, to int) (*model.RevisionDiff, error) {
+	a, err := s.findAssistant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if to == 0 {
+		to = a.Revision
+	}
+	revisions, err := s.assistantRepo.SelectRevisions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("查询版本失败: %w", err)
+	}
+	old, err := findRevision(revisions, from)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := findRevision(revisions, to)
+	if err != nil {
+		return nil, err
+	}
+	fields := diffAssistant(revisionAssistant(old), revisionAssistant(cur))
+	if fields == nil {
+		fields = []model.FieldDiff{}
+	}
+	return &model.RevisionDiff{From: from, To: to, Fields: fields}, nil
+}
+
+// 回滚到指定版本（内容与当前一致时不生成新版本）
+func (s *assistantServiceImpl) Rollback(ctx context.Context, id string, revision int) (*model.Assistant, error) {
+	original, err := s.findAssistant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if original.Source != "" {
+		return nil, ErrManagedAssistant
+	}
+	if err := checkOwner(ctx, original); err != nil {
+		return nil, err
+	}
+	revisions, err := s.assistantRepo.SelectRevisions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("查询版本失败: %w", err)
+	}
+	target, err := findRevision(revisions, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *original
+	updated.Name = target.Name
+	updated.Description = target.Description
+	updated.Prompt = target.Prompt
+	updated.ModelParams = target.ModelParams
+	updated.Tools = target.Tools
+	updated.GmtModified = time.Now().Format("2006-01-02 15:04:05")
+	updated.TimeStamp = updated.GmtModified
+	return s.update(ctx, original, &updated, fmt.Sprintf("回滚到版本%d", revision))
+}
+
+// findAssistant 查询当前用户可见的助手
+func (s *assistantServiceImpl) findAssistant(ctx context.Context, id string) (*model.Assistant, error) {
+	assistants, err := s.assistantRepo.SelectAll(ctx)
+	if err != nil {
+		return nil, errors.New("failed to get assistants: " + err.Error())
+	}
+	for _, a := range assistants {
+		if a.ID == id {
+			return &a, nil
+		}
+	}
+	return nil, errors.New("assistant not found")
+}
+
+// newRevision 以助手当前内容生成版本记录
+func newRevision(ctx context.Context, a *model.Assistant, note string) *model.AssistantRevision {
+	return &model.AssistantRevision{
+		AssistantID: a.ID,
+		Revision:    a.Revision,
+		Name:        a.Name,
+		Description: a.Description,
+		Prompt:      a.Prompt,
+		ModelParams: a.ModelParams,
+		Tools:       a.Tools,
+		AuthorID:    auth.UserID(ctx),
+		Note:        note,
+		GmtCreate:   time.Now().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// revisionAssistant 版本内容（只含纳入版本管理的字段）
+func revisionAssistant(r *model.AssistantRevision) *model.Assistant {
+	return &model.Assistant{
+		Name:        r.Name,
+		Description: r.Description,
+		Prompt:      r.Prompt,
+		ModelParams: r.ModelParams,
+		Tools:       r.Tools,
+	}
+}
+
+// revisionChanged 纳入版本管理的字段（名称、描述、提示词、模型参数、工具）是否变化
+func revisionChanged(old, a *model.Assistant) bool {
+	return len(diffAssistant(versioned(old), versioned(a))) > 0
+}
+
+func versioned(a *model.Assistant) *model.Assistant {
+	return &model.Assistant{
+		Name:        a.Name,
+		Description: a.Description,
+		Prompt:      a.Prompt,
+		ModelParams: a.ModelParams,
+		Tools:       a.Tools,
+	}
+}
+
+func findRevision(revisions []model.AssistantRevision, revision int) (*model.AssistantRevision, error) {
+	for i := range revisions {
+		if revisions[i].Revision == revision {
+			return &revisions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d", ErrRevisionNotFound, revision)
+}
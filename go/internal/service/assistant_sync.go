@@ -0,0 +1,379 @@
+package service
+
+import (
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/repository"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidAssistantFile = errors.New("助手定义文件不合法")
+
+// 由文件名生成稳定ID的命名空间（同一文件在任何机器上得到相同ID）
+var assistantNamespace = uuid.MustParse("6f1c1d2a-5b8e-4c39-9f0e-2d7a4b8c3e51")
+
+// AssistantSyncService 将目录中的YAML助手定义同步到数据库（assistants-as-code）
+type AssistantSyncService interface {
+	// 比较文件与数据库并执行变更（dryRun时只返回计划，不写库）
+	Sync(ctx context.Context, dryRun bool) (*model.SyncPlan, error)
+}
+
+type assistantSyncServiceImpl struct {
+	dir            string
+	prune          bool // 文件删除后是否同时删除助手（否则仅解除管理）
+	assistantRepo  repository.AssistantRepo
+	historyService HistoryService
+	llmService     LLMService
+}
+
+func NewAssistantSyncService(dir string, prune bool, assistantRepo repository.AssistantRepo, historyService HistoryService, llmService LLMService) AssistantSyncService {
+	return &assistantSyncServiceImpl{
+		dir:            dir,
+		prune:          prune,
+		assistantRepo:  assistantRepo,
+		historyService: historyService,
+		llmService:     llmService,
+	}
+}
+
+// assistantFile YAML文件中的助手定义
+type assistantFile struct {
+	ID          string   `yaml:"id"` // 可选，默认由文件名生成
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Prompt      string   `yaml:"prompt"`
+	Visibility  string   `yaml:"visibility"` // 默认public
+	SharedWith  []string `yaml:"shared_with"`
+	Model       struct {
+		Name        string   `yaml:"name"`
+		MaxTokens   int      `yaml:"max_tokens"`
+		Temperature *float64 `yaml:"temperature"`
+	} `yaml:"model"`
+	Tools     []string `yaml:"tools"`      // 省略为全部工具，[]为不使用工具
+	Preamble  *string  `yaml:"preamble"`   // 省略时使用全局配置，""为不使用
+	ToolGuide *string  `yaml:"tool_guide"` // 同上
+	Adopt     bool     `yaml:"adopt"`      // 接管id相同、通过API创建的助手（默认不覆盖）
+}
+
+// Sync 同步声明式助手
+func (s *assistantSyncServiceImpl) Sync(ctx context.Context, dryRun bool) (*model.SyncPlan, error) {
+	declared, adopt, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.assistantRepo.SelectAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询助手失败: %w", err)
+	}
+	byID := make(map[string]model.Assistant, len(existing))
+	for _, a := range existing {
+		byID[a.ID] = a
+	}
+
+	plan := &model.SyncPlan{Dir: s.dir, DryRun: dryRun, Changes: []model.SyncChange{}}
+	seen := make(map[string]bool, len(declared))
+	for i := range declared {
+		a := &declared[i]
+		seen[a.ID] = true
+		change := model.SyncChange{Action: model.SyncCreate, ID: a.ID, Name: a.Name, Source: a.Source}
+		if old, ok := byID[a.ID]; ok {
+			change.Fields = diffAssistant(&old, a)
+			change.Action = model.SyncUnchanged
+			if len(change.Fields) > 0 {
+				change.Action = model.SyncUpdate
+			}
+			// 通过API创建的助手不会被静默覆盖并变为只读
+			if old.Source == "" && !adopt[a.ID] {
+				change.Action = model.SyncConflict
+			}
+		}
+		plan.Changes = append(plan.Changes, change)
+		if dryRun || change.Action == model.SyncConflict {
+			continue
+		}
+		if err := s.apply(ctx, change.Action, a, byID[a.ID]); err != nil {
+			return nil, fmt.Errorf("同步%s失败: %w", a.Source, err)
+		}
+	}
+
+	// 文件已删除的助手：解除管理，或在prune时删除
+	for _, old := range existing {
+		if old.Source == "" || seen[old.ID] {
+			continue
+		}
+		change := model.SyncChange{Action: model.SyncRelease, ID: old.ID, Name: old.Name, Source: old.Source}
+		if s.prune {
+			change.Action = model.SyncDelete
+		}
+		plan.Changes = append(plan.Changes, change)
+		if dryRun {
+			continue
+		}
+		if err := s.apply(ctx, change.Action, nil, old); err != nil {
+			return nil, fmt.Errorf("同步%s失败: %w", old.Source, err)
+		}
+	}
+	return plan, nil
+}
+
+// apply 执行单个变更
+func (s *assistantSyncServiceImpl) apply(ctx context.Context, action string, a *model.Assistant, old model.Assistant) error {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	switch action {
+	case model.SyncCreate:
+		a.GmtCreate, a.GmtModified, a.TimeStamp = now, now, now
+		a.Revision = 1
+		saved, err := s.assistantRepo.Save(ctx, a)
+		if err != nil {
+			return err
+		}
+		if err := s.assistantRepo.SaveRevision(ctx, newRevision(ctx, saved, "同步自"+a.Source)); err != nil {
+			return err
+		}
+		return s.historyService.SaveByAssistantID(ctx, saved.ID, welcomeMessage(saved))
+	case model.SyncUpdate:
+		a.GmtCreate, a.GmtModified, a.TimeStamp = old.GmtCreate, now, now
+		a.OwnerID = old.OwnerID
+		a.Revision = old.Revision
+		var revision *model.AssistantRevision
+		if revisionChanged(&old, a) {
+			a.Revision++
+			revision = newRevision(ctx, a, "同步自"+a.Source)
+		}
+		if err := s.assistantRepo.UpdateWithRevision(ctx, a, old.Revision, revision); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrAssistantConflict
+			}
+			return err
+		}
+		return nil
+	case model.SyncRelease:
+		old.Source = ""
+		old.GmtModified = now
+		_, err := s.assistantRepo.UpdateByID(ctx, old.ID, &old)
+		return err
+	case model.SyncDelete:
+		return s.assistantRepo.DeleteByID(ctx, old.ID)
+	}
+	return nil
+}
+
+// load 读取目录下的所有YAML文件（按文件名排序），一次性列出所有问题；同时返回声明adopt的助手ID
+func (s *assistantSyncServiceImpl) load() ([]model.Assistant, map[string]bool, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取助手定义目录失败: %w", err)
+	}
+	tools := s.llmService.ToolNames()
+
+	var assistants []model.Assistant
+	var problems []string
+	sources := make(map[string]string)
+	adopt := make(map[string]bool)
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		a, adopted, errs := s.loadFile(e.Name(), tools)
+		for _, msg := range errs {
+			problems = append(problems, e.Name()+": "+msg)
+		}
+		if len(errs) > 0 {
+			continue
+		}
+		if other, ok := sources[a.ID]; ok {
+			problems = append(problems, fmt.Sprintf("%s: id与%s重复", e.Name(), other))
+			continue
+		}
+		sources[a.ID] = e.Name()
+		adopt[a.ID] = adopted
+		assistants = append(assistants, *a)
+	}
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("%w（%d项）:\n  - %s", ErrInvalidAssistantFile, len(problems), strings.Join(problems, "\n  - "))
+	}
+	return assistants, adopt, nil
+}
+
+// loadFile 解析并校验单个文件
+func (s *assistantSyncServiceImpl) loadFile(name string, tools []string) (*model.Assistant, bool, []string) {
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, false, []string{err.Error()}
+	}
+	var f assistantFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true) // 拼错的字段直接报错，而不是被静默忽略
+	if err := dec.Decode(&f); err != nil {
+		return nil, false, []string{"解析失败: " + err.Error()}
+	}
+
+	var problems []string
+	id := f.ID
+	if id == "" {
+		id = uuid.NewSHA1(assistantNamespace, []byte(strings.TrimSuffix(name, filepath.Ext(name)))).String()
+	} else if _, err := uuid.Parse(id); err != nil {
+		problems = append(problems, "id: 不是合法的UUID")
+	}
+	if f.Name == "" {
+		problems = append(problems, "name: 不能为空")
+	}
+	if f.Prompt == "" {
+		problems = append(problems, "prompt: 不能为空")
+	} else if err := ValidatePrompt(f.Prompt); err != nil {
+		problems = append(problems, "prompt: "+err.Error())
+	}
+	if f.Visibility == "" {
+		f.Visibility = model.VisibilityPublic
+	}
+	if !isValidVisibility(f.Visibility) {
+		problems = append(problems, "visibility: 可选值：private、shared、public")
+	}
+	if f.Model.MaxTokens < 0 {
+		problems = append(problems, "model.max_tokens: 不能为负数")
+	}
+	if t := f.Model.Temperature; t != nil && (*t < 0 || *t > 2) {
+		problems = append(problems, "model.temperature: 取值范围为0~2")
+	}
+	for _, t := range f.Tools {
+		if !slices.Contains(tools, t) {
+			problems = append(problems, fmt.Sprintf("tools: 未知工具%s，可选值：%s", t, strings.Join(tools, "、")))
+		}
+	}
+
+	return &model.Assistant{
+		ID:          id,
+		Name:        f.Name,
+		Description: f.Description,
+		Prompt:      f.Prompt,
+		Visibility:  f.Visibility,
+		SharedWith:  f.SharedWith,
+		ModelParams: model.ModelParams{
+			Model:       f.Model.Name,
+			MaxTokens:   f.Model.MaxTokens,
+			Temperature: f.Model.Temperature,
+			Preamble:    f.Preamble,
+			ToolGuide:   f.ToolGuide,
+		},
+		Tools:  f.Tools,
+		Source: name,
+	}, f.Adopt, problems
+}
+
+// diffAssistant 比较数据库中的助手与文件定义，返回变化的字段
+func diffAssistant(old, a *model.Assistant) []model.FieldDiff {
+	fields := []struct{ name, old, new string }{
+		{"name", old.Name, a.Name},
+		{"description", old.Description, a.Description},
+		{"prompt", old.Prompt, a.Prompt},
+		{"visibility", old.Visibility, a.Visibility},
+		{"shared_with", strings.Join(old.SharedWith, ","), strings.Join(a.SharedWith, ",")},
+		{"model", formatModelParams(old.ModelParams), formatModelParams(a.ModelParams)},
+		{"tools", formatTools(old.Tools), formatTools(a.Tools)},
+		{"preamble", formatSystemPrompt(old.ModelParams.Preamble), formatSystemPrompt(a.ModelParams.Preamble)},
+		{"tool_guide", formatSystemPrompt(old.ModelParams.ToolGuide), formatSystemPrompt(a.ModelParams.ToolGuide)},
+		{"source", old.Source, a.Source},
+	}
+	var diffs []model.FieldDiff
+	for _, f := range fields {
+		if f.old == f.new {
+			continue
+		}
+		d := model.FieldDiff{Field: f.name, Old: f.old, New: f.new}
+		if strings.Contains(f.old, "\n") || strings.Contains(f.new, "\n") {
+			d.Diff = DiffLines(f.old, f.new)
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+func formatModelParams(p model.ModelParams) string {
+	var parts []string
+	if p.Model != "" {
+		parts = append(parts, "name="+p.Model)
+	}
+	if p.MaxTokens > 0 {
+		parts = append(parts, "max_tokens="+strconv.Itoa(p.MaxTokens))
+	}
+	if p.Temperature != nil {
+		parts = append(parts, "temperature="+strconv.FormatFloat(*p.Temperature, 'f', -1, 64))
+	}
+	if len(parts) == 0 {
+		return "（默认）"
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatSystemPrompt(p *string) string {
+	switch {
+	case p == nil:
+		return "（全局配置）"
+	case *p == "":
+		return "（不使用）"
+	}
+	return *p
+}
+
+func formatTools(tools []string) string {
+	switch {
+	case tools == nil:
+		return "（全部）"
+	case len(tools) == 0:
+		return "（无）"
+	}
+	sorted := slices.Clone(tools)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// DiffLines 逐行对比（最长公共子序列，同一位置先删后增），行首为"  "、"- "或"+ "
+func DiffLines(old, new string) []string {
+	a := strings.Split(strings.TrimSuffix(old, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(new, "\n"), "\n")
+	// lcs[i][j]为a[i:]与b[j:]的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out = append(out, "  "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, "- "+a[i])
+			i++
+		default:
+			out = append(out, "+ "+b[j])
+			j++
+		}
+	}
+	return out
+}
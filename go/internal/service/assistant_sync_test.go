@@ -0,0 +1,51 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+	}{
+		{"相同", "a\nb\n", "a\nb", []string{"  a", "  b"}},
+		{"新增行", "a\nc", "a\nb\nc", []string{"  a", "+ b", "  c"}},
+		{"删除行", "a\nb\nc", "a\nc", []string{"  a", "- b", "  c"}},
+		{"修改行先删后增", "a\nb\nc", "a\nB\nc", []string{"  a", "- b", "+ B", "  c"}},
+		{"全部替换", "x\ny", "p\nq", []string{"- x", "- y", "+ p", "+ q"}},
+		{"末尾追加", "a", "a\nb\nc", []string{"  a", "+ b", "+ c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffLines(tt.old, tt.new)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("DiffLines =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestDiffLinesReconstruct(t *testing.T) {
+	old := "标题\n第一段\n第二段\n第三段\n结尾"
+	new := "标题\n第一段（修订）\n第二段\n新增段落\n结尾\n附录"
+	var gotOld, gotNew []string
+	for _, line := range DiffLines(old, new) {
+		prefix, text := line[:2], line[2:]
+		if prefix != "+ " {
+			gotOld = append(gotOld, text)
+		}
+		if prefix != "- " {
+			gotNew = append(gotNew, text)
+		}
+	}
+	// 去掉"+"行得到旧文本，去掉"-"行得到新文本
+	if strings.Join(gotOld, "\n") != old {
+		t.Errorf("还原旧文本 = %q, want %q", strings.Join(gotOld, "\n"), old)
+	}
+	if strings.Join(gotNew, "\n") != new {
+		t.Errorf("还原新文本 = %q, want %q", strings.Join(gotNew, "\n"), new)
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/data/sqlite"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/repository"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestUpdateByIDNotOwner(t *testing.T) {
+	db, err := sqlite.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer db.Close()
+	repo := repository.NewAssistantRepo(db)
+	svc := NewAssistantService(repo, nil)
+
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: "u1"})
+	other := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: "u2"})
+	admin := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: "admin", Admin: true})
+
+	id := uuid.NewString()
+	if _, err := repo.Save(owner, &model.Assistant{ID: id, Name: "公开助手", Prompt: "p", OwnerID: "u1", Visibility: model.VisibilityPublic, Revision: 1}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// 其他用户能看到公开助手，但修改与回滚返回无权修改而不是并发冲突
+	if _, err := svc.UpdateByID(other, id, &model.Assistant{Name: "改名", Prompt: "p2"}); !errors.Is(err, ErrAssistantNotOwner) {
+		t.Errorf("非所有者更新 err = %v, want ErrAssistantNotOwner", err)
+	}
+	if _, err := svc.Rollback(other, id, 1); !errors.Is(err, ErrAssistantNotOwner) {
+		t.Errorf("非所有者回滚 err = %v, want ErrAssistantNotOwner", err)
+	}
+
+	for _, ctx := range []context.Context{owner, admin} {
+		if _, err := svc.UpdateByID(ctx, id, &model.Assistant{Name: "改名", Prompt: "p2"}); err != nil {
+			t.Errorf("UpdateByID(%s): %v", auth.UserID(ctx), err)
+		}
+	}
+}
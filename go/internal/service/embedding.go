@@ -0,0 +1,293 @@
+package service
+
+import (
+	"Voice_Assistant/internal/metrics"
+	"Voice_Assistant/internal/tracing"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Embedder 文本向量化接口
+type Embedder interface {
+	// 返回与texts一一对应的向量
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// 模型名称（向量只能与同一模型生成的向量比较）
+	Model() string
+}
+
+// 向量提供方
+const (
+	EmbeddingHTTP  = "http"  // OpenAI兼容的/embeddings接口（DashScope兼容模式同样适用）
+	EmbeddingLocal = "local" // 本地哈希向量（离线运行与测试使用）
+	EmbeddingNone  = "none"  // 不启用
+)
+
+// IsValidEmbeddingProvider 是否为支持的向量提供方（空值按http处理）
+func IsValidEmbeddingProvider(p string) bool {
+	switch p {
+	case "", EmbeddingHTTP, EmbeddingLocal, EmbeddingNone:
+		return true
+	}
+	return false
+}
+
+// EmbeddingConfig 向量配置
+type EmbeddingConfig struct {
+	Provider   string `yaml:"provider"` // http/local/none，为空时按http处理
+	APIKey     string `yaml:"api_key"`
+	BaseURL    string `yaml:"base_url"`
+	Model      string `yaml:"model"`
+	Dimensions int    `yaml:"dimensions"`  // 向量维度（http为0时使用模型默认维度，local默认256）
+	BatchSize  int    `yaml:"batch_size"`  // 每次请求最多文本数（为0时默认10，与DashScope上限一致）
+	MaxRetries int    `yaml:"max_retries"` // 网络错误、429与5xx时的重试次数
+	TimeoutSec int    `yaml:"timeout_sec"`
+}
+
+const (
+	defaultEmbeddingBatch = 10
+	defaultHashDimensions = 256
+	embeddingBackoff      = 500 * time.Millisecond // 首次重试等待时长，之后每次翻倍
+)
+
+// NewEmbedder 按配置创建向量接口（未启用时返回nil；http未配置base_url视为未启用）
+func NewEmbedder(cfg EmbeddingConfig) Embedder {
+	switch cfg.Provider {
+	case EmbeddingLocal:
+		return NewHashEmbedder(cfg.Dimensions)
+	case EmbeddingNone:
+		return nil
+	}
+	if cfg.BaseURL == "" {
+		return nil
+	}
+	return NewHTTPEmbedder(cfg)
+}
+
+// httpEmbedder OpenAI兼容的/embeddings接口客户端（分批请求，可重试错误按指数退避重试）
+type httpEmbedder struct {
+	client *http.Client
+	cfg    EmbeddingConfig
+}
+
+// NewHTTPEmbedder 创建向量接口客户端
+func NewHTTPEmbedder(cfg EmbeddingConfig) Embedder {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultEmbeddingBatch
+	}
+	return &httpEmbedder{
+		client: &http.Client{Timeout: time.Duration(cfg.TimeoutSec) * time.Second},
+		cfg:    cfg,
+	}
+}
+
+func (e *httpEmbedder) Model() string {
+	return e.cfg.Model
+}
+
+// Embed 按batch_size分批调用向量接口，任一批失败则整体失败
+func (e *httpEmbedder) Embed(ctx context.Context, texts []string) (_ [][]float32, err error) {
+	ctx, span := tracing.Start(ctx, "embedding.Embed",
+		attribute.String("embedding.model", e.cfg.Model),
+		attribute.Int("embedding.texts", len(texts)),
+	)
+	defer func() { tracing.EndWithError(span, err) }()
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += e.cfg.BatchSize {
+		batch := texts[start:min(start+e.cfg.BatchSize, len(texts))]
+		got, err := e.embedBatch(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("第%d~%d条文本: %w", start+1, start+len(batch), err)
+		}
+		vectors = append(vectors, got...)
+	}
+	for i, v := range vectors {
+		if len(v) != len(vectors[0]) {
+			return nil, fmt.Errorf("向量接口返回的维度不一致（第1条%d维，第%d条%d维）", len(vectors[0]), i+1, len(v))
+		}
+	}
+	return vectors, nil
+}
+
+// embedBatch 单批请求（可重试错误最多重试max_retries次）
+func (e *httpEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	for attempt := 0; ; attempt++ {
+		vectors, retryable, err := e.request(ctx, texts)
+		if err == nil || !retryable || attempt >= e.cfg.MaxRetries {
+			return vectors, err
+		}
+		wait := embeddingBackoff << attempt
+		slog.WarnContext(ctx, "向量接口请求失败（将重试）", "attempt", attempt+1, "wait", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// request 发起一次请求，返回错误是否可重试（网络错误、429、5xx）
+func (e *httpEmbedder) request(ctx context.Context, texts []string) (_ [][]float32, retryable bool, err error) {
+	start := time.Now()
+	status := "ok"
+	defer func() { metrics.ObserveEmbedding(e.cfg.Model, status, start) }()
+
+	body := map[string]interface{}{
+		"model":           e.cfg.Model,
+		"input":           texts,
+		"encoding_format": "float",
+	}
+	if e.cfg.Dimensions > 0 {
+		body["dimensions"] = e.cfg.Dimensions
+	}
+	reqBytes, err := json.Marshal(body)
+	if err != nil {
+		status = "encode"
+		return nil, false, fmt.Errorf("序列化请求失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.BaseURL, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		status = "request"
+		return nil, false, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.cfg.APIKey)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		status = errorStatus(ctx)
+		return nil, ctx.Err() == nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		status = strconv.Itoa(resp.StatusCode)
+		retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		return nil, retryable, fmt.Errorf("向量接口错误: %d, 内容: %s", resp.StatusCode, upstreamBody(respBody))
+	}
+
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		status = "decode"
+		return nil, false, fmt.Errorf("解析响应失败: %w", err)
+	}
+	// 按index还原顺序（接口不保证按输入顺序返回）
+	vectors := make([][]float32, len(texts))
+	for _, d := range response.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			status = "decode"
+			return nil, false, fmt.Errorf("向量接口返回了无效的序号%d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if len(v) == 0 {
+			status = "decode"
+			return nil, false, fmt.Errorf("向量接口缺少第%d条文本的结果", i+1)
+		}
+	}
+	return vectors, false, nil
+}
+
+// hashEmbedder 本地确定性向量（特征哈希）：英文与数字按单词、中文按单字及相邻二字取特征，
+// 相同文本总是得到相同向量，无需网络，适合测试与离线运行（只反映字面重合，语义效果不如模型向量）
+type hashEmbedder struct {
+	dim int
+}
+
+// NewHashEmbedder 创建本地哈希向量（dim为0时默认256维）
+func NewHashEmbedder(dim int) Embedder {
+	if dim <= 0 {
+		dim = defaultHashDimensions
+	}
+	return &hashEmbedder{dim: dim}
+}
+
+func (e *hashEmbedder) Model() string {
+	return "local-hash-" + strconv.Itoa(e.dim)
+}
+
+// Embed 计算特征哈希向量（L2归一化）
+func (e *hashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, e.dim)
+		for _, f := range hashFeatures(text) {
+			h := fnv.New64a()
+			h.Write([]byte(f))
+			sum := h.Sum64()
+			// 最高位决定符号，减小哈希冲突带来的偏差
+			if sum>>63 == 0 {
+				v[sum%uint64(e.dim)]++
+			} else {
+				v[sum%uint64(e.dim)]--
+			}
+		}
+		var norm float64
+		for _, x := range v {
+			norm += float64(x) * float64(x)
+		}
+		if norm > 0 {
+			scale := float32(1 / math.Sqrt(norm))
+			for j := range v {
+				v[j] *= scale
+			}
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+// hashFeatures 文本特征：小写单词、汉字单字与相邻二字
+func hashFeatures(text string) []string {
+	var features []string
+	var word strings.Builder
+	var prevHan rune
+	flushWord := func() {
+		if word.Len() > 0 {
+			features = append(features, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			features = append(features, string(r))
+			if prevHan != 0 {
+				features = append(features, string([]rune{prevHan, r}))
+			}
+			prevHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			word.WriteRune(r)
+		default:
+			flushWord()
+		}
+		prevHan = 0
+	}
+	flushWord()
+	return features
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHashEmbedderDeterministic(t *testing.T) {
+	e := NewHashEmbedder(64)
+	texts := []string{"用户喜欢简洁的回答", "Deploy the Go service", ""}
+	first, err := e.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	second, err := NewHashEmbedder(64).Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	for i := range texts {
+		if len(first[i]) != 64 {
+			t.Fatalf("第%d条向量维度 = %d, want 64", i, len(first[i]))
+		}
+		if !slices.Equal(first[i], second[i]) {
+			t.Errorf("相同文本%q得到不同向量", texts[i])
+		}
+	}
+	// 非空文本L2归一化，空文本为零向量
+	for i, v := range first {
+		var norm float64
+		for _, x := range v {
+			norm += float64(x) * float64(x)
+		}
+		want := 1.0
+		if texts[i] == "" {
+			want = 0
+		}
+		if math.Abs(norm-want) > 1e-5 {
+			t.Errorf("第%d条向量模长平方 = %v, want %v", i, norm, want)
+		}
+	}
+}
+
+func TestHashEmbedderModel(t *testing.T) {
+	tests := []struct {
+		dim  int
+		want string
+	}{
+		{0, "local-hash-256"},
+		{-1, "local-hash-256"},
+		{128, "local-hash-128"},
+	}
+	for _, tt := range tests {
+		if got := NewHashEmbedder(tt.dim).Model(); got != tt.want {
+			t.Errorf("NewHashEmbedder(%d).Model() = %q, want %q", tt.dim, got, tt.want)
+		}
+	}
+}
+
+func TestHashFeatures(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello, World 42", []string{"hello", "world", "42"}},
+		{"你好", []string{"你", "好", "你好"}},
+		{"Go语言", []string{"go", "语", "言", "语言"}},
+		{"长 期", []string{"长", "期"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := hashFeatures(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("hashFeatures(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// embeddingServer 模拟向量接口：每条文本返回[序号, 长度]，并倒序返回结果；
+// 前failures次请求返回status
+func embeddingServer(t *testing.T, failures int, status int) (*httptest.Server, *[][]string, *atomic.Int32) {
+	t.Helper()
+	var mu sync.Mutex
+	var batches [][]string
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(calls.Add(1)) <= failures {
+			http.Error(w, "busy", status)
+			return
+		}
+		var body struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		batches = append(batches, body.Input)
+		mu.Unlock()
+		type item struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		var data []item
+		for i := len(body.Input) - 1; i >= 0; i-- {
+			n, _ := strconv.Atoi(body.Input[i])
+			data = append(data, item{Index: i, Embedding: []float32{float32(n), float32(len(body.Input[i]))}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &batches, &calls
+}
+
+func TestHTTPEmbedderBatchesAndOrder(t *testing.T) {
+	srv, batches, _ := embeddingServer(t, 0, 0)
+	e := NewHTTPEmbedder(EmbeddingConfig{BaseURL: srv.URL, Model: "m", BatchSize: 3, TimeoutSec: 5})
+
+	texts := []string{"0", "1", "2", "3", "4", "5", "6"}
+	vectors, err := e.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	wantBatches := [][]string{{"0", "1", "2"}, {"3", "4", "5"}, {"6"}}
+	if len(*batches) != len(wantBatches) {
+		t.Fatalf("请求批次 = %q, want %q", *batches, wantBatches)
+	}
+	for i := range wantBatches {
+		if !slices.Equal((*batches)[i], wantBatches[i]) {
+			t.Errorf("第%d批 = %q, want %q", i+1, (*batches)[i], wantBatches[i])
+		}
+	}
+	// 接口倒序返回，结果仍应与输入一一对应
+	for i, v := range vectors {
+		if v[0] != float32(i) {
+			t.Errorf("第%d条向量 = %v, want 序号%d", i, v, i)
+		}
+	}
+}
+
+func TestHTTPEmbedderRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		failures   int
+		maxRetries int
+		wantErr    bool
+		wantCalls  int32
+	}{
+		{"5xx重试后成功", http.StatusServiceUnavailable, 1, 1, false, 2},
+		{"429重试后成功", http.StatusTooManyRequests, 1, 2, false, 2},
+		{"超过重试次数", http.StatusInternalServerError, 2, 1, true, 2},
+		{"4xx不重试", http.StatusBadRequest, 1, 3, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _, calls := embeddingServer(t, tt.failures, tt.status)
+			e := NewHTTPEmbedder(EmbeddingConfig{BaseURL: srv.URL, Model: "m", MaxRetries: tt.maxRetries, TimeoutSec: 5})
+			_, err := e.Embed(context.Background(), []string{"1"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Embed err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("请求次数 = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestHTTPEmbedderInvalidIndex(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"index":5,"embedding":[1,2]}]}`))
+	}))
+	defer srv.Close()
+	e := NewHTTPEmbedder(EmbeddingConfig{BaseURL: srv.URL, Model: "m", MaxRetries: 2, TimeoutSec: 5})
+	if _, err := e.Embed(context.Background(), []string{"a"}); err == nil {
+		t.Error("无效序号未返回错误")
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/model"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 会话导出格式
+const (
+	ExportJSON     = "json"     // 原始历史记录（可被 history import 导入）
+	ExportMarkdown = "markdown" // 可读的Markdown对话记录
+	ExportHTML     = "html"     // 独立的HTML页面
+	ExportJSONL    = "jsonl"    // OpenAI微调格式（system/user/assistant）
+)
+
+var ErrUnsupportedFormat = errors.New("不支持的导出格式，可选值：json、markdown、html、jsonl")
+
+// 各格式的文件扩展名与内容类型
+var exportFormats = map[string]struct{ ext, contentType string }{
+	ExportJSON:     {".json", "application/json; charset=utf-8"},
+	ExportMarkdown: {".md", "text/markdown; charset=utf-8"},
+	ExportHTML:     {".html", "text/html; charset=utf-8"},
+	ExportJSONL:    {".jsonl", "application/jsonl; charset=utf-8"},
+}
+
+// ExportFile 导出结果
+type ExportFile struct {
+	Name        string // 建议的文件名
+	ContentType string
+	Data        []byte
+}
+
+// ExportFormatByExt 按文件扩展名推断导出格式（未知扩展名按json导出）
+func ExportFormatByExt(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return ExportMarkdown
+	case ".html", ".htm":
+		return ExportHTML
+	case ".jsonl":
+		return ExportJSONL
+	default:
+		return ExportJSON
+	}
+}
+
+// 导出当前用户与助手的会话
+func (s *historyServiceImpl) Export(ctx context.Context, assistantID string, format string) (*ExportFile, error) {
+	if format == "md" {
+		format = ExportMarkdown
+	}
+	f, ok := exportFormats[format]
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+	assistant, err := s.getAssistant(ctx, assistantID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.historyRepo.SelectByAssistantID(ctx, assistantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		history, err = &model.History{AssistantID: assistantID, UserID: auth.UserID(ctx)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询历史失败: %w", err)
+	}
+
+	var data []byte
+	switch format {
+	case ExportJSON:
+		data, err = renderJSON(history)
+	case ExportMarkdown:
+		data = renderMarkdown(assistant, history)
+	case ExportHTML:
+		data, err = renderHTML(assistant, history)
+	case ExportJSONL:
+		data, err = renderJSONL(s.systemMessage(ctx, assistant, history, model.Input{}), history)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("导出失败: %w", err)
+	}
+
+	// 文件名只用ASCII，避免Content-Disposition编码问题
+	name := fmt.Sprintf("conversation-%s-%s%s", shortID(assistantID), time.Now().Format("20060102-150405"), f.ext)
+	return &ExportFile{Name: name, ContentType: f.contentType, Data: data}, nil
+}
+
+// renderJSON 原始历史记录（不转义HTML字符，便于阅读）
+func renderJSON(h *model.History) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(h)
+	return buf.Bytes(), err
+}
+
+// renderMarkdown 渲染为Markdown（含时间、工具调用与引用）
+func renderMarkdown(a *model.Assistant, h *model.History) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# 与「%s」的对话\n\n", a.Name)
+	if a.Description != "" {
+		fmt.Fprintf(&b, "> %s\n\n", a.Description)
+	}
+	fmt.Fprintf(&b, "- 助手ID：%s\n", a.ID)
+	if h.UserID != "" {
+		fmt.Fprintf(&b, "- 用户ID：%s\n", h.UserID)
+	}
+	fmt.Fprintf(&b, "- 导出时间：%s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "- 消息数：%d\n", len(h.Messages))
+
+	for _, m := range h.Messages {
+		fmt.Fprintf(&b, "\n---\n\n### %s", m.GmtCreate)
+		if m.Usage.TotalTokens > 0 {
+			fmt.Fprintf(&b, "（%d tokens）", m.Usage.TotalTokens)
+		}
+		b.WriteString("\n\n")
+		if m.Input.Send != "" {
+			fmt.Fprintf(&b, "**你：**\n\n%s\n\n", m.Input.Send)
+		}
+		for _, t := range m.ToolCalls {
+			fmt.Fprintf(&b, "> 工具调用：`%s` `%s`\n\n", t.Name, t.Arguments)
+		}
+		fmt.Fprintf(&b, "**%s：**\n\n%s\n", a.Name, m.Output.Content)
+		if len(m.Citations) > 0 {
+			b.WriteString("\n引用：\n\n")
+			for i, c := range m.Citations {
+				if c.URL != "" {
+					fmt.Fprintf(&b, "%d. [%s](%s)", i+1, c.Title, c.URL)
+				} else {
+					fmt.Fprintf(&b, "%d. %s（知识库）", i+1, c.Title)
+				}
+				if c.DatePublished != "" {
+					fmt.Fprintf(&b, " %s", c.DatePublished)
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+// 独立HTML页面（样式内联，无外部依赖）
+var htmlTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>与「{{.Assistant.Name}}」的对话</title>
+<style>
+body{font-family:-apple-system,"PingFang SC","Microsoft YaHei",sans-serif;max-width:820px;margin:2rem auto;padding:0 1rem;color:#222;background:#fafafa}
+header{border-bottom:1px solid #ddd;margin-bottom:1.5rem}
+.meta{color:#666;font-size:.85rem}
+.turn{margin:1.25rem 0}
+.time{color:#999;font-size:.8rem;margin-bottom:.4rem}
+.msg{padding:.75rem 1rem;border-radius:8px;white-space:pre-wrap;word-break:break-word;margin:.4rem 0}
+.user{background:#e8f0fe;margin-left:15%}
+.assistant{background:#fff;border:1px solid #e5e5e5;margin-right:15%}
+.role{font-weight:600;font-size:.8rem;color:#555;display:block;margin-bottom:.25rem}
+.tool{font-family:monospace;font-size:.8rem;color:#8a6d3b;background:#fcf8e3;padding:.3rem .6rem;border-radius:4px;margin:.25rem 15% .25rem 0}
+.citations{font-size:.85rem;margin:.25rem 15% 0 0}
+.citations a{color:#1a73e8}
+</style>
+</head>
+<body>
+<header>
+<h1>与「{{.Assistant.Name}}」的对话</h1>
+{{with .Assistant.Description}}<p>{{.}}</p>{{end}}
+<p class="meta">助手ID：{{.Assistant.ID}}{{with .History.UserID}} · 用户ID：{{.}}{{end}} · 导出时间：{{.ExportedAt}} · 消息数：{{len .History.Messages}}</p>
+</header>
+{{range .History.Messages}}<section class="turn">
+<div class="time">{{.GmtCreate}}{{if gt .Usage.TotalTokens 0}} · {{.Usage.TotalTokens}} tokens{{end}}</div>
+{{with .Input.Send}}<div class="msg user"><span class="role">你</span>{{.}}</div>{{end}}
+{{range .ToolCalls}}<div class="tool">工具调用：{{.Name}} {{.Arguments}}</div>{{end}}
+<div class="msg assistant"><span class="role">{{$.Assistant.Name}}</span>{{.Output.Content}}</div>
+{{with .Citations}}<ol class="citations">{{range .}}<li>{{if .URL}}<a href="{{.URL}}" target="_blank" rel="noopener">{{.Title}}</a>{{else}}{{.Title}} <span class="meta">知识库</span>{{end}}{{with .DatePublished}} <span class="meta">{{.}}</span>{{end}}</li>{{end}}</ol>{{end}}
+</section>
+{{end}}</body>
+</html>
+`))
+
+// renderHTML 渲染为独立HTML页面（内容均经过转义）
+func renderHTML(a *model.Assistant, h *model.History) ([]byte, error) {
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, map[string]any{
+		"Assistant":  a,
+		"History":    h,
+		"ExportedAt": time.Now().Format("2006-01-02 15:04:05"),
+	})
+	return buf.Bytes(), err
+}
+
+// 微调数据中的消息
+type fineTuneMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// renderJSONL 渲染为OpenAI微调格式：整段会话为一行（system为对话时实际发送的系统提示，跳过欢迎语等非对话消息）
+func renderJSONL(system string, h *model.History) ([]byte, error) {
+	var messages []fineTuneMessage
+	if system != "" {
+		messages = append(messages, fineTuneMessage{Role: "system", Content: system})
+	}
+	turns := 0
+	for _, m := range h.Messages {
+		if m.Input.Send == "" || m.Output.Content == "" {
+			continue
+		}
+		messages = append(messages,
+			fineTuneMessage{Role: "user", Content: m.Input.Send},
+			fineTuneMessage{Role: "assistant", Content: m.Output.Content},
+		)
+		turns++
+	}
+	// 没有完整对话轮次时输出空文件（不能作为训练样本）
+	if turns == 0 {
+		return []byte{}, nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(map[string]any{"messages": messages}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// 单次就绪检查的超时时间
+const readyCheckTimeout = 3 * time.Second
+
+// HealthService 健康与就绪检查业务接口
+type HealthService interface {
+	// 就绪检查：返回是否就绪及各依赖项的检查结果
+	Ready(ctx context.Context) (bool, map[string]string)
+}
+
+type healthServiceImpl struct {
+	pingDB     func(ctx context.Context) error
+	llmService LLMService
+	checkLLM   bool // 是否检查LLM可达性（外部依赖抖动会导致实例被摘除，默认关闭）
+}
+
+func NewHealthService(pingDB func(ctx context.Context) error, llmService LLMService, checkLLM bool) HealthService {
+	return &healthServiceImpl{
+		pingDB:     pingDB,
+		llmService: llmService,
+		checkLLM:   checkLLM,
+	}
+}
+
+// Ready 依次检查数据库与（可选）LLM接口
+func (s *healthServiceImpl) Ready(ctx context.Context) (bool, map[string]string) {
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+
+	ready := true
+	checks := map[string]string{"database": "ok"}
+	if err := s.pingDB(ctx); err != nil {
+		ready = false
+		checks["database"] = err.Error()
+	}
+
+	if s.checkLLM {
+		checks["llm"] = "ok"
+		if err := s.llmService.Ping(ctx); err != nil {
+			ready = false
+			checks["llm"] = err.Error()
+		}
+	}
+	return ready, checks
+}
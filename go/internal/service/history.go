@@ -1,36 +1,84 @@
 package service
 
 import (
+	"Voice_Assistant/internal/auth"
 	"Voice_Assistant/internal/model"
 	"Voice_Assistant/internal/repository"
+	"Voice_Assistant/internal/tracing"
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type HistoryService interface {
 	SelectByAssistantID(ctx context.Context, assistantID string) (*model.History, error)
 	ResetByAssistantID(ctx context.Context, assistantID string) error
 	SaveByAssistantID(ctx context.Context, assistantID string, message model.Message) error
-	StreamProcessMessage(ctx context.Context, assistantID string, input model.Input) (<-chan string, <-chan error, model.Usage, error)
+	// 返回的用量在contentChan关闭后可读取
+	StreamProcessMessage(ctx context.Context, assistantID string, input model.Input) (<-chan string, <-chan error, *model.Usage, error)
+	ProcessMessage(ctx context.Context, assistantID string, input model.Input) (*ProcessResult, error)
+	// 等待进行中的流式对话完成历史写入（用于优雅退出）
+	Wait(ctx context.Context) error
+	// 导出会话（json/markdown/html/jsonl）
+	Export(ctx context.Context, assistantID string, format string) (*ExportFile, error)
+	// 热更新全局系统提示
+	UpdateSystemPrompts(prompts SystemPrompts)
+	// 会话标题与提示词模板变量
+	SelectVars(ctx context.Context, assistantID string) (*model.ConversationVars, error)
+	SaveVars(ctx context.Context, assistantID string, vars *model.ConversationVars) (*model.ConversationVars, error)
+}
+
+// ProcessResult 非流式处理结果
+type ProcessResult struct {
+	Reply     string        `json:"reply"`
+	Usage     model.Usage   `json:"usage"`
+	ToolCalls []ToolCall    `json:"tool_calls"`
+	Message   model.Message `json:"message"`
 }
 
 type historyServiceImpl struct {
 	historyRepo   repository.HistoryRepo
 	assistantRepo repository.AssistantRepo
+	userRepo      repository.UserRepo
 	llmService    LLMService
+	quotaService  QuotaService
+	memoryService MemoryService  // 未启用长期记忆时为nil
+	pending       sync.WaitGroup // 尚未写完历史的流式对话及重置后的记忆提取
+	turns         *turnLocks     // 同一会话并发轮次控制
+	location      *time.Location // 提示词模板中日期时间使用的时区
+	prompts       atomic.Pointer[SystemPrompts]
 }
 
-func NewHistoryService(historyRepo repository.HistoryRepo, assistantRepo repository.AssistantRepo, llmService LLMService) HistoryService {
-	return &historyServiceImpl{
+func NewHistoryService(historyRepo repository.HistoryRepo, assistantRepo repository.AssistantRepo, userRepo repository.UserRepo, llmService LLMService, quotaService QuotaService, memoryService MemoryService, turnPolicy string, location *time.Location, prompts SystemPrompts) HistoryService {
+	if !IsValidTurnPolicy(turnPolicy) {
+		slog.Warn("未知的会话并发策略，使用queue", "policy", turnPolicy)
+		turnPolicy = TurnPolicyQueue
+	}
+	s := &historyServiceImpl{
 		historyRepo:   historyRepo,
 		assistantRepo: assistantRepo,
+		userRepo:      userRepo,
 		llmService:    llmService,
+		quotaService:  quotaService,
+		memoryService: memoryService,
+		turns:         newTurnLocks(turnPolicy),
+		location:      location,
 	}
+	s.prompts.Store(&prompts)
+	return s
+}
+
+// 热更新全局系统提示（进行中的对话不受影响）
+func (s *historyServiceImpl) UpdateSystemPrompts(prompts SystemPrompts) {
+	s.prompts.Store(&prompts)
 }
 
 // 按助手ID查询历史
@@ -51,7 +99,7 @@ func (s *historyServiceImpl) SelectByAssistantID(ctx context.Context, assistantI
 	return nil, errors.New("助手不存在")
 }
 
-// 重置对话
+// 重置对话（启用长期记忆时在后台从被清空的会话中提取记忆）
 func (s *historyServiceImpl) ResetByAssistantID(ctx context.Context, assistantID string) error {
 	assistants, err := s.assistantRepo.SelectAll(ctx)
 	if err != nil {
@@ -59,9 +107,29 @@ func (s *historyServiceImpl) ResetByAssistantID(ctx context.Context, assistantID
 	}
 	for _, a := range assistants {
 		if a.ID == assistantID {
+			// 与对话轮次共用会话锁：进行中的回复保存后再重置，否则重置会被随后追加的回复撤销
+			release, err := s.turns.acquire(ctx, turnKey(ctx, assistantID))
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			var history *model.History
+			if s.memoryService != nil {
+				if history, err = s.historyRepo.SelectByAssistantID(ctx, assistantID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+					slog.WarnContext(ctx, "查询历史失败，跳过记忆提取", "assistant_id", assistantID, "error", err)
+				}
+			}
 			if err := s.historyRepo.DeleteByAssistantID(ctx, assistantID); err != nil {
 				return fmt.Errorf("删除历史失败: %w", err)
 			}
+			if history != nil {
+				s.pending.Add(1)
+				go func() {
+					defer s.pending.Done()
+					s.memoryService.Extract(context.WithoutCancel(ctx), assistantID, history)
+				}()
+			}
 			// 添加重置消息
 			msg := model.Message{
 				Input:     model.Input{Prompt: a.Prompt},
@@ -92,46 +160,48 @@ func (s *historyServiceImpl) SaveByAssistantID(ctx context.Context, assistantID
 }
 
 // 流式处理（核心）
-func (s *historyServiceImpl) StreamProcessMessage(ctx context.Context, assistantID string, input model.Input) (<-chan string, <-chan error, model.Usage, error) {
+func (s *historyServiceImpl) StreamProcessMessage(ctx context.Context, assistantID string, input model.Input) (<-chan string, <-chan error, *model.Usage, error) {
 	contentChan := make(chan string)
 	errChan := make(chan error, 1)
 	var fullContent strings.Builder
-	usage := model.Usage{}
 	var wg sync.WaitGroup
 	wg.Add(1)
 
+	// span在流结束、历史保存后才结束
+	ctx, span := tracing.Start(ctx, "HistoryService.StreamProcessMessage",
+		attribute.String("assistant.id", assistantID),
+	)
+
 	// 1. 获取助手信息
 	assistant, err := s.getAssistant(ctx, assistantID)
 	if err != nil {
-		errChan <- err
-		close(contentChan)
-		close(errChan)
-		return nil, nil, usage, err
+		tracing.EndWithError(span, err)
+		return nil, nil, nil, err
 	}
+	opts := callOptions(assistant)
+	modelName := s.modelName(opts)
+	span.SetAttributes(attribute.String("llm.model", modelName))
 
-	// 2. 构建消息列表
-	messages := []Message{
-		{Role: "system", Content: "你是一个智能助手，会根据用户输入来挑选,如果是一些实时性的问答或者你只要通过调用提供的tools可以提升对话质量的就一定要调用。" + assistant.Prompt}, // 使用优化后的系统提示
+	// 2. 校验配额（超限时不调用LLM）
+	if err := s.quotaService.Check(ctx, assistantID); err != nil {
+		tracing.EndWithError(span, err)
+		return nil, nil, nil, err
 	}
-	// 追加历史消息
-	history, err := s.historyRepo.SelectByAssistantID(ctx, assistantID)
-	if err == nil && history != nil {
-		for _, msg := range history.Messages {
-			if msg.Input.Send != "" {
-				messages = append(messages, Message{Role: "user", Content: msg.Input.Send})
-			}
-			if msg.Output.Content != "" {
-				messages = append(messages, Message{Role: "assistant", Content: msg.Output.Content})
-			}
-		}
+
+	// 3. 获取会话锁（按策略排队或拒绝），历史保存后释放
+	release, err := s.turns.acquire(ctx, turnKey(ctx, assistantID))
+	if err != nil {
+		tracing.EndWithError(span, err)
+		return nil, nil, nil, err
 	}
-	// 追加当前输入
-	messages = append(messages, Message{Role: "user", Content: input.Send})
+	messages := s.buildMessages(ctx, assistant, input)
 
-	// 3. 调用LLM服务
-	llmChan, llmErrChan := s.llmService.StreamGenerateWithSearch(ctx, messages)
+	// 4. 调用LLM服务
+	s.pending.Add(1)
+	llmChan, llmErrChan, result := s.llmService.StreamGenerateWithSearch(ctx, messages, opts)
+	usage := &result.Usage
 
-	// 4. 处理流式内容
+	// 5. 处理流式内容
 	go func() {
 		defer wg.Done()
 		for chunk := range llmChan {
@@ -140,26 +210,42 @@ func (s *historyServiceImpl) StreamProcessMessage(ctx context.Context, assistant
 		}
 	}()
 
-	// 5. 处理错误
+	// 6. 处理错误
+	var llmErr error
+	errDone := make(chan struct{})
 	go func() {
+		defer close(errDone)
 		if err := <-llmErrChan; err != nil {
+			llmErr = err
 			errChan <- err
 		}
 	}()
 
-	// 6. 确保保存历史
+	// 7. 确保保存历史与用量（客户端断开后请求上下文已取消，写库不能随之失败）
 	go func() {
+		defer s.pending.Done()
+		defer release()
 		wg.Wait()
+		<-errDone
+		defer func() { tracing.EndWithError(span, llmErr) }()
+		ctx := context.WithoutCancel(ctx)
+		span.SetAttributes(usageAttributes(*usage)...)
+		if err := s.quotaService.Record(ctx, assistantID, modelName, *usage); err != nil {
+			slog.WarnContext(ctx, "记录用量失败", "assistant_id", assistantID, "error", err)
+		}
 		message := model.Message{
 			Input:     input,
 			Output:    model.Output{Content: fullContent.String()},
-			Usage:     usage,
+			Usage:     *usage,
 			GmtCreate: time.Now().Format("2006-01-02 15:04:05"),
+			ToolCalls: toolUses(result.ToolCalls),
+			Citations: result.Citations,
+			Revision:  assistant.Revision,
 		}
 		if err := s.SaveByAssistantID(ctx, assistantID, message); err != nil {
-			log.Printf("保存历史警告: %v", err)
+			slog.ErrorContext(ctx, "保存历史失败", "assistant_id", assistantID, "error", err)
 		} else {
-			log.Printf("历史保存成功，长度: %d", fullContent.Len())
+			slog.DebugContext(ctx, "历史保存成功", "assistant_id", assistantID, "length", fullContent.Len())
 		}
 		close(contentChan)
 		close(errChan)
@@ -168,6 +254,214 @@ func (s *historyServiceImpl) StreamProcessMessage(ctx context.Context, assistant
 	return contentChan, errChan, usage, nil
 }
 
+// 等待进行中的流式对话写完历史，超时返回ctx错误
+func (s *historyServiceImpl) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 非流式处理（返回完整回复、用量、工具调用与已保存消息）
+func (s *historyServiceImpl) ProcessMessage(ctx context.Context, assistantID string, input model.Input) (_ *ProcessResult, err error) {
+	ctx, span := tracing.Start(ctx, "HistoryService.ProcessMessage",
+		attribute.String("assistant.id", assistantID),
+	)
+	defer func() { tracing.EndWithError(span, err) }()
+
+	// 1. 获取助手信息
+	assistant, err := s.getAssistant(ctx, assistantID)
+	if err != nil {
+		return nil, err
+	}
+	opts := callOptions(assistant)
+	modelName := s.modelName(opts)
+	span.SetAttributes(attribute.String("llm.model", modelName))
+
+	// 2. 校验配额（超限时不调用LLM）
+	if err := s.quotaService.Check(ctx, assistantID); err != nil {
+		return nil, err
+	}
+
+	// 3. 获取会话锁后构建消息列表并调用LLM服务
+	release, err := s.turns.acquire(ctx, turnKey(ctx, assistantID))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	messages := s.buildMessages(ctx, assistant, input)
+	result, err := s.llmService.GenerateWithSearch(ctx, messages, opts)
+	if err != nil {
+		return nil, fmt.Errorf("生成回复失败: %w", err)
+	}
+	span.SetAttributes(usageAttributes(result.Usage)...)
+	if err := s.quotaService.Record(ctx, assistantID, modelName, result.Usage); err != nil {
+		slog.WarnContext(ctx, "记录用量失败", "assistant_id", assistantID, "error", err)
+	}
+
+	// 4. 保存历史
+	message := model.Message{
+		Input:     input,
+		Output:    model.Output{FinishReason: result.FinishReason, Content: result.Content},
+		Usage:     result.Usage,
+		GmtCreate: time.Now().Format("2006-01-02 15:04:05"),
+		ToolCalls: toolUses(result.ToolCalls),
+		Citations: result.Citations,
+		Revision:  assistant.Revision,
+	}
+	if err := s.SaveByAssistantID(ctx, assistantID, message); err != nil {
+		return nil, fmt.Errorf("保存历史失败: %w", err)
+	}
+
+	return &ProcessResult{
+		Reply:     result.Content,
+		Usage:     result.Usage,
+		ToolCalls: result.ToolCalls,
+		Message:   message,
+	}, nil
+}
+
+// 辅助：构建发送给LLM的消息列表（系统提示+历史+当前输入）
+func (s *historyServiceImpl) buildMessages(ctx context.Context, assistant *model.Assistant, input model.Input) []Message {
+	history, err := s.historyRepo.SelectByAssistantID(ctx, assistant.ID)
+	system := s.systemMessage(ctx, assistant, history, input)
+	// 长期记忆附加在系统提示最后
+	if s.memoryService != nil {
+		if memories := s.memoryService.Recall(ctx, assistant.ID, input.Send); memories != "" {
+			system = strings.TrimSpace(system + "\n\n" + memories)
+		}
+	}
+	messages := []Message{
+		{Role: "system", Content: system},
+	}
+	// 追加历史消息
+	if err == nil && history != nil {
+		for _, msg := range history.Messages {
+			if msg.Input.Send != "" {
+				messages = append(messages, Message{Role: "user", Content: msg.Input.Send})
+			}
+			if msg.Output.Content != "" {
+				messages = append(messages, Message{Role: "assistant", Content: msg.Output.Content})
+			}
+		}
+	}
+	// 追加当前输入
+	return append(messages, Message{Role: "user", Content: input.Send})
+}
+
+// 辅助：渲染后的提示词与全局系统提示合并为system消息（不含长期记忆）
+func (s *historyServiceImpl) systemMessage(ctx context.Context, assistant *model.Assistant, history *model.History, input model.Input) string {
+	prompt := s.renderPrompt(ctx, assistant, history, input)
+	toolsEnabled := assistant.Tools == nil || len(assistant.Tools) > 0
+	return systemPrompt(*s.prompts.Load(), assistant.ModelParams, prompt, toolsEnabled)
+}
+
+// 辅助：渲染助手提示词模板（渲染失败时使用原始提示词，不影响对话）
+func (s *historyServiceImpl) renderPrompt(ctx context.Context, assistant *model.Assistant, history *model.History, input model.Input) string {
+	if !strings.Contains(assistant.Prompt, "{{") {
+		return assistant.Prompt
+	}
+	data := newPromptData(time.Now().In(s.location))
+	data.AssistantName = assistant.Name
+	data.UserID = auth.UserID(ctx)
+	if data.UserID != "" {
+		if user, err := s.userRepo.SelectByID(ctx, data.UserID); err == nil {
+			data.UserName = user.DisplayName
+			if data.UserName == "" {
+				data.UserName = user.Username
+			}
+		}
+	}
+	if vars, err := s.historyRepo.SelectVars(ctx, assistant.ID); err == nil {
+		data.ConversationTitle = vars.Title
+		if vars.Variables != nil {
+			data.Vars = vars.Variables
+		}
+	}
+	// 未设置标题时取首条用户消息（新会话为本次输入）
+	if data.ConversationTitle == "" {
+		data.ConversationTitle = defaultTitle(input.Send)
+		if history != nil {
+			for _, m := range history.Messages {
+				if m.Input.Send != "" {
+					data.ConversationTitle = defaultTitle(m.Input.Send)
+					break
+				}
+			}
+		}
+	}
+
+	prompt, err := RenderPrompt(assistant.Prompt, data)
+	if err != nil {
+		slog.WarnContext(ctx, "渲染提示词模板失败，使用原始提示词", "assistant_id", assistant.ID, "error", err)
+		return assistant.Prompt
+	}
+	return prompt
+}
+
+// 查询会话变量（未设置时返回空变量）
+func (s *historyServiceImpl) SelectVars(ctx context.Context, assistantID string) (*model.ConversationVars, error) {
+	if _, err := s.getAssistant(ctx, assistantID); err != nil {
+		return nil, err
+	}
+	vars, err := s.historyRepo.SelectVars(ctx, assistantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &model.ConversationVars{Variables: map[string]string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// 保存会话变量（整体覆盖）
+func (s *historyServiceImpl) SaveVars(ctx context.Context, assistantID string, vars *model.ConversationVars) (*model.ConversationVars, error) {
+	if _, err := s.getAssistant(ctx, assistantID); err != nil {
+		return nil, err
+	}
+	if err := validateVariables(vars.Variables); err != nil {
+		return nil, err
+	}
+	if vars.Variables == nil {
+		vars.Variables = map[string]string{}
+	}
+	vars.GmtModified = time.Now().Format("2006-01-02 15:04:05")
+	if err := s.historyRepo.SaveVars(ctx, assistantID, vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// 辅助：会话标识（同一助手下不同用户的会话互不影响）
+func turnKey(ctx context.Context, assistantID string) string {
+	return assistantID + ":" + auth.UserID(ctx)
+}
+
+// 辅助：工具调用转为历史记录格式
+func toolUses(calls []ToolCall) []model.ToolUse {
+	var uses []model.ToolUse
+	for _, c := range calls {
+		uses = append(uses, model.ToolUse{Name: c.Function.Name, Arguments: c.Function.Arguments})
+	}
+	return uses
+}
+
+// 辅助：用量追踪属性
+func usageAttributes(u model.Usage) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("llm.usage.input_tokens", u.InputTokens),
+		attribute.Int("llm.usage.output_tokens", u.OutputTokens),
+		attribute.Int("llm.usage.total_tokens", u.TotalTokens),
+	}
+}
+
 // 辅助：获取助手
 func (s *historyServiceImpl) getAssistant(ctx context.Context, assistantID string) (*model.Assistant, error) {
 	assistants, err := s.assistantRepo.SelectAll(ctx)
@@ -181,3 +475,22 @@ func (s *historyServiceImpl) getAssistant(ctx context.Context, assistantID strin
 	}
 	return nil, errors.New("助手不存在")
 }
+
+// callOptions 助手声明的模型参数与可用工具
+func callOptions(a *model.Assistant) CallOptions {
+	return CallOptions{
+		Model:       a.ModelParams.Model,
+		MaxTokens:   a.ModelParams.MaxTokens,
+		Temperature: a.ModelParams.Temperature,
+		Tools:       a.Tools,
+		AssistantID: a.ID,
+	}
+}
+
+// modelName 本次调用实际使用的模型（用于追踪与用量记录）
+func (s *historyServiceImpl) modelName(opts CallOptions) string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return s.llmService.ModelName()
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/repository"
+	"Voice_Assistant/internal/tracing"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrDocumentNotFound    = errors.New("文档不存在")
+	ErrUnsupportedDocument = errors.New("不支持的文档类型，可选：.md、.markdown、.txt、.html、.htm、.pdf（文本需为UTF-8编码）")
+	ErrEmptyDocument       = errors.New("文档中没有可提取的文本（扫描版或字体编码特殊的PDF请先转换为文本）")
+	ErrDocumentTooLarge    = errors.New("文档超过大小限制")
+	ErrNotAssistantOwner   = errors.New("只有助手所有者可以管理知识库")
+)
+
+// PDF内容流解压后的总大小上限为文档大小上限的倍数
+const maxInflateRatio = 20
+
+// KnowledgeConfig 知识库切分与检索参数（零值使用默认值）
+type KnowledgeConfig struct {
+	ChunkSize     int     `yaml:"chunk_size"`      // 每个片段最多字符数
+	ChunkOverlap  int     `yaml:"chunk_overlap"`   // 同一章节内相邻片段重叠的字符数
+	TopK          int     `yaml:"top_k"`           // 检索默认返回的片段数
+	MinScore      float64 `yaml:"min_score"`       // 低于该相似度的片段不返回
+	MaxDocumentMB int     `yaml:"max_document_mb"` // 单个文档大小上限
+}
+
+func (c KnowledgeConfig) withDefaults() KnowledgeConfig {
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = 500
+	}
+	if c.ChunkOverlap < 0 || c.ChunkOverlap >= c.ChunkSize {
+		c.ChunkOverlap = 0
+	}
+	if c.TopK <= 0 {
+		c.TopK = 4
+	}
+	if c.MaxDocumentMB <= 0 {
+		c.MaxDocumentMB = 10
+	}
+	return c
+}
+
+// 单次检索最多返回的片段数
+const maxKnowledgeTopK = 20
+
+// KnowledgeService 助手知识库（同时作为knowledge_search工具注册到LLM服务）
+type KnowledgeService interface {
+	ToolProvider
+	Upload(ctx context.Context, assistantID, filename, title string, data []byte) (*model.KnowledgeDocument, error)
+	ListDocuments(ctx context.Context, assistantID string) ([]model.KnowledgeDocument, error)
+	DeleteDocument(ctx context.Context, assistantID, documentID string) error
+	// topK为0时使用配置的默认值
+	Search(ctx context.Context, assistantID, query string, topK int) ([]model.KnowledgeMatch, error)
+	// 单个文档大小上限（字节），上传接口据此限制请求体
+	MaxDocumentSize() int64
+}
+
+type knowledgeServiceImpl struct {
+	knowledgeRepo repository.KnowledgeRepo
+	assistantRepo repository.AssistantRepo
+	embedder      Embedder
+	cfg           KnowledgeConfig
+}
+
+func NewKnowledgeService(knowledgeRepo repository.KnowledgeRepo, assistantRepo repository.AssistantRepo, embedder Embedder, cfg KnowledgeConfig) KnowledgeService {
+	return &knowledgeServiceImpl{
+		knowledgeRepo: knowledgeRepo,
+		assistantRepo: assistantRepo,
+		embedder:      embedder,
+		cfg:           cfg.withDefaults(),
+	}
+}
+
+// MaxDocumentSize 单个文档大小上限（字节）
+func (s *knowledgeServiceImpl) MaxDocumentSize() int64 {
+	return int64(s.cfg.MaxDocumentMB) << 20
+}
+
+// Upload 提取文档文本、切分并向量化后保存（标题为空时取HTML标题或文件名）
+func (s *knowledgeServiceImpl) Upload(ctx context.Context, assistantID, filename, title string, data []byte) (_ *model.KnowledgeDocument, err error) {
+	ctx, span := tracing.Start(ctx, "KnowledgeService.Upload",
+		attribute.String("assistant.id", assistantID),
+		attribute.Int("document.size", len(data)),
+	)
+	defer func() { tracing.EndWithError(span, err) }()
+
+	a, err := s.findAssistant(ctx, assistantID)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.IsAdmin(ctx) && a.OwnerID != auth.UserID(ctx) {
+		return nil, ErrNotAssistantOwner
+	}
+	if int64(len(data)) > s.MaxDocumentSize() {
+		return nil, fmt.Errorf("%w（%dMB）", ErrDocumentTooLarge, s.cfg.MaxDocumentMB)
+	}
+
+	contentType := documentType(filename)
+	if contentType == "" {
+		return nil, ErrUnsupportedDocument
+	}
+	text, docTitle, err := extractText(contentType, data, s.MaxDocumentSize()*maxInflateRatio)
+	if err != nil {
+		return nil, err
+	}
+	if contentType == model.DocumentPDF && !readableText(text) {
+		return nil, ErrEmptyDocument
+	}
+	pieces := chunkText(text, s.cfg.ChunkSize, s.cfg.ChunkOverlap)
+	if len(pieces) == 0 {
+		return nil, ErrEmptyDocument
+	}
+	span.SetAttributes(attribute.String("document.type", contentType), attribute.Int("document.chunks", len(pieces)))
+
+	vectors, err := s.embedder.Embed(ctx, pieces)
+	if err != nil {
+		return nil, fmt.Errorf("生成向量失败: %w", err)
+	}
+	if len(vectors) != len(pieces) {
+		return nil, fmt.Errorf("生成向量失败: 期望%d条，实际%d条", len(pieces), len(vectors))
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = docTitle
+	}
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	doc := &model.KnowledgeDocument{
+		ID:             uuid.New().String(),
+		AssistantID:    assistantID,
+		Title:          title,
+		Filename:       filepath.Base(filename),
+		ContentType:    contentType,
+		Size:           len(data),
+		Chunks:         len(pieces),
+		EmbeddingModel: s.embedder.Model(),
+		UploaderID:     auth.UserID(ctx),
+		GmtCreate:      time.Now().Format("2006-01-02 15:04:05"),
+	}
+	chunks := make([]model.KnowledgeChunk, len(pieces))
+	for i, p := range pieces {
+		chunks[i] = model.KnowledgeChunk{DocumentID: doc.ID, Seq: i, Title: title, Content: p, Embedding: vectors[i]}
+	}
+	if err := s.knowledgeRepo.SaveDocument(ctx, doc, chunks); err != nil {
+		return nil, fmt.Errorf("保存文档失败: %w", err)
+	}
+	slog.InfoContext(ctx, "知识库文档已上传", "assistant_id", assistantID, "document_id", doc.ID, "type", contentType, "chunks", len(chunks))
+	return doc, nil
+}
+
+// ListDocuments 助手知识库的文档
+func (s *knowledgeServiceImpl) ListDocuments(ctx context.Context, assistantID string) ([]model.KnowledgeDocument, error) {
+	if _, err := s.findAssistant(ctx, assistantID); err != nil {
+		return nil, err
+	}
+	docs, err := s.knowledgeRepo.SelectDocuments(ctx, assistantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询文档失败: %w", err)
+	}
+	return docs, nil
+}
+
+// DeleteDocument 删除文档及其片段
+func (s *knowledgeServiceImpl) DeleteDocument(ctx context.Context, assistantID, documentID string) error {
+	a, err := s.findAssistant(ctx, assistantID)
+	if err != nil {
+		return err
+	}
+	if !auth.IsAdmin(ctx) && a.OwnerID != auth.UserID(ctx) {
+		return ErrNotAssistantOwner
+	}
+	if err := s.knowledgeRepo.DeleteDocument(ctx, assistantID, documentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDocumentNotFound
+		}
+		return fmt.Errorf("删除文档失败: %w", err)
+	}
+	return nil
+}
+
+// Search 按余弦相似度检索助手知识库
+func (s *knowledgeServiceImpl) Search(ctx context.Context, assistantID, query string, topK int) ([]model.KnowledgeMatch, error) {
+	if _, err := s.findAssistant(ctx, assistantID); err != nil {
+		return nil, err
+	}
+	return s.search(ctx, assistantID, query, topK)
+}
+
+// search 检索（不校验助手可见性，工具调用时已校验）
+func (s *knowledgeServiceImpl) search(ctx context.Context, assistantID, query string, topK int) (_ []model.KnowledgeMatch, err error) {
+	ctx, span := tracing.Start(ctx, "KnowledgeService.Search", attribute.String("assistant.id", assistantID))
+	defer func() { tracing.EndWithError(span, err) }()
+
+	if topK <= 0 {
+		topK = s.cfg.TopK
+	}
+	topK = min(topK, maxKnowledgeTopK)
+	chunks, err := s.knowledgeRepo.SelectChunks(ctx, assistantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询知识库失败: %w", err)
+	}
+	matches := []model.KnowledgeMatch{}
+	if len(chunks) == 0 || strings.TrimSpace(query) == "" {
+		return matches, nil
+	}
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("生成查询向量失败: %w", err)
+	}
+
+	skipped := 0
+	for _, c := range chunks {
+		// 不同模型（或维度）生成的向量无法比较
+		if c.EmbeddingModel != s.embedder.Model() || len(c.Embedding) != len(vectors[0]) {
+			skipped++
+			continue
+		}
+		score := cosine(vectors[0], c.Embedding)
+		if score < s.cfg.MinScore {
+			continue
+		}
+		matches = append(matches, model.KnowledgeMatch{
+			DocumentID: c.DocumentID,
+			Title:      c.Title,
+			Seq:        c.Seq,
+			Content:    c.Content,
+			Score:      score,
+		})
+	}
+	if skipped > 0 {
+		slog.WarnContext(ctx, "部分知识库片段的向量模型或维度与当前配置不一致，已跳过（请重新上传对应文档）",
+			"assistant_id", assistantID, "skipped", skipped, "model", s.embedder.Model())
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > topK {
+		matches = matches[:topK]
+	}
+	span.SetAttributes(attribute.Int("knowledge.chunks", len(chunks)), attribute.Int("knowledge.matches", len(matches)))
+	return matches, nil
+}
+
+// findAssistant 查询当前用户可见的助手
+func (s *knowledgeServiceImpl) findAssistant(ctx context.Context, id string) (*model.Assistant, error) {
+	assistants, err := s.assistantRepo.SelectAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询助手失败: %w", err)
+	}
+	for _, a := range assistants {
+		if a.ID == id {
+			return &a, nil
+		}
+	}
+	return nil, errors.New("助手不存在")
+}
+
+// Definition knowledge_search工具定义
+func (s *knowledgeServiceImpl) Definition() Tool {
+	return Tool{
+		Type: "function",
+		Function: Function{
+			Name: "knowledge_search",
+			Description: "检索当前助手知识库中上传的内部文档（产品资料、规章流程、手册等）。" +
+				"问题涉及内部资料时优先使用，返回最相关的文档片段及出处。",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "检索内容，使用完整的问题或关键词短语",
+					},
+					"top_k": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("返回片段数量（最大%d）", maxKnowledgeTopK),
+						"default":     s.cfg.TopK,
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+	}
+}
+
+// Available 知识库为空的助手不提供该工具
+func (s *knowledgeServiceImpl) Available(ctx context.Context, assistantID string) bool {
+	if assistantID == "" {
+		return false
+	}
+	n, err := s.knowledgeRepo.CountDocuments(ctx, assistantID)
+	if err != nil {
+		slog.WarnContext(ctx, "查询知识库文档数量失败", "assistant_id", assistantID, "error", err)
+		return false
+	}
+	return n > 0
+}
+
+// Execute 执行knowledge_search工具调用
+func (s *knowledgeServiceImpl) Execute(ctx context.Context, assistantID string, arguments string) (string, []model.Citation, error) {
+	var params struct {
+		Query string `json:"query"`
+		TopK  int    `json:"top_k"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+		return "", nil, fmt.Errorf("参数解析错误: %w", err)
+	}
+	if strings.TrimSpace(params.Query) == "" {
+		return "", nil, errors.New("检索内容不能为空")
+	}
+	matches, err := s.search(ctx, assistantID, params.Query, params.TopK)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(matches) == 0 {
+		return "知识库中未找到相关内容，可尝试调整关键词，或告知用户内部资料中没有相关信息。", nil, nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "共找到%d条相关片段：\n\n", len(matches))
+	citations := make([]model.Citation, 0, len(matches))
+	for i, m := range matches {
+		fmt.Fprintf(&b, "%d. 《%s》第%d段（相似度%.2f）\n%s\n\n", i+1, m.Title, m.Seq+1, m.Score, m.Content)
+		citations = append(citations, model.Citation{
+			Title:   m.Title,
+			Snippet: m.Content,
+		})
+	}
+	return b.String(), citations, nil
+}
+
+// cosine 余弦相似度（任一向量为零向量时为0）
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
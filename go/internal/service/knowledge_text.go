@@ -0,0 +1,363 @@
+package service
+
+import (
+	"Voice_Assistant/internal/model"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// documentType 按扩展名判断文档类型（不支持时返回空字符串）
+func documentType(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return model.DocumentMarkdown
+	case ".txt", ".text":
+		return model.DocumentText
+	case ".html", ".htm":
+		return model.DocumentHTML
+	case ".pdf":
+		return model.DocumentPDF
+	}
+	return ""
+}
+
+// extractText 提取文档正文，HTML同时返回<title>（没有时为空）；maxInflated为PDF内容流解压后的总大小上限
+func extractText(contentType string, data []byte, maxInflated int64) (text, title string, err error) {
+	switch contentType {
+	case model.DocumentPDF:
+		text, err = extractPDFText(data, maxInflated)
+		return text, "", err
+	case model.DocumentHTML:
+		if !utf8.Valid(data) {
+			return "", "", ErrUnsupportedDocument
+		}
+		text, title = extractHTMLText(string(data))
+		return text, title, nil
+	default:
+		if !utf8.Valid(data) {
+			return "", "", ErrUnsupportedDocument
+		}
+		return strings.TrimPrefix(string(data), "\ufeff"), "", nil
+	}
+}
+
+var (
+	htmlTitle   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlDrop    = regexp.MustCompile(`(?is)<(script|style|head|noscript|template)\b[^>]*>.*?</(script|style|head|noscript|template)>|<!--.*?-->`)
+	htmlBlock   = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6]|/section|/article|/blockquote|/pre|/table)\b[^>]*>`)
+	htmlHeading = regexp.MustCompile(`(?i)<h([1-6])\b[^>]*>`)
+	htmlTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLines  = regexp.MustCompile(`\n{3,}`)
+)
+
+// extractHTMLText 去掉脚本、样式与标签，块级元素转为换行，标题转为Markdown标题便于按章节切分
+func extractHTMLText(s string) (text, title string) {
+	if m := htmlTitle.FindStringSubmatch(s); m != nil {
+		title = strings.TrimSpace(html.UnescapeString(htmlTag.ReplaceAllString(m[1], "")))
+	}
+	s = htmlDrop.ReplaceAllString(s, "")
+	s = htmlHeading.ReplaceAllStringFunc(s, func(tag string) string {
+		level := htmlHeading.FindStringSubmatch(tag)[1]
+		return "\n\n" + strings.Repeat("#", int(level[0]-'0')) + " "
+	})
+	s = htmlBlock.ReplaceAllString(s, "\n\n")
+	s = html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")), title
+}
+
+var pdfStream = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+
+// extractPDFText 尽力提取PDF文本层（解压内容流后读取Tj/TJ等文本操作符的字符串）
+// 扫描件或使用嵌入字体编码的PDF无法提取，返回内容为空或不可读字符时由调用方拒绝；
+// 所有内容流解压后的总大小超过maxInflated时返回ErrDocumentTooLarge（防止压缩炸弹）
+func extractPDFText(data []byte, maxInflated int64) (string, error) {
+	var b strings.Builder
+	remaining := maxInflated
+	for _, m := range pdfStream.FindAllSubmatch(data, -1) {
+		stream := m[1]
+		if r, err := zlib.NewReader(bytes.NewReader(stream)); err == nil {
+			// 多读1字节用于判断是否超出上限
+			inflated, err := io.ReadAll(io.LimitReader(r, remaining+1))
+			r.Close()
+			if int64(len(inflated)) > remaining {
+				return "", fmt.Errorf("%w（PDF内容解压后超过%dMB）", ErrDocumentTooLarge, maxInflated>>20)
+			}
+			if err == nil {
+				stream = inflated
+				remaining -= int64(len(inflated))
+			}
+		}
+		if !bytes.Contains(stream, []byte("BT")) {
+			continue
+		}
+		pdfContentText(&b, stream)
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(b.String(), "\n\n")), nil
+}
+
+// pdfContentText 解析内容流中的文本操作符
+func pdfContentText(b *strings.Builder, s []byte) {
+	var pending []string
+	inArray := false
+	flush := func() {
+		for _, p := range pending {
+			b.WriteString(p)
+		}
+		pending = pending[:0]
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '(':
+			str, next := pdfLiteralString(s, i)
+			pending = append(pending, str)
+			i = next
+		case c == '<' && i+1 < len(s) && s[i+1] != '<':
+			end := bytes.IndexByte(s[i:], '>')
+			if end < 0 {
+				return
+			}
+			pending = append(pending, pdfHexString(s[i+1:i+end]))
+			i += end + 1
+		case c == '[' || c == ']':
+			inArray = c == '['
+			i++
+		case c == '%':
+			for i < len(s) && s[i] != '\n' && s[i] != '\r' {
+				i++
+			}
+		case isPDFRegular(c):
+			start := i
+			for i < len(s) && isPDFRegular(s[i]) {
+				i++
+			}
+			switch string(s[start:i]) {
+			case "Tj", "TJ":
+				flush()
+			case "'", "\"":
+				b.WriteString("\n")
+				flush()
+			case "Td", "TD", "T*", "ET":
+				b.WriteString("\n")
+				pending = pending[:0]
+			default:
+				// TJ数组中较大的负间距通常表示单词间的空格
+				if n, err := strconv.ParseFloat(string(s[start:i]), 64); err == nil && inArray && n <= -200 {
+					pending = append(pending, " ")
+				}
+			}
+		default:
+			i++
+		}
+	}
+}
+
+func isPDFRegular(c byte) bool {
+	return !strings.ContainsRune(" \t\r\n\f\x00()<>[]{}/%", rune(c))
+}
+
+// pdfLiteralString 解析(...)字符串（支持嵌套括号与转义），返回文本和结束位置
+func pdfLiteralString(s []byte, i int) (string, int) {
+	var out []byte
+	depth := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch e := s[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r', 't', 'b', 'f':
+				out = append(out, ' ')
+			case '\r', '\n':
+				// 续行
+			default:
+				if e >= '0' && e <= '7' {
+					v, n := 0, 0
+					for n < 3 && i < len(s) && s[i] >= '0' && s[i] <= '7' {
+						v = v*8 + int(s[i]-'0')
+						i++
+						n++
+					}
+					out = append(out, byte(v))
+					continue
+				}
+				out = append(out, e)
+			}
+		case c == '(':
+			if depth > 0 {
+				out = append(out, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return pdfDecode(out), i + 1
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+		i++
+	}
+	return pdfDecode(out), i
+}
+
+func pdfHexString(h []byte) string {
+	h = bytes.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, h)
+	if len(h)%2 == 1 {
+		h = append(h, '0')
+	}
+	out := make([]byte, 0, len(h)/2)
+	for i := 0; i+1 < len(h); i += 2 {
+		hi, lo := hexValue(h[i]), hexValue(h[i+1])
+		if hi < 0 || lo < 0 {
+			return ""
+		}
+		out = append(out, byte(hi<<4|lo))
+	}
+	return pdfDecode(out)
+}
+
+func hexValue(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// pdfDecode UTF-16BE（带BOM）按Unicode解码，其余按单字节编码解码，丢弃控制字符
+func pdfDecode(raw []byte) string {
+	var runes []rune
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		u := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			u = append(u, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		runes = utf16.Decode(u)
+	} else {
+		runes = make([]rune, 0, len(raw))
+		for _, c := range raw {
+			runes = append(runes, rune(c))
+		}
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || unicode.IsPrint(r) {
+			return r
+		}
+		return -1
+	}, string(runes))
+}
+
+// readableText 文本中可读字符（字母、数字、汉字等）的占比是否足够，用于识别提取失败的PDF
+func readableText(s string) bool {
+	total, readable := 0, 0
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Han, r) || unicode.IsPunct(r) {
+			readable++
+		}
+	}
+	return total > 0 && readable*10 >= total*7
+}
+
+// chunkText 按段落切分文本：段落尽量合并到chunkSize个字符以内，超长段落按句子拆分，
+// Markdown标题开启新片段（不跨章节重叠），同一章节内相邻片段重叠overlap个字符
+func chunkText(text string, chunkSize, overlap int) []string {
+	var chunks []string
+	var cur []rune
+	emit := func(carry bool) {
+		if s := strings.TrimSpace(string(cur)); s != "" {
+			chunks = append(chunks, s)
+		}
+		if carry && overlap > 0 && len(cur) > overlap {
+			cur = append([]rune(nil), cur[len(cur)-overlap:]...)
+			return
+		}
+		cur = cur[:0]
+	}
+	add := func(piece []rune) {
+		if len(cur) > 0 && len(cur)+len(piece)+2 > chunkSize {
+			emit(true)
+			// 带上重叠后仍放不下时缩短重叠部分，保证片段不超过chunkSize
+			if room := chunkSize - len(piece) - 2; len(cur) > room {
+				cur = cur[len(cur)-max(room, 0):]
+			}
+		}
+		if len(cur) > 0 {
+			cur = append(cur, '\n', '\n')
+		}
+		cur = append(cur, piece...)
+	}
+
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if strings.HasPrefix(para, "#") && len(cur) > 0 {
+			emit(false)
+		}
+		for _, piece := range splitLong([]rune(para), chunkSize) {
+			add(piece)
+		}
+	}
+	emit(false)
+	return chunks
+}
+
+// splitLong 将超过size的段落按句末标点拆分，仍超长的句子按字符数硬切
+func splitLong(para []rune, size int) [][]rune {
+	if len(para) <= size {
+		return [][]rune{para}
+	}
+	var pieces [][]rune
+	var cur []rune
+	for i, r := range para {
+		cur = append(cur, r)
+		end := i == len(para)-1 || strings.ContainsRune("。！？；.!?;\n", r)
+		if !end {
+			continue
+		}
+		if len(pieces) > 0 && len(pieces[len(pieces)-1])+len(cur) <= size {
+			pieces[len(pieces)-1] = append(pieces[len(pieces)-1], cur...)
+		} else {
+			for len(cur) > size {
+				pieces = append(pieces, cur[:size])
+				cur = cur[size:]
+			}
+			pieces = append(pieces, cur)
+		}
+		cur = nil
+	}
+	return pieces
+}
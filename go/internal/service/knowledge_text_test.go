@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkTextWithinSize(t *testing.T) {
+	long := strings.Repeat("这是一个比较长的句子，用来测试拆分。", 20)
+	tests := []struct {
+		name      string
+		text      string
+		chunkSize int
+		overlap   int
+	}{
+		{"段落合并", strings.Repeat(strings.Repeat("a", 30)+"\n\n", 10), 100, 20},
+		{"重叠后放不下下一段", strings.Repeat("a", 80) + "\n\n" + strings.Repeat("b", 95) + "\n\n" + strings.Repeat("c", 50), 100, 20},
+		{"超长段落按句子拆分", long, 50, 10},
+		{"无标点硬切", strings.Repeat("字", 250), 60, 15},
+		{"重叠大于片段", strings.Repeat("ab\n\n", 50), 10, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := chunkText(tt.text, tt.chunkSize, tt.overlap)
+			if len(chunks) == 0 {
+				t.Fatal("未生成片段")
+			}
+			for i, c := range chunks {
+				if n := utf8.RuneCountInString(c); n > tt.chunkSize {
+					t.Errorf("第%d段%d字，超过chunkSize=%d", i+1, n, tt.chunkSize)
+				}
+				if strings.TrimSpace(c) != c || c == "" {
+					t.Errorf("第%d段未去除首尾空白或为空: %q", i+1, c)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkTextOverlap(t *testing.T) {
+	a, b := strings.Repeat("a", 40)+"XYZ", strings.Repeat("b", 40)
+	chunks := chunkText(a+"\n\n"+b, 60, 3)
+	if len(chunks) != 2 {
+		t.Fatalf("片段数 = %d, want 2: %q", len(chunks), chunks)
+	}
+	if chunks[0] != a {
+		t.Errorf("第1段 = %q, want %q", chunks[0], a)
+	}
+	// 相邻片段重叠overlap个字符
+	if want := "XYZ\n\n" + b; chunks[1] != want {
+		t.Errorf("第2段 = %q, want %q", chunks[1], want)
+	}
+}
+
+func TestChunkTextHeading(t *testing.T) {
+	text := "# 第一章\n\n内容一\n\n# 第二章\n\n内容二"
+	chunks := chunkText(text, 1000, 2)
+	want := []string{"# 第一章\n\n内容一", "# 第二章\n\n内容二"}
+	if len(chunks) != len(want) {
+		t.Fatalf("chunkText = %q, want %q", chunks, want)
+	}
+	// 标题开启新片段且不跨章节重叠
+	for i := range want {
+		if chunks[i] != want[i] {
+			t.Errorf("第%d段 = %q, want %q", i+1, chunks[i], want[i])
+		}
+	}
+}
+
+func TestChunkTextEmpty(t *testing.T) {
+	for _, text := range []string{"", "  \n\n \r\n\r\n "} {
+		if chunks := chunkText(text, 100, 10); len(chunks) != 0 {
+			t.Errorf("chunkText(%q) = %q, want 空", text, chunks)
+		}
+	}
+}
+
+func TestSplitLong(t *testing.T) {
+	tests := []struct {
+		para string
+		size int
+		want []string
+	}{
+		{"短句。", 10, []string{"短句。"}},
+		{"第一句。第二句。第三句。", 8, []string{"第一句。第二句。", "第三句。"}},
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, p := range splitLong([]rune(tt.para), tt.size) {
+			got = append(got, string(p))
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitLong(%q, %d) = %q, want %q", tt.para, tt.size, got, tt.want)
+		}
+	}
+}
+
+// pdfWithStreams 构造只包含内容流的最简PDF
+func pdfWithStreams(streams ...[]byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, s := range streams {
+		fmt.Fprintf(&b, "%d 0 obj\n<< >>\nstream\n", i+1)
+		b.Write(s)
+		b.WriteString("\nendstream\nendobj\n")
+	}
+	b.WriteString("%%EOF\n")
+	return b.Bytes()
+}
+
+func deflate(t *testing.T, s string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatalf("zlib: %v", err)
+	}
+	w.Close()
+	return b.Bytes()
+}
+
+func TestExtractPDFText(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream func(t *testing.T) []byte
+		want   string
+	}{
+		{"字面字符串", func(*testing.T) []byte {
+			return []byte("BT /F1 12 Tf (Hello \\(PDF\\)) Tj ET")
+		}, "Hello (PDF)"},
+		{"TJ数组与间距", func(*testing.T) []byte {
+			return []byte("BT [(Hel) 20 (lo) -300 (World)] TJ ET")
+		}, "Hello World"},
+		{"UTF-16十六进制字符串", func(*testing.T) []byte {
+			return []byte("BT <FEFF4F60597D> Tj ET")
+		}, "你好"},
+		{"换行操作符", func(*testing.T) []byte {
+			return []byte("BT (line1) Tj 0 -14 Td (line2) Tj ET")
+		}, "line1\nline2"},
+		{"压缩流", func(t *testing.T) []byte {
+			return deflate(t, "BT (compressed) Tj ET")
+		}, "compressed"},
+		{"非文本流", func(*testing.T) []byte {
+			return []byte("0 0 m 10 10 l S")
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractPDFText(pdfWithStreams(tt.stream(t)), 1<<20)
+			if err != nil {
+				t.Fatalf("extractPDFText: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("extractPDFText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractPDFTextInflateLimit(t *testing.T) {
+	content := "BT (" + strings.Repeat("a", 4096) + ") Tj ET"
+	stream := deflate(t, content)
+
+	// 多个流累计计算解压大小
+	data := pdfWithStreams(stream, stream)
+	if _, err := extractPDFText(data, int64(len(content))); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("超出上限 err = %v, want ErrDocumentTooLarge", err)
+	}
+	if _, err := extractPDFText(data, int64(2*len(content))); err != nil {
+		t.Errorf("恰好达到上限 err = %v, want nil", err)
+	}
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"Voice_Assistant/internal/metrics"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/tracing"
 	"bufio"
 	"bytes"
 	"context"
@@ -8,10 +11,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // 工具定义（包含搜索工具和本地时间工具）
@@ -69,24 +79,87 @@ type BochaSearchResponse struct {
 	} `json:"data"`
 }
 
+// 非流式生成结果（含工具调用、搜索引用与用量统计）
+type GenerateResult struct {
+	Content      string           `json:"content"`
+	FinishReason string           `json:"finish_reason"`
+	Usage        model.Usage      `json:"usage"`
+	ToolCalls    []ToolCall       `json:"tool_calls"`
+	Citations    []model.Citation `json:"citations"`
+}
+
+// 流式生成的附加结果（contentChan关闭后可读取）
+type StreamResult struct {
+	Usage     model.Usage
+	ToolCalls []ToolCall
+	Citations []model.Citation
+}
+
+// CallOptions 单次调用的模型参数（零值字段使用全局配置）
+type CallOptions struct {
+	Model       string
+	MaxTokens   int
+	Temperature *float64
+	Tools       []string // 可用工具名称（nil为全部，空切片为不使用工具）
+	AssistantID string   // 调用所属助手（按助手提供的工具如知识库检索使用）
+}
+
+// ToolProvider 由其他服务提供的工具（如知识库检索），可按助手决定是否提供给模型
+type ToolProvider interface {
+	Definition() Tool
+	// 该助手当前是否可用（不可用时不发送给模型）
+	Available(ctx context.Context, assistantID string) bool
+	// 执行工具调用，返回工具结果与引用
+	Execute(ctx context.Context, assistantID string, arguments string) (string, []model.Citation, error)
+}
+
+// 第二次调用无内容时的兜底回复
+const emptyReplyFallback = "抱歉，暂时无法获取相关信息。请尝试调整问题或提供更多细节。"
+
 // LLM服务接口
 type LLMService interface {
-	GenerateReply(ctx context.Context, prompt string, input string) (string, error)
+	GenerateReply(ctx context.Context, prompt string, input string) (string, model.Usage, error)
+	GenerateWithSearch(ctx context.Context, messages []Message, opts CallOptions) (*GenerateResult, error)
 	StreamGenerate(ctx context.Context, messages []Message, tools []Tool) (<-chan string, <-chan error)
-	StreamGenerateWithSearch(ctx context.Context, messages []Message) (<-chan string, <-chan error)
+	// 返回的用量、工具调用与引用在contentChan关闭后可读取
+	StreamGenerateWithSearch(ctx context.Context, messages []Message, opts CallOptions) (<-chan string, <-chan error, *StreamResult)
+	ModelName() string
+	// 全部工具名称（用于校验助手声明的工具）
+	ToolNames() []string
+	// 注册外部工具（需在处理请求前完成）
+	RegisterTool(provider ToolProvider)
+	// 热更新连接参数（模型、密钥、地址等）
+	Reconfigure(apiKey, baseURL, modelName string, maxTokens int, timeoutSec int, bochaAPIKey string)
+	// 检查LLM接口是否可达（收到任意非5xx响应即视为可达）
+	Ping(ctx context.Context) error
 }
 
 // LLM服务实现
 type llmServiceImpl struct {
-	apiKey          string
-	baseURL         string
-	modelName       string
-	maxTokens       int
-	timeout         time.Duration
+	settings        atomic.Pointer[llmSettings] // 可热更新的连接参数，每次调用开始时取快照
 	client          *http.Client
-	bochaAPIKey     string
 	tools           []Tool
-	beijingLocation *time.Location // 北京时间时区
+	providers       map[string]ToolProvider // 外部工具（按名称）
+	beijingLocation *time.Location          // 北京时间时区
+}
+
+// llmSettings 可热更新的LLM与工具参数
+type llmSettings struct {
+	apiKey      string
+	baseURL     string
+	modelName   string
+	maxTokens   int
+	temperature *float64      // 仅由CallOptions设置，nil时使用模型默认值
+	timeout     time.Duration // 非流式请求的超时；流式请求为首个及相邻数据块之间的最长等待（0为不限制）
+	bochaAPIKey string
+}
+
+// withTimeout 按配置为单次非流式请求设置超时
+func (c *llmSettings) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
 }
 
 // 初始化函数（工具定义与时区初始化）
@@ -95,7 +168,7 @@ func NewLLMService(apiKey, baseURL, modelName string, maxTokens int, timeoutSec
 	beijingLoc, err := time.LoadLocation("Asia/Shanghai")
 	if err != nil {
 		beijingLoc = time.FixedZone("CST", 8*3600)
-		log.Printf("加载北京时间时区失败，使用UTC+8替代: %v", err)
+		slog.Warn("加载北京时间时区失败，使用UTC+8替代", "error", err)
 	}
 
 	// 工具列表：搜索工具+本地时间工具
@@ -143,56 +216,150 @@ func NewLLMService(apiKey, baseURL, modelName string, maxTokens int, timeoutSec
 		},
 	}
 
-	return &llmServiceImpl{
-		apiKey:    apiKey,
-		baseURL:   baseURL,
-		modelName: modelName,
-		maxTokens: maxTokens,
-		timeout:   time.Duration(timeoutSec) * time.Second,
+	s := &llmServiceImpl{
+		// 不设置整体超时（会截断长时间的流式响应），由llmSettings.timeout按请求控制
 		client: &http.Client{
-			Timeout: 30 * time.Second,
 			Transport: &http.Transport{
 				TLSHandshakeTimeout: 10 * time.Second,
 			},
 		},
-		bochaAPIKey:     bochaAPIKey,
 		tools:           tools,
+		providers:       make(map[string]ToolProvider),
 		beijingLocation: beijingLoc,
 	}
+	s.Reconfigure(apiKey, baseURL, modelName, maxTokens, timeoutSec, bochaAPIKey)
+	return s
+}
+
+// 热更新连接参数（进行中的调用继续使用旧参数）
+func (s *llmServiceImpl) Reconfigure(apiKey, baseURL, modelName string, maxTokens int, timeoutSec int, bochaAPIKey string) {
+	s.settings.Store(&llmSettings{
+		apiKey:      apiKey,
+		baseURL:     baseURL,
+		modelName:   modelName,
+		maxTokens:   maxTokens,
+		timeout:     time.Duration(timeoutSec) * time.Second,
+		bochaAPIKey: bochaAPIKey,
+	})
+}
+
+// 当前使用的模型名称
+func (s *llmServiceImpl) ModelName() string {
+	return s.settings.Load().modelName
+}
+
+// 全部工具名称
+func (s *llmServiceImpl) ToolNames() []string {
+	names := make([]string, 0, len(s.tools))
+	for _, t := range s.tools {
+		names = append(names, t.Function.Name)
+	}
+	return names
+}
+
+// 注册外部工具（启动时调用，与请求处理不并发）
+func (s *llmServiceImpl) RegisterTool(provider ToolProvider) {
+	tool := provider.Definition()
+	s.tools = append(s.tools, tool)
+	s.providers[tool.Function.Name] = provider
+}
+
+// resolve 在当前参数快照上应用单次调用参数，按名称筛选工具并去掉对该助手不可用的外部工具
+func (s *llmServiceImpl) resolve(ctx context.Context, opts CallOptions) (*llmSettings, []Tool) {
+	cfg := *s.settings.Load()
+	if opts.Model != "" {
+		cfg.modelName = opts.Model
+	}
+	if opts.MaxTokens > 0 {
+		cfg.maxTokens = opts.MaxTokens
+	}
+	if opts.Temperature != nil {
+		cfg.temperature = opts.Temperature
+	}
+	tools := make([]Tool, 0, len(s.tools))
+	for _, t := range s.tools {
+		if opts.Tools != nil && !slices.Contains(opts.Tools, t.Function.Name) {
+			continue
+		}
+		if p, ok := s.providers[t.Function.Name]; ok && !p.Available(ctx, opts.AssistantID) {
+			continue
+		}
+		tools = append(tools, t)
+	}
+	return &cfg, tools
+}
+
+// chatRequest 构造对话请求体（系统提示由调用方组装，有工具时才附带工具定义）
+func chatRequest(cfg *llmSettings, messages []Message, tools []Tool) map[string]interface{} {
+	reqBody := map[string]interface{}{
+		"model":      cfg.modelName,
+		"messages":   messages,
+		"max_tokens": cfg.maxTokens,
+	}
+	if len(tools) > 0 {
+		reqBody["tools"] = tools
+	}
+	if cfg.temperature != nil {
+		reqBody["temperature"] = *cfg.temperature
+	}
+	return reqBody
+}
+
+// 检查LLM接口可达性（不发起真实生成，避免消耗用量）
+func (s *llmServiceImpl) Ping(ctx context.Context) error {
+	cfg := s.settings.Load()
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, cfg.baseURL, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("LLM接口不可达: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("LLM接口异常: %d", resp.StatusCode)
+	}
+	return nil
 }
 
-// 非流式生成
-func (s *llmServiceImpl) GenerateReply(ctx context.Context, prompt string, input string) (string, error) {
+// 非流式生成（返回本次调用的用量，由调用方计入配额）
+func (s *llmServiceImpl) GenerateReply(ctx context.Context, prompt string, input string) (string, model.Usage, error) {
+	cfg := s.settings.Load()
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer cancel()
 	reqBody := map[string]interface{}{
-		"model": s.modelName,
+		"model": cfg.modelName,
 		"messages": []Message{
 			{Role: "system", Content: prompt},
 			{Role: "user", Content: input},
 		},
-		"max_tokens": s.maxTokens,
+		"max_tokens": cfg.maxTokens,
 	}
 
 	reqBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求失败: %w", err)
+		return "", model.Usage{}, fmt.Errorf("序列化请求失败: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL, bytes.NewBuffer(reqBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.baseURL, bytes.NewBuffer(reqBytes))
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
+		return "", model.Usage{}, fmt.Errorf("创建请求失败: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Authorization", "Bearer "+cfg.apiKey)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
+		return "", model.Usage{}, fmt.Errorf("发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API错误: %d, 内容: %s", resp.StatusCode, string(respBody))
+		return "", model.Usage{}, fmt.Errorf("API错误: %d, 内容: %s", resp.StatusCode, upstreamBody(respBody))
 	}
 
 	var response struct {
@@ -201,88 +368,263 @@ func (s *llmServiceImpl) GenerateReply(ctx context.Context, prompt string, input
 				Content string `json:"content"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
 	}
 	if err := json.Unmarshal(respBody, &response); err != nil {
-		return "", fmt.Errorf("解析响应失败: %w", err)
+		return "", model.Usage{}, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	usage := model.Usage{
+		InputTokens:  response.Usage.PromptTokens,
+		OutputTokens: response.Usage.CompletionTokens,
+		TotalTokens:  response.Usage.TotalTokens,
+	}
+	metrics.ObserveLLMTokens(cfg.modelName, usage)
+	if len(response.Choices) == 0 {
+		return "", usage, errors.New("无生成结果")
+	}
+
+	return response.Choices[0].Message.Content, usage, nil
+}
+
+// 非流式单轮调用（返回助手消息、结束原因和用量）
+func (s *llmServiceImpl) chatCompletion(ctx context.Context, cfg *llmSettings, messages []Message, tools []Tool) (_ Message, _ string, usage model.Usage, err error) {
+	start := time.Now()
+	defer metrics.ObserveLLMDuration(cfg.modelName, false, start)
+
+	ctx, span := tracing.Start(ctx, "llm.ChatCompletion",
+		attribute.String("llm.model", cfg.modelName),
+		attribute.Bool("llm.stream", false),
+		attribute.Int("llm.messages", len(messages)),
+		attribute.Int("llm.tools", len(tools)),
+	)
+	defer func() { tracing.EndWithError(span, err) }()
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer cancel()
+
+	reqBytes, err := json.Marshal(chatRequest(cfg, messages, tools))
+	if err != nil {
+		return Message{}, "", usage, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.baseURL, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return Message{}, "", usage, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.apiKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		metrics.IncLLMError(cfg.modelName, errorStatus(ctx))
+		return Message{}, "", usage, fmt.Errorf("发送请求失败: %w", err)
 	}
+	defer resp.Body.Close()
 
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		metrics.IncLLMError(cfg.modelName, strconv.Itoa(resp.StatusCode))
+		return Message{}, "", usage, fmt.Errorf("API错误: %d, 内容: %s", resp.StatusCode, upstreamBody(respBody))
+	}
+
+	var response struct {
+		Choices []struct {
+			Message      Message `json:"message"`
+			FinishReason string  `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		metrics.IncLLMError(cfg.modelName, "decode")
+		return Message{}, "", usage, fmt.Errorf("解析响应失败: %w", err)
+	}
 	if len(response.Choices) == 0 {
-		return "", errors.New("无生成结果")
+		return Message{}, "", usage, errors.New("无生成结果")
+	}
+
+	usage = model.Usage{
+		InputTokens:  response.Usage.PromptTokens,
+		OutputTokens: response.Usage.CompletionTokens,
+		TotalTokens:  response.Usage.TotalTokens,
+	}
+	span.SetAttributes(
+		attribute.Int("llm.usage.input_tokens", usage.InputTokens),
+		attribute.Int("llm.usage.output_tokens", usage.OutputTokens),
+		attribute.Int("llm.tool_calls", len(response.Choices[0].Message.ToolCalls)),
+	)
+	msg := response.Choices[0].Message
+	msg.Role = "assistant"
+	return msg, response.Choices[0].FinishReason, usage, nil
+}
+
+// 带搜索功能的非流式生成（与StreamGenerateWithSearch相同的两轮工具调用逻辑）
+func (s *llmServiceImpl) GenerateWithSearch(ctx context.Context, messages []Message, opts CallOptions) (*GenerateResult, error) {
+	cfg, tools := s.resolve(ctx, opts)
+	modelName := cfg.modelName
+	slog.DebugContext(ctx, "开始第一次LLM调用（非流式，判断是否需要工具）")
+	assistantMsg, finishReason, usage, err := s.chatCompletion(ctx, cfg, messages, tools)
+	if err != nil {
+		return nil, fmt.Errorf("第一次调用失败: %w", err)
+	}
+
+	metrics.ObserveLLMTokens(modelName, usage)
+	result := &GenerateResult{
+		Content:      assistantMsg.Content,
+		FinishReason: finishReason,
+		Usage:        usage,
+	}
+	if len(assistantMsg.ToolCalls) == 0 {
+		slog.DebugContext(ctx, "无需工具调用，直接返回第一次调用结果")
+		return result, nil
+	}
+
+	names := toolNames(assistantMsg.ToolCalls)
+	slog.InfoContext(ctx, "检测到工具调用，执行工具后发起第二次调用", "tools", names)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.StringSlice("llm.tool_names", names))
+	result.ToolCalls = assistantMsg.ToolCalls
+	toolResults, citations := s.executeTools(ctx, assistantMsg.ToolCalls, opts.AssistantID)
+	result.Citations = citations
+	messages = append(messages, assistantMsg)
+	messages = append(messages, toolResults...)
+
+	slog.DebugContext(ctx, "开始第二次LLM调用（非流式，生成最终回答）")
+	finalMsg, finishReason, finalUsage, err := s.chatCompletion(ctx, cfg, messages, tools)
+	if err != nil {
+		return nil, fmt.Errorf("第二次调用失败: %w", err)
 	}
 
-	return response.Choices[0].Message.Content, nil
+	result.Content = finalMsg.Content
+	result.FinishReason = finishReason
+	metrics.ObserveLLMTokens(modelName, finalUsage)
+	addUsage(&result.Usage, finalUsage)
+	if result.Content == "" {
+		slog.WarnContext(ctx, "第二次调用LLM未返回内容，使用兜底回复")
+		result.Content = emptyReplyFallback
+	}
+	return result, nil
 }
 
 // 流式生成基础实现
 func (s *llmServiceImpl) StreamGenerate(ctx context.Context, messages []Message, tools []Tool) (<-chan string, <-chan error) {
+	return s.streamGenerate(ctx, s.settings.Load(), messages, tools)
+}
+
+// streamGenerate 使用指定参数快照发起流式调用
+func (s *llmServiceImpl) streamGenerate(ctx context.Context, cfg *llmSettings, messages []Message, tools []Tool) (<-chan string, <-chan error) {
 	contentChan, errChan := make(chan string), make(chan error, 1)
 
 	go func() {
 		defer close(contentChan)
 		defer close(errChan)
-
-		// 系统提示：引导工具正确使用
-		enhancedMessages := append([]Message{
-			{
-				Role: "system",
-				Content: "当用户询问时间相关问题（如“现在几点了”），必须使用get_current_time工具；" +
-					"其他实时信息查询使用bocha_search工具；" +
-					"若搜索结果为空，告知用户未找到信息并建议调整关键词。",
-			},
-		}, messages...)
-
-		reqBody := map[string]interface{}{
-			"model":      s.modelName,
-			"messages":   enhancedMessages,
-			"max_tokens": s.maxTokens,
-			"stream":     true,
-			"tools":      tools,
+		start := time.Now()
+		defer metrics.ObserveLLMDuration(cfg.modelName, true, start)
+
+		ctx, span := tracing.Start(ctx, "llm.StreamGenerate",
+			attribute.String("llm.model", cfg.modelName),
+			attribute.Bool("llm.stream", true),
+			attribute.Int("llm.messages", len(messages)),
+			attribute.Int("llm.tools", len(tools)),
+		)
+		var spanErr error
+		defer func() { tracing.EndWithError(span, spanErr) }()
+		fail := func(err error) {
+			spanErr = err
+			errChan <- err
 		}
+
+		reqBody := chatRequest(cfg, messages, tools)
+		reqBody["stream"] = true
+		// 最后一个chunk返回用量统计
+		reqBody["stream_options"] = map[string]interface{}{"include_usage": true}
 		reqBytes, err := json.Marshal(reqBody)
 		if err != nil {
-			errChan <- fmt.Errorf("序列化失败: %w", err)
+			fail(fmt.Errorf("序列化失败: %w", err))
 			return
 		}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL, bytes.NewBuffer(reqBytes))
+		// 首个及相邻数据块之间超过timeout未收到数据时取消请求（不限制整个流的时长）
+		reqCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var stalled atomic.Bool
+		var idle *time.Timer
+		if cfg.timeout > 0 {
+			idle = time.AfterFunc(cfg.timeout, func() {
+				stalled.Store(true)
+				cancel()
+			})
+			defer idle.Stop()
+		}
+
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, cfg.baseURL, bytes.NewBuffer(reqBytes))
 		if err != nil {
-			errChan <- fmt.Errorf("创建请求失败: %w", err)
+			fail(fmt.Errorf("创建请求失败: %w", err))
 			return
 		}
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+		req.Header.Set("Authorization", "Bearer "+cfg.apiKey)
 
 		resp, err := s.client.Do(req)
 		if err != nil {
-			errChan <- fmt.Errorf("发送请求失败: %w", err)
+			if stalled.Load() {
+				metrics.IncLLMError(cfg.modelName, "timeout")
+				fail(fmt.Errorf("等待响应超时（%s）", cfg.timeout))
+				return
+			}
+			metrics.IncLLMError(cfg.modelName, errorStatus(ctx))
+			fail(fmt.Errorf("发送请求失败: %w", err))
 			return
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
+			metrics.IncLLMError(cfg.modelName, strconv.Itoa(resp.StatusCode))
 			body, _ := io.ReadAll(resp.Body)
-			errChan <- fmt.Errorf("API错误: %d, 内容: %s", resp.StatusCode, string(body))
+			fail(fmt.Errorf("API错误: %d, 内容: %s", resp.StatusCode, upstreamBody(body)))
 			return
 		}
 
 		scanner := bufio.NewScanner(resp.Body)
 		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
 
+		firstChunk := true
 		for scanner.Scan() {
 			select {
 			case <-ctx.Done():
 				resp.Body.Close()
-				errChan <- ctx.Err()
+				metrics.IncLLMError(cfg.modelName, "canceled")
+				fail(ctx.Err())
 				return
 			default:
+				if idle != nil {
+					idle.Reset(cfg.timeout)
+				}
 				line := strings.TrimSpace(scanner.Text())
 				if line != "" && !strings.HasPrefix(line, "data: [DONE]") {
+					if firstChunk {
+						span.AddEvent("first_chunk")
+						metrics.ObserveLLMFirstToken(cfg.modelName, start)
+						firstChunk = false
+					}
 					contentChan <- strings.TrimPrefix(line, "data: ")
 				}
 			}
 		}
 		if err := scanner.Err(); err != nil {
-			errChan <- fmt.Errorf("读取流失败: %w", err)
+			if stalled.Load() {
+				metrics.IncLLMError(cfg.modelName, "timeout")
+				fail(fmt.Errorf("读取流超时（%s未收到数据）", cfg.timeout))
+				return
+			}
+			metrics.IncLLMError(cfg.modelName, "stream_read")
+			fail(fmt.Errorf("读取流失败: %w", err))
 		}
 	}()
 
@@ -290,52 +632,61 @@ func (s *llmServiceImpl) StreamGenerate(ctx context.Context, messages []Message,
 }
 
 // 带搜索功能的流式生成
-func (s *llmServiceImpl) StreamGenerateWithSearch(ctx context.Context, messages []Message) (<-chan string, <-chan error) {
+func (s *llmServiceImpl) StreamGenerateWithSearch(ctx context.Context, messages []Message, opts CallOptions) (<-chan string, <-chan error, *StreamResult) {
 	contentChan := make(chan string)
 	errChan := make(chan error, 1)
+	result := &StreamResult{}
+	usage := &result.Usage
+	cfg, tools := s.resolve(ctx, opts)
+	modelName := cfg.modelName
 
 	go func() {
 		defer func() {
+			metrics.ObserveLLMTokens(modelName, *usage)
 			close(contentChan)
 			close(errChan)
-			log.Println("所有流式数据处理完成")
+			slog.DebugContext(ctx, "所有流式数据处理完成", "total_tokens", usage.TotalTokens)
 		}()
 
-		log.Println("开始第一次LLM调用（判断是否需要工具）")
-		streamChan, streamErrChan := s.StreamGenerate(ctx, messages, s.tools)
+		slog.DebugContext(ctx, "开始第一次LLM调用（判断是否需要工具）")
+		streamChan, streamErrChan := s.streamGenerate(ctx, cfg, messages, tools)
 
-		toolCalls, assistantMsg, err := s.parseToolCalls(streamChan, streamErrChan, contentChan)
+		toolCalls, assistantMsg, err := s.parseToolCalls(ctx, streamChan, streamErrChan, contentChan, usage)
 		if err != nil {
 			errChan <- fmt.Errorf("第一次调用解析失败: %w", err)
 			return
 		}
 
 		if len(toolCalls) == 0 {
-			log.Println("无需工具调用，第一次调用流式内容已完成")
+			slog.DebugContext(ctx, "无需工具调用，第一次调用流式内容已完成")
 			return
 		}
 
-		log.Printf("检测到%d个工具调用，执行工具后发起第二次调用", len(toolCalls))
+		names := toolNames(toolCalls)
+		slog.InfoContext(ctx, "检测到工具调用，执行工具后发起第二次调用", "tools", names)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.StringSlice("llm.tool_names", names))
+		result.ToolCalls = toolCalls
 		messages = append(messages, assistantMsg)
-		toolResults := s.executeTools(ctx, toolCalls) // 执行工具（含搜索和时间工具）
+		toolResults, citations := s.executeTools(ctx, toolCalls, opts.AssistantID) // 执行工具（含搜索和时间工具）
+		result.Citations = citations
 		messages = append(messages, toolResults...)
 
-		log.Println("开始第二次LLM调用（生成最终回答）")
-		finalChan, finalErrChan := s.StreamGenerate(ctx, messages, s.tools)
+		slog.DebugContext(ctx, "开始第二次LLM调用（生成最终回答）")
+		finalChan, finalErrChan := s.streamGenerate(ctx, cfg, messages, tools)
 
-		if err := s.forwardStream(finalChan, finalErrChan, contentChan); err != nil {
+		if err := s.forwardStream(ctx, finalChan, finalErrChan, contentChan, usage); err != nil {
 			errChan <- fmt.Errorf("第二次调用转发失败: %w", err)
 			return
 		}
 
-		log.Println("第二次LLM调用流式内容处理完成")
+		slog.DebugContext(ctx, "第二次LLM调用流式内容处理完成")
 	}()
 
-	return contentChan, errChan
+	return contentChan, errChan, result
 }
 
 // 解析流式响应
-func (s *llmServiceImpl) parseToolCalls(streamChan <-chan string, errChan <-chan error, contentChan chan<- string) ([]ToolCall, Message, error) {
+func (s *llmServiceImpl) parseToolCalls(ctx context.Context, streamChan <-chan string, errChan <-chan error, contentChan chan<- string, usage *model.Usage) ([]ToolCall, Message, error) {
 	type partialTool struct {
 		id        string
 		name      string
@@ -353,16 +704,18 @@ func (s *llmServiceImpl) parseToolCalls(streamChan <-chan string, errChan <-chan
 					ToolCalls []ToolCall `json:"tool_calls"`
 				} `json:"delta"`
 			} `json:"choices"`
+			Usage *streamUsage `json:"usage"`
 		}
 
 		if err := json.Unmarshal([]byte(chunk), &resp); err != nil {
-			log.Printf("解析流式chunk失败（非致命）: %v, chunk: %s", err, chunk)
+			slog.WarnContext(ctx, "解析流式chunk失败（非致命）", "error", err, "chunk", chunk)
 			continue
 		}
+		resp.Usage.addTo(usage)
 
 		for _, choice := range resp.Choices {
 			if choice.Delta.Content != "" {
-				log.Printf("第一次调用流式内容: %s", choice.Delta.Content)
+				slog.DebugContext(ctx, "第一次调用流式内容", "content", choice.Delta.Content)
 				contentChan <- choice.Delta.Content
 				assistantMsg.Content += choice.Delta.Content
 			}
@@ -411,138 +764,146 @@ func (s *llmServiceImpl) parseToolCalls(streamChan <-chan string, errChan <-chan
 	return toolCalls, assistantMsg, nil
 }
 
-// 执行工具调用（含搜索和时间工具逻辑）
-func (s *llmServiceImpl) executeTools(ctx context.Context, calls []ToolCall) []Message {
+// 执行工具调用（含搜索和时间工具逻辑），返回工具结果消息与搜索引用
+func (s *llmServiceImpl) executeTools(ctx context.Context, calls []ToolCall, assistantID string) ([]Message, []model.Citation) {
 	var results []Message
-	log.Printf("开始执行%d个工具调用", len(calls))
-
+	var citations []model.Citation
 	for i, call := range calls {
-		log.Printf("执行第%d个工具调用: %s", i+1, call.Function.Name)
-
-		// 处理本地时间工具（无需外部API）
-		if call.Function.Name == "get_current_time" {
-			currentTime := time.Now().In(s.beijingLocation).Format("2006-01-02 15:04:05")
-			resultContent := fmt.Sprintf("当前北京时间: %s", currentTime)
-			results = append(results, Message{
-				Role:       "tool",
-				Content:    resultContent,
-				ToolCallID: call.ID,
-			})
-			log.Println("本地时间工具调用完成，返回当前北京时间")
-			continue
-		}
+		slog.DebugContext(ctx, "执行工具调用", "index", i+1, "total", len(calls), "tool", call.Function.Name)
+		msg, cited := s.executeTool(ctx, call, assistantID)
+		results = append(results, msg)
+		citations = append(citations, cited...)
+	}
+	return results, citations
+}
 
-		// 处理搜索工具
-		if call.Function.Name != "bocha_search" {
-			results = append(results, Message{
-				Role:       "tool",
-				Content:    fmt.Sprintf("不支持的工具: %s", call.Function.Name),
-				ToolCallID: call.ID,
-			})
-			continue
-		}
+// 执行单个工具调用（每次调用一个span，并记录调用结果指标），搜索工具同时返回引用
+func (s *llmServiceImpl) executeTool(ctx context.Context, call ToolCall, assistantID string) (Message, []model.Citation) {
+	ctx, span := tracing.Start(ctx, "tool."+call.Function.Name,
+		attribute.String("tool.name", call.Function.Name),
+		attribute.String("tool.call_id", call.ID),
+	)
+	defer span.End()
+
+	result := func(content, outcome string) Message {
+		metrics.IncToolExecution(call.Function.Name, outcome)
+		span.SetAttributes(attribute.String("tool.outcome", outcome))
+		return Message{Role: "tool", Content: content, ToolCallID: call.ID}
+	}
 
-		// 解析搜索参数
-		var params map[string]interface{}
-		if err := json.Unmarshal([]byte(call.Function.Arguments), &params); err != nil {
-			results = append(results, Message{
-				Role:       "tool",
-				Content:    fmt.Sprintf("参数解析错误: %v", err),
-				ToolCallID: call.ID,
-			})
-			continue
-		}
+	// 处理本地时间工具（无需外部API）
+	if call.Function.Name == "get_current_time" {
+		currentTime := time.Now().In(s.beijingLocation).Format("2006-01-02 15:04:05")
+		slog.DebugContext(ctx, "本地时间工具调用完成，返回当前北京时间")
+		return result(fmt.Sprintf("当前北京时间: %s", currentTime), "success"), nil
+	}
 
-		query, ok := params["query"].(string)
-		if !ok || strings.TrimSpace(query) == "" {
-			results = append(results, Message{
-				Role:       "tool",
-				Content:    "错误：搜索关键词不能为空",
-				ToolCallID: call.ID,
-			})
-			continue
+	// 处理外部注册的工具（无引用视为无结果）
+	if p, ok := s.providers[call.Function.Name]; ok {
+		content, cited, err := p.Execute(ctx, assistantID, call.Function.Arguments)
+		if err != nil {
+			span.RecordError(err)
+			slog.WarnContext(ctx, "工具调用失败", "tool", call.Function.Name, "error", err)
+			return result(fmt.Sprintf("工具调用失败: %v", err), "error"), nil
 		}
-
-		// 构建搜索请求（修正：freshness默认值与工具定义一致，使用oneWeek）
-		freshness := "oneWeek" // 与工具定义的default保持一致
-		if f, ok := params["freshness"].(string); ok && f != "" {
-			freshness = f
+		outcome := "success"
+		if len(cited) == 0 {
+			outcome = "empty"
 		}
+		span.SetAttributes(attribute.Int("tool.results", len(cited)))
+		return result(content, outcome), cited
+	}
 
-		count := 10
-		if c, ok := params["count"].(float64); ok && c > 0 {
-			count = int(c)
-		}
+	// 处理搜索工具
+	if call.Function.Name != "bocha_search" {
+		return result(fmt.Sprintf("不支持的工具: %s", call.Function.Name), "unsupported"), nil
+	}
 
-		searchReq := BochaSearchRequest{
-			Query:     query,
-			Freshness: freshness,
-			Count:     count,
-			Summary:   true,
-		}
+	// 解析搜索参数
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &params); err != nil {
+		return result(fmt.Sprintf("参数解析错误: %v", err), "error"), nil
+	}
 
-		// 执行搜索（最多重试3次）
-		var resp *BochaSearchResponse
-		var err error
-		maxRetries := 3
-		for retry := 0; retry < maxRetries; retry++ {
-			reqJSON, _ := json.Marshal(searchReq)
-			log.Printf("第%d次尝试调用博查API，请求参数: %s", retry+1, string(reqJSON))
-
-			resp, err = s.callBochaAPI(ctx, searchReq)
-			if err != nil {
-				log.Printf("第%d次搜索失败（将重试）：%v", retry+1, err)
-				time.Sleep(1 * time.Second)
-				continue
-			}
-			if len(resp.Data.WebPages.Value) > 0 {
-				log.Printf("第%d次搜索成功，获取到%d条结果", retry+1, len(resp.Data.WebPages.Value))
-				break
-			}
-			log.Printf("第%d次搜索无结果（将重试）：%s", retry+1, query)
-			time.Sleep(1 * time.Second)
+	query, ok := params["query"].(string)
+	if !ok || strings.TrimSpace(query) == "" {
+		return result("错误：搜索关键词不能为空", "error"), nil
+	}
+
+	// 构建搜索请求（修正：freshness默认值与工具定义一致，使用oneWeek）
+	freshness := "oneWeek" // 与工具定义的default保持一致
+	if f, ok := params["freshness"].(string); ok && f != "" {
+		freshness = f
+	}
+
+	count := 10
+	if c, ok := params["count"].(float64); ok && c > 0 {
+		count = int(c)
+	}
+
+	searchReq := BochaSearchRequest{
+		Query:     query,
+		Freshness: freshness,
+		Count:     count,
+		Summary:   true,
+	}
+
+	// 执行搜索（最多重试3次）
+	var resp *BochaSearchResponse
+	var err error
+	maxRetries := 3
+	for retry := 0; retry < maxRetries; retry++ {
+		if retry > 0 {
+			metrics.IncBochaRetry()
 		}
+		slog.DebugContext(ctx, "尝试调用博查API", "attempt", retry+1, "query", query, "freshness", freshness, "count", count)
 
-		// 处理搜索结果
+		resp, err = s.callBochaAPI(ctx, searchReq)
 		if err != nil {
-			results = append(results, Message{
-				Role:       "tool",
-				Content:    fmt.Sprintf("搜索失败（已重试3次）: %v", err),
-				ToolCallID: call.ID,
-			})
+			slog.WarnContext(ctx, "博查搜索失败（将重试）", "attempt", retry+1, "error", err)
+			time.Sleep(1 * time.Second)
 			continue
 		}
+		if len(resp.Data.WebPages.Value) > 0 {
+			slog.DebugContext(ctx, "博查搜索成功", "attempt", retry+1, "results", len(resp.Data.WebPages.Value))
+			break
+		}
+		slog.WarnContext(ctx, "博查搜索无结果（将重试）", "attempt", retry+1, "query", query)
+		time.Sleep(1 * time.Second)
+	}
 
-		respJSON, _ := json.Marshal(resp)
-		log.Printf("博查API最终响应: %s", string(respJSON))
+	// 处理搜索结果
+	if err != nil {
+		span.RecordError(err)
+		return result(fmt.Sprintf("搜索失败（已重试3次）: %v", err), "error"), nil
+	}
 
-		formattedResult := s.formatSearchResult(resp)
-		results = append(results, Message{
-			Role:       "tool",
-			Content:    formattedResult,
-			ToolCallID: call.ID,
-		})
-		log.Printf("搜索工具调用完成，实际获取到%d条结果（请求count=%d）", len(resp.Data.WebPages.Value), count)
+	outcome := "success"
+	if len(resp.Data.WebPages.Value) == 0 {
+		outcome = "empty"
 	}
-	return results
+	span.SetAttributes(attribute.Int("tool.results", len(resp.Data.WebPages.Value)))
+	slog.InfoContext(ctx, "搜索工具调用完成", "results", len(resp.Data.WebPages.Value), "count", count)
+	return result(s.formatSearchResult(resp), outcome), citationsOf(resp)
 }
 
 // 调用博查API
 func (s *llmServiceImpl) callBochaAPI(ctx context.Context, req BochaSearchRequest) (*BochaSearchResponse, error) {
+	cfg := s.settings.Load()
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer cancel()
 	apiURL := "https://api.bochaai.com/v1/web-search"
 	reqBytes, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("序列化请求失败: %w", err)
 	}
 
-	log.Printf("调用博查API，URL: %s，请求体: %s", apiURL, string(reqBytes))
-
 	reqHTTP, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewBuffer(reqBytes))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 	reqHTTP.Header.Set("Content-Type", "application/json")
-	reqHTTP.Header.Set("Authorization", "Bearer "+s.bochaAPIKey)
+	reqHTTP.Header.Set("Authorization", "Bearer "+cfg.bochaAPIKey)
 
 	resp, err := s.client.Do(reqHTTP)
 	if err != nil {
@@ -555,15 +916,15 @@ func (s *llmServiceImpl) callBochaAPI(ctx context.Context, req BochaSearchReques
 		return nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 
-	log.Printf("博查API响应状态码: %d，响应体: %s", resp.StatusCode, string(respBody))
+	slog.DebugContext(ctx, "博查API响应", "status", resp.StatusCode, "body", string(respBody))
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP错误: %d, 内容: %s", resp.StatusCode, string(respBody))
+		return nil, fmt.Errorf("HTTP错误: %d, 内容: %s", resp.StatusCode, upstreamBody(respBody))
 	}
 
 	var searchResp BochaSearchResponse
 	if err := json.Unmarshal(respBody, &searchResp); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w，响应体: %s", err, string(respBody))
+		return nil, fmt.Errorf("解析响应失败: %w，响应体: %s", err, upstreamBody(respBody))
 	}
 
 	if searchResp.Code != 200 {
@@ -592,8 +953,22 @@ func (s *llmServiceImpl) formatSearchResult(resp *BochaSearchResponse) string {
 	return result.String()
 }
 
+// 搜索结果转为引用
+func citationsOf(resp *BochaSearchResponse) []model.Citation {
+	citations := make([]model.Citation, 0, len(resp.Data.WebPages.Value))
+	for _, item := range resp.Data.WebPages.Value {
+		citations = append(citations, model.Citation{
+			Title:         item.Name,
+			URL:           item.Url,
+			Snippet:       item.Snippet,
+			DatePublished: item.DatePublished,
+		})
+	}
+	return citations
+}
+
 // 转发流式结果
-func (s *llmServiceImpl) forwardStream(finalChan <-chan string, finalErrChan <-chan error, contentChan chan<- string) error {
+func (s *llmServiceImpl) forwardStream(ctx context.Context, finalChan <-chan string, finalErrChan <-chan error, contentChan chan<- string, usage *model.Usage) error {
 	hasContent := false
 	for chunk := range finalChan {
 		var streamResp struct {
@@ -602,25 +977,27 @@ func (s *llmServiceImpl) forwardStream(finalChan <-chan string, finalErrChan <-c
 					Content string `json:"content"`
 				} `json:"delta"`
 			} `json:"choices"`
+			Usage *streamUsage `json:"usage"`
 		}
 
 		if err := json.Unmarshal([]byte(chunk), &streamResp); err != nil {
-			log.Printf("第二次调用解析chunk失败（非致命）: %v, chunk: %s", err, chunk)
+			slog.WarnContext(ctx, "第二次调用解析chunk失败（非致命）", "error", err, "chunk", chunk)
 			continue
 		}
+		streamResp.Usage.addTo(usage)
 
 		for _, choice := range streamResp.Choices {
 			if choice.Delta.Content != "" {
 				hasContent = true
-				log.Printf("第二次调用流式内容: %s", choice.Delta.Content)
+				slog.DebugContext(ctx, "第二次调用流式内容", "content", choice.Delta.Content)
 				contentChan <- choice.Delta.Content
 			}
 		}
 	}
 
 	if !hasContent {
-		log.Println("第二次调用LLM未返回内容")
-		contentChan <- "抱歉，暂时无法获取相关信息。请尝试调整问题或提供更多细节。"
+		slog.WarnContext(ctx, "第二次调用LLM未返回内容，使用兜底回复")
+		contentChan <- emptyReplyFallback
 	}
 
 	if err := <-finalErrChan; err != nil {
@@ -629,3 +1006,64 @@ func (s *llmServiceImpl) forwardStream(finalChan <-chan string, finalErrChan <-c
 
 	return nil
 }
+
+// 流式响应中的用量统计（仅最后一个chunk携带）
+type streamUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *streamUsage) addTo(dst *model.Usage) {
+	if u == nil || dst == nil {
+		return
+	}
+	addUsage(dst, model.Usage{
+		InputTokens:  u.PromptTokens,
+		OutputTokens: u.CompletionTokens,
+		TotalTokens:  u.TotalTokens,
+	})
+}
+
+// 工具调用名称列表（用于追踪属性）
+func toolNames(calls []ToolCall) []string {
+	names := make([]string, 0, len(calls))
+	for _, c := range calls {
+		names = append(names, c.Function.Name)
+	}
+	return names
+}
+
+// 累加多轮调用的用量
+// 错误信息中保留的上游响应体长度（响应体可能回显请求内容，不完整写入日志）
+const maxErrorBody = 256
+
+// upstreamBody 截断上游响应体用于错误信息
+func upstreamBody(body []byte) string {
+	if len(body) <= maxErrorBody {
+		return string(body)
+	}
+	cut := maxErrorBody
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s…（共%d字节）", body[:cut], len(body))
+}
+
+func addUsage(dst *model.Usage, u model.Usage) {
+	dst.InputTokens += u.InputTokens
+	dst.OutputTokens += u.OutputTokens
+	dst.TotalTokens += u.TotalTokens
+}
+
+// 网络错误分类（区分主动取消、超时与其他网络错误）
+func errorStatus(ctx context.Context) string {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return "canceled"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "network"
+	}
+}
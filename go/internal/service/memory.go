@@ -0,0 +1,524 @@
+package service
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/repository"
+	"Voice_Assistant/internal/tracing"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrMemoryNotFound          = errors.New("记忆不存在")
+	ErrInvalidMemory           = errors.New("记忆内容不能为空且不超过500字")
+	ErrMemoryLimit             = errors.New("记忆数量已达上限，请先删除不需要的记忆")
+	ErrMemoryAssistantNotFound = errors.New("助手不存在或无权访问")
+)
+
+const (
+	// 单条记忆最多字符数
+	maxMemoryRunes = 500
+	// 提取记忆时发送给模型的会话内容上限（超出时保留最近的部分）
+	maxTranscriptRunes = 6000
+	// 单次提取最多保存的记忆条数
+	maxExtractedMemories = 10
+)
+
+// 注入系统提示时记忆列表前的说明
+const memoryPromptHeader = "以下是关于用户的长期记忆（来自以往的对话，仅在与当前问题相关时使用，不要主动复述）："
+
+// 从会话中提取记忆的系统提示
+const memoryExtractionPrompt = "你负责从用户与助手的对话中提取值得长期记住的信息，" +
+	"例如用户的姓名、身份、偏好、长期目标，以及所在项目的约定和背景。" +
+	"不要提取一次性的问题、临时状态、助手的回答内容或敏感信息（如密码、证件号）。" +
+	"每条记忆用一句完整的陈述表达（如“用户是后端工程师，主要使用Go”）。" +
+	"只输出JSON数组，不要输出其他内容，格式为[{\"content\":\"记忆内容\",\"shared\":true}]，" +
+	"其中shared表示该信息是否与具体助手无关（如个人信息、通用偏好）；没有值得记住的信息时输出[]。"
+
+// MemoryConfig 长期记忆参数（零值使用默认值）
+type MemoryConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	TopK           int     `yaml:"top_k"`            // 每次对话注入系统提示的记忆条数
+	MinScore       float64 `yaml:"min_score"`        // 按相关度选取时低于该相似度的记忆不注入
+	MaxPerUser     int     `yaml:"max_per_user"`     // 每个用户最多保存的记忆条数
+	ExtractOnReset bool    `yaml:"extract_on_reset"` // 重置对话时是否由模型从会话中提取记忆
+}
+
+func (c MemoryConfig) withDefaults() MemoryConfig {
+	if c.TopK <= 0 {
+		c.TopK = 5
+	}
+	if c.MaxPerUser <= 0 {
+		c.MaxPerUser = 200
+	}
+	return c
+}
+
+// MemoryService 当前用户的长期记忆（同时作为remember工具注册到LLM服务）
+type MemoryService interface {
+	ToolProvider
+	// assistantID非空时只返回该助手可用的记忆（共享+专属）
+	List(ctx context.Context, assistantID string) ([]model.Memory, error)
+	// assistantID为空时对该用户的所有助手生效
+	Create(ctx context.Context, content, assistantID string) (*model.Memory, error)
+	Update(ctx context.Context, id, content, assistantID string) (*model.Memory, error)
+	Delete(ctx context.Context, id string) error
+	// assistantID为空时清空全部记忆，否则只清空该助手专属的记忆；返回删除条数
+	Clear(ctx context.Context, assistantID string) (int, error)
+	// 与本次输入相关的记忆，格式化为系统提示片段（没有可用记忆时返回空字符串，出错时不影响对话）
+	Recall(ctx context.Context, assistantID, input string) string
+	// 从即将清空的会话中提取记忆（未启用extract_on_reset时直接返回）
+	Extract(ctx context.Context, assistantID string, history *model.History)
+}
+
+type memoryServiceImpl struct {
+	memoryRepo    repository.MemoryRepo
+	assistantRepo repository.AssistantRepo
+	llmService    LLMService
+	quotaService  QuotaService // 提取调用的配额校验与用量记录
+	embedder      Embedder     // 为nil时不按相关度选取，使用最近修改的记忆
+	cfg           MemoryConfig
+}
+
+func NewMemoryService(memoryRepo repository.MemoryRepo, assistantRepo repository.AssistantRepo, llmService LLMService, quotaService QuotaService, embedder Embedder, cfg MemoryConfig) MemoryService {
+	return &memoryServiceImpl{
+		memoryRepo:    memoryRepo,
+		assistantRepo: assistantRepo,
+		llmService:    llmService,
+		quotaService:  quotaService,
+		embedder:      embedder,
+		cfg:           cfg.withDefaults(),
+	}
+}
+
+// List 当前用户的记忆（按修改时间倒序）
+func (s *memoryServiceImpl) List(ctx context.Context, assistantID string) ([]model.Memory, error) {
+	if assistantID != "" {
+		if err := s.checkAssistant(ctx, assistantID); err != nil {
+			return nil, err
+		}
+	}
+	memories, err := s.memoryRepo.SelectByUser(ctx, auth.UserID(ctx), assistantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询记忆失败: %w", err)
+	}
+	return memories, nil
+}
+
+// Create 手动添加记忆（与已有记忆相同时返回已有记忆）
+func (s *memoryServiceImpl) Create(ctx context.Context, content, assistantID string) (*model.Memory, error) {
+	m, _, err := s.save(ctx, assistantID, content, model.MemorySourceManual)
+	return m, err
+}
+
+// Update 修改记忆内容与适用助手
+func (s *memoryServiceImpl) Update(ctx context.Context, id, content, assistantID string) (*model.Memory, error) {
+	content = strings.TrimSpace(content)
+	if content == "" || utf8.RuneCountInString(content) > maxMemoryRunes {
+		return nil, ErrInvalidMemory
+	}
+	if assistantID != "" {
+		if err := s.checkAssistant(ctx, assistantID); err != nil {
+			return nil, err
+		}
+	}
+	m, err := s.memoryRepo.SelectByID(ctx, auth.UserID(ctx), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMemoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询记忆失败: %w", err)
+	}
+
+	if content != m.Content {
+		m.Content = content
+		m.EmbeddingModel, m.Embedding = s.embed(ctx, content)
+	}
+	m.AssistantID = assistantID
+	m.Source = model.MemorySourceManual
+	m.GmtModified = time.Now().Format("2006-01-02 15:04:05")
+	if err := s.memoryRepo.Update(ctx, m); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMemoryNotFound
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// Delete 删除记忆
+func (s *memoryServiceImpl) Delete(ctx context.Context, id string) error {
+	if err := s.memoryRepo.Delete(ctx, auth.UserID(ctx), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrMemoryNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Clear 清空记忆
+func (s *memoryServiceImpl) Clear(ctx context.Context, assistantID string) (int, error) {
+	if assistantID != "" {
+		if err := s.checkAssistant(ctx, assistantID); err != nil {
+			return 0, err
+		}
+	}
+	return s.memoryRepo.DeleteAll(ctx, auth.UserID(ctx), assistantID)
+}
+
+// Recall 选取注入系统提示的记忆
+func (s *memoryServiceImpl) Recall(ctx context.Context, assistantID, input string) string {
+	ctx, span := tracing.Start(ctx, "MemoryService.Recall", attribute.String("assistant.id", assistantID))
+	defer span.End()
+
+	memories, err := s.memoryRepo.SelectByUser(ctx, auth.UserID(ctx), assistantID)
+	if err != nil {
+		slog.WarnContext(ctx, "查询记忆失败，本次对话不使用记忆", "assistant_id", assistantID, "error", err)
+		return ""
+	}
+	selected := s.relevant(ctx, memories, input)
+	span.SetAttributes(attribute.Int("memory.total", len(memories)), attribute.Int("memory.recalled", len(selected)))
+	if len(selected) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(memoryPromptHeader)
+	for _, m := range selected {
+		b.WriteString("\n- ")
+		b.WriteString(m.Content)
+	}
+	return b.String()
+}
+
+// relevant 记忆不超过top_k条时全部使用，否则按与输入的相似度选取
+// （未启用文本向量或生成向量失败时使用最近修改的记忆）
+func (s *memoryServiceImpl) relevant(ctx context.Context, memories []model.Memory, input string) []model.Memory {
+	if len(memories) <= s.cfg.TopK {
+		return memories
+	}
+	recent := memories[:s.cfg.TopK]
+	if s.embedder == nil || strings.TrimSpace(input) == "" {
+		return recent
+	}
+	if err := s.refreshEmbeddings(ctx, memories); err != nil {
+		slog.WarnContext(ctx, "生成记忆向量失败，使用最近的记忆", "error", err)
+		return recent
+	}
+	vectors, err := s.embedder.Embed(ctx, []string{input})
+	if err != nil {
+		slog.WarnContext(ctx, "生成查询向量失败，使用最近的记忆", "error", err)
+		return recent
+	}
+
+	type scored struct {
+		memory model.Memory
+		score  float64
+	}
+	var candidates []scored
+	for _, m := range memories {
+		if len(m.Embedding) != len(vectors[0]) {
+			continue
+		}
+		if score := cosine(vectors[0], m.Embedding); score >= s.cfg.MinScore {
+			candidates = append(candidates, scored{m, score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	selected := make([]model.Memory, 0, s.cfg.TopK)
+	for _, c := range candidates[:min(len(candidates), s.cfg.TopK)] {
+		selected = append(selected, c.memory)
+	}
+	return selected
+}
+
+// refreshEmbeddings 为没有向量或由其他模型生成向量的记忆重新生成并保存向量
+func (s *memoryServiceImpl) refreshEmbeddings(ctx context.Context, memories []model.Memory) error {
+	var stale []int
+	for i, m := range memories {
+		if m.EmbeddingModel != s.embedder.Model() || len(m.Embedding) == 0 {
+			stale = append(stale, i)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	texts := make([]string, len(stale))
+	for i, idx := range stale {
+		texts[i] = memories[idx].Content
+	}
+	vectors, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+	if len(vectors) != len(texts) {
+		return fmt.Errorf("期望%d条，实际%d条", len(texts), len(vectors))
+	}
+	for i, idx := range stale {
+		m := &memories[idx]
+		m.EmbeddingModel, m.Embedding = s.embedder.Model(), vectors[i]
+		if err := s.memoryRepo.UpdateEmbedding(ctx, m); err != nil {
+			slog.WarnContext(ctx, "保存记忆向量失败", "memory_id", m.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// Extract 由模型从会话中提取记忆（调用失败只记录日志）
+func (s *memoryServiceImpl) Extract(ctx context.Context, assistantID string, history *model.History) {
+	if !s.cfg.ExtractOnReset || history == nil {
+		return
+	}
+	transcript := memoryTranscript(history.Messages)
+	if transcript == "" {
+		return
+	}
+	ctx, span := tracing.Start(ctx, "MemoryService.Extract", attribute.String("assistant.id", assistantID))
+	defer span.End()
+
+	existing, err := s.memoryRepo.SelectByUser(ctx, auth.UserID(ctx), assistantID)
+	if err != nil {
+		span.RecordError(err)
+		slog.WarnContext(ctx, "查询记忆失败，跳过提取", "assistant_id", assistantID, "error", err)
+		return
+	}
+	// 已有记忆一并告知模型，避免重复提取
+	prompt := memoryExtractionPrompt
+	if len(existing) > 0 {
+		var b strings.Builder
+		b.WriteString(prompt)
+		b.WriteString("\n\n以下是已保存的记忆，不要重复提取：")
+		for _, m := range existing {
+			b.WriteString("\n- ")
+			b.WriteString(m.Content)
+		}
+		prompt = b.String()
+	}
+
+	// 提取调用使用全局模型，与对话一样校验配额并计入该助手的用量（超限时跳过提取）
+	if err := s.quotaService.Check(ctx, assistantID); err != nil {
+		span.RecordError(err)
+		slog.InfoContext(ctx, "配额不足，跳过提取记忆", "assistant_id", assistantID, "error", err)
+		return
+	}
+	reply, usage, err := s.llmService.GenerateReply(ctx, prompt, transcript)
+	span.SetAttributes(usageAttributes(usage)...)
+	if usage.TotalTokens > 0 {
+		if err := s.quotaService.Record(ctx, assistantID, s.llmService.ModelName(), usage); err != nil {
+			slog.WarnContext(ctx, "记录用量失败", "assistant_id", assistantID, "error", err)
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.WarnContext(ctx, "提取记忆失败", "assistant_id", assistantID, "error", err)
+		return
+	}
+	facts, err := parseExtractedMemories(reply)
+	if err != nil {
+		span.RecordError(err)
+		// 回复可能包含会话内容，只记录长度
+		slog.WarnContext(ctx, "解析提取的记忆失败", "assistant_id", assistantID, "error", err, "reply_len", utf8.RuneCountInString(reply))
+		return
+	}
+
+	saved := 0
+	for _, f := range facts[:min(len(facts), maxExtractedMemories)] {
+		scope := assistantID
+		if f.Shared {
+			scope = ""
+		}
+		_, created, err := s.save(ctx, scope, f.Content, model.MemorySourceExtracted)
+		if errors.Is(err, ErrMemoryLimit) {
+			slog.WarnContext(ctx, "记忆数量已达上限，停止保存提取的记忆", "assistant_id", assistantID)
+			break
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "保存提取的记忆失败", "assistant_id", assistantID, "error", err)
+			continue
+		}
+		if created {
+			saved++
+		}
+	}
+	span.SetAttributes(attribute.Int("memory.extracted", len(facts)), attribute.Int("memory.saved", saved))
+	slog.InfoContext(ctx, "已从会话中提取记忆", "assistant_id", assistantID, "extracted", len(facts), "saved", saved)
+}
+
+// Definition remember工具定义
+func (s *memoryServiceImpl) Definition() Tool {
+	return Tool{
+		Type: "function",
+		Function: Function{
+			Name: "remember",
+			Description: "保存关于用户或项目的长期记忆（如姓名、偏好、长期目标、项目约定），以后的对话（包括重置对话后）仍可使用。" +
+				"仅在用户要求记住某事，或信息明显长期有效时调用；不要保存一次性信息或敏感信息（如密码）。",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"content": map[string]interface{}{
+						"type":        "string",
+						"description": "要记住的内容，用一句完整的陈述表达，如“用户喜欢简洁的回答”",
+					},
+					"shared": map[string]interface{}{
+						"type":        "boolean",
+						"description": "是否对该用户的所有助手生效（个人信息、通用偏好为true，只与当前助手相关为false）",
+						"default":     false,
+					},
+				},
+				"required": []string{"content"},
+			},
+		},
+	}
+}
+
+// Available 启用长期记忆时所有助手均可使用
+func (s *memoryServiceImpl) Available(ctx context.Context, assistantID string) bool {
+	return true
+}
+
+// Execute 执行remember工具调用
+func (s *memoryServiceImpl) Execute(ctx context.Context, assistantID string, arguments string) (string, []model.Citation, error) {
+	var params struct {
+		Content string `json:"content"`
+		Shared  bool   `json:"shared"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+		return "", nil, fmt.Errorf("参数解析错误: %w", err)
+	}
+	scope := assistantID
+	if params.Shared {
+		scope = ""
+	}
+	m, created, err := s.save(ctx, scope, params.Content, model.MemorySourceTool)
+	if err != nil {
+		return "", nil, err
+	}
+	if !created {
+		return "已有相同的记忆：" + m.Content, nil, nil
+	}
+	return "已记住：" + m.Content, nil, nil
+}
+
+// save 保存记忆（与同一范围内已有记忆相同时返回已有记忆，created为false）
+func (s *memoryServiceImpl) save(ctx context.Context, assistantID, content, source string) (_ *model.Memory, created bool, err error) {
+	content = strings.TrimSpace(content)
+	if content == "" || utf8.RuneCountInString(content) > maxMemoryRunes {
+		return nil, false, ErrInvalidMemory
+	}
+	if assistantID != "" {
+		if err := s.checkAssistant(ctx, assistantID); err != nil {
+			return nil, false, err
+		}
+	}
+	userID := auth.UserID(ctx)
+	existing, err := s.memoryRepo.SelectByUser(ctx, userID, "")
+	if err != nil {
+		return nil, false, fmt.Errorf("查询记忆失败: %w", err)
+	}
+	for _, m := range existing {
+		if m.AssistantID == assistantID && m.Content == content {
+			return &m, false, nil
+		}
+	}
+	if len(existing) >= s.cfg.MaxPerUser {
+		return nil, false, fmt.Errorf("%w（%d条）", ErrMemoryLimit, s.cfg.MaxPerUser)
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	m := &model.Memory{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		AssistantID: assistantID,
+		Content:     content,
+		Source:      source,
+		GmtCreate:   now,
+		GmtModified: now,
+	}
+	m.EmbeddingModel, m.Embedding = s.embed(ctx, content)
+	if err := s.memoryRepo.Save(ctx, m); err != nil {
+		return nil, false, err
+	}
+	slog.InfoContext(ctx, "记忆已保存", "memory_id", m.ID, "assistant_id", assistantID, "source", source)
+	return m, true, nil
+}
+
+// embed 生成记忆向量（未启用文本向量或失败时返回空，检索时再补生成）
+func (s *memoryServiceImpl) embed(ctx context.Context, content string) (string, []float32) {
+	if s.embedder == nil {
+		return "", nil
+	}
+	vectors, err := s.embedder.Embed(ctx, []string{content})
+	if err != nil || len(vectors) != 1 {
+		slog.WarnContext(ctx, "生成记忆向量失败，检索时重试", "error", err)
+		return "", nil
+	}
+	return s.embedder.Model(), vectors[0]
+}
+
+// checkAssistant 校验助手对当前用户可见
+func (s *memoryServiceImpl) checkAssistant(ctx context.Context, id string) error {
+	assistants, err := s.assistantRepo.SelectAll(ctx)
+	if err != nil {
+		return fmt.Errorf("查询助手失败: %w", err)
+	}
+	for _, a := range assistants {
+		if a.ID == id {
+			return nil
+		}
+	}
+	return ErrMemoryAssistantNotFound
+}
+
+// extractedMemory 模型提取的单条记忆
+type extractedMemory struct {
+	Content string `json:"content"`
+	Shared  bool   `json:"shared"`
+}
+
+// parseExtractedMemories 解析模型输出的JSON数组（容忍代码块等多余内容）
+func parseExtractedMemories(reply string) ([]extractedMemory, error) {
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, errors.New("未找到JSON数组")
+	}
+	var facts []extractedMemory
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &facts); err != nil {
+		return nil, err
+	}
+	return facts, nil
+}
+
+// memoryTranscript 会话内容转为文本（只取用户发起的轮次，超长时保留最近的部分）
+func memoryTranscript(messages []model.Message) string {
+	var lines []string
+	for _, m := range messages {
+		if m.Input.Send == "" {
+			continue
+		}
+		lines = append(lines, "用户："+m.Input.Send)
+		if m.Output.Content != "" {
+			lines = append(lines, "助手："+m.Output.Content)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	transcript := []rune(strings.Join(lines, "\n"))
+	if len(transcript) > maxTranscriptRunes {
+		transcript = transcript[len(transcript)-maxTranscriptRunes:]
+	}
+	return string(transcript)
+}
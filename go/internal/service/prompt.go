@@ -0,0 +1,314 @@
+package service
+
+import (
+	"Voice_Assistant/internal/model"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+var (
+	ErrInvalidPrompt    = errors.New("提示词模板不合法")
+	ErrInvalidVariables = errors.New("会话变量不合法")
+)
+
+// 内置的系统提示（配置未指定时使用）
+const (
+	DefaultPreamble  = "你是一个智能助手，会根据用户输入来挑选,如果是一些实时性的问答或者你只要通过调用提供的tools可以提升对话质量的就一定要调用。"
+	DefaultToolGuide = "当用户询问时间相关问题（如“现在几点了”），必须使用get_current_time工具；" +
+		"其他实时信息查询使用bocha_search工具；" +
+		"若搜索结果为空，告知用户未找到信息并建议调整关键词。"
+)
+
+// SystemPrompts 全局系统提示，与助手提示词按“前言、助手提示词、工具使用说明”的顺序合并为一条system消息
+type SystemPrompts struct {
+	Preamble  string
+	ToolGuide string // 仅在本次调用启用了工具时附加
+}
+
+// systemPrompt 合并系统提示（助手设置优先于全局配置，空内容跳过）
+func systemPrompt(global SystemPrompts, params model.ModelParams, prompt string, toolsEnabled bool) string {
+	preamble, toolGuide := global.Preamble, global.ToolGuide
+	if params.Preamble != nil {
+		preamble = *params.Preamble
+	}
+	if params.ToolGuide != nil {
+		toolGuide = *params.ToolGuide
+	}
+	parts := make([]string, 0, 3)
+	for _, p := range []string{preamble, prompt} {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if toolGuide = strings.TrimSpace(toolGuide); toolsEnabled && toolGuide != "" {
+		parts = append(parts, toolGuide)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// 会话变量名（需能以 {{.Vars.名称}} 引用）
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// 会话标题未设置时，取首条用户消息的前若干字
+const defaultTitleRunes = 20
+
+var weekdayNames = [...]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// PromptData 提示词模板可引用的变量，如 {{.Date}}、{{.UserName}}、{{.Vars.city}}
+type PromptData struct {
+	Date              string            // 当前日期（2006-01-02）
+	Time              string            // 当前时间（15:04）
+	Weekday           string            // 星期几
+	Timezone          string            // 时区名称
+	UserID            string            // 当前用户ID（系统身份为空）
+	UserName          string            // 当前用户显示名称
+	AssistantName     string            // 助手名称
+	ConversationTitle string            // 会话标题
+	Vars              map[string]string // 会话自定义变量（未设置的变量为空字符串）
+}
+
+// 渲染结果最多比模板本身多出的字节数（防止嵌套模板等构造超大输出）
+const maxPromptExpansion = 64 << 10
+
+var errPromptTooLarge = errors.New("渲染结果过长")
+
+// printf的宽度与精度（含*及显式参数下标），如 %08.3f、%[1]*d
+var printfWidth = regexp.MustCompile(`%[-+# 0]*(?:\[\d+\])?(\*|\d+)?(?:\.(?:\[\d+\])?(\*|\d+))?`)
+
+// 模板函数：{{default "北京" .Vars.city}}
+var promptFuncs = template.FuncMap{
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	// 覆盖内置printf：fmt在写出前就会按宽度分配内存（如 %0999999999d），限制宽度与精度不超过3位数
+	"printf": func(format string, args ...any) (string, error) {
+		for _, m := range printfWidth.FindAllStringSubmatch(format, -1) {
+			for _, n := range m[1:] {
+				if n == "*" || len(n) > 3 {
+					return "", fmt.Errorf("printf的宽度或精度过大: %s", m[0])
+				}
+			}
+		}
+		return fmt.Sprintf(format, args...), nil
+	},
+}
+
+// limitedBuilder 超过上限时写入失败，模板执行随之中止
+type limitedBuilder struct {
+	strings.Builder
+	limit int
+}
+
+func (b *limitedBuilder) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errPromptTooLarge
+	}
+	return b.Builder.Write(p)
+}
+
+// 模板执行的节点数上限：输出长度限制挡不住不输出内容的循环（如 {{range 3000000000}}）与层层嵌套的模板调用
+const maxPromptSteps = 100000
+
+// 估算执行步数时假定range遍历的元素数（渲染时取会话变量数与该值的较大值）
+const minRangeItems = 16
+
+// promptCoster 按语法树估算模板执行的节点数
+type promptCoster struct {
+	tmpl       *template.Template
+	rangeItems int
+	memo       map[string]int
+	visiting   map[string]bool
+}
+
+// checkPromptCost range只能遍历数据字段（如 .Vars），模板不能递归调用，估算的执行步数不能超过上限
+func checkPromptCost(tmpl *template.Template, rangeItems int) error {
+	c := &promptCoster{tmpl: tmpl, rangeItems: rangeItems, memo: map[string]int{}, visiting: map[string]bool{}}
+	steps, err := c.template(tmpl.Name())
+	if err != nil {
+		return err
+	}
+	if steps > maxPromptSteps {
+		return errors.New("模板执行步数过多（循环或嵌套模板过深）")
+	}
+	return nil
+}
+
+// 超过上限后不再精确累加，避免溢出
+func capSteps(n int) int {
+	return min(n, maxPromptSteps+1)
+}
+
+func (c *promptCoster) template(name string) (int, error) {
+	if steps, ok := c.memo[name]; ok {
+		return steps, nil
+	}
+	if c.visiting[name] {
+		return 0, fmt.Errorf("模板%q不能递归调用", name)
+	}
+	t := c.tmpl.Lookup(name)
+	if t == nil || t.Tree == nil {
+		// 未定义的模板在执行时报错
+		return 1, nil
+	}
+	c.visiting[name] = true
+	steps, err := c.node(t.Tree.Root)
+	delete(c.visiting, name)
+	if err != nil {
+		return 0, err
+	}
+	c.memo[name] = capSteps(steps + 1)
+	return c.memo[name], nil
+}
+
+func (c *promptCoster) node(n parse.Node) (int, error) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return 0, nil
+		}
+		total := 0
+		for _, child := range n.Nodes {
+			steps, err := c.node(child)
+			if err != nil {
+				return 0, err
+			}
+			total = capSteps(total + steps)
+		}
+		return total, nil
+	case *parse.IfNode:
+		return c.branches(n.List, n.ElseList)
+	case *parse.WithNode:
+		return c.branches(n.List, n.ElseList)
+	case *parse.RangeNode:
+		if !isDataField(n.Pipe) {
+			return 0, fmt.Errorf("range只能遍历数据字段（如 .Vars）: {{range %s}}", n.Pipe)
+		}
+		body, err := c.node(n.List)
+		if err != nil {
+			return 0, err
+		}
+		elseSteps, err := c.node(n.ElseList)
+		if err != nil {
+			return 0, err
+		}
+		return capSteps(1 + (body+1)*c.rangeItems + elseSteps), nil
+	case *parse.TemplateNode:
+		return c.template(n.Name)
+	default:
+		return 1, nil
+	}
+}
+
+func (c *promptCoster) branches(list, elseList *parse.ListNode) (int, error) {
+	steps, err := c.node(list)
+	if err != nil {
+		return 0, err
+	}
+	elseSteps, err := c.node(elseList)
+	if err != nil {
+		return 0, err
+	}
+	return capSteps(1 + steps + elseSteps), nil
+}
+
+// isDataField 管道是否只引用数据字段（.Vars 或 $.Vars），排除整数、函数结果与变量
+func isDataField(pipe *parse.PipeNode) bool {
+	if len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		return true
+	case *parse.VariableNode:
+		return len(arg.Ident) > 1 && arg.Ident[0] == "$"
+	}
+	return false
+}
+
+// executePrompt 检查执行步数后执行模板并限制输出长度
+func executePrompt(tmpl *template.Template, prompt string, data *PromptData) (string, error) {
+	if err := checkPromptCost(tmpl, max(len(data.Vars), minRangeItems)); err != nil {
+		return "", err
+	}
+	b := &limitedBuilder{limit: len(prompt) + maxPromptExpansion}
+	if err := tmpl.Execute(b, data); err != nil {
+		if errors.Is(err, errPromptTooLarge) {
+			return "", errPromptTooLarge
+		}
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// newPromptData 按当前时间生成内置变量
+func newPromptData(now time.Time) *PromptData {
+	return &PromptData{
+		Date:     now.Format("2006-01-02"),
+		Time:     now.Format("15:04"),
+		Weekday:  weekdayNames[now.Weekday()],
+		Timezone: now.Location().String(),
+		Vars:     map[string]string{},
+	}
+}
+
+func parsePrompt(prompt string) (*template.Template, error) {
+	return template.New("prompt").Funcs(promptFuncs).Option("missingkey=zero").Parse(prompt)
+}
+
+// ValidatePrompt 校验提示词模板（语法错误与引用不存在的内置变量均视为不合法）
+func ValidatePrompt(prompt string) error {
+	tmpl, err := parsePrompt(prompt)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidPrompt, err)
+	}
+	if _, err := executePrompt(tmpl, prompt, newPromptData(time.Now())); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidPrompt, err)
+	}
+	return nil
+}
+
+// RenderPrompt 渲染提示词模板
+func RenderPrompt(prompt string, data *PromptData) (string, error) {
+	// 不含模板语法时直接返回，避免无谓的解析
+	if !strings.Contains(prompt, "{{") {
+		return prompt, nil
+	}
+	tmpl, err := parsePrompt(prompt)
+	if err != nil {
+		return "", err
+	}
+	return executePrompt(tmpl, prompt, data)
+}
+
+// validateVariables 校验会话变量名
+func validateVariables(vars map[string]string) error {
+	var invalid []string
+	for name := range vars {
+		if !variableNamePattern.MatchString(name) {
+			invalid = append(invalid, name)
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return fmt.Errorf("%w: 变量名只能包含字母、数字和下划线且不以数字开头（%s）", ErrInvalidVariables, strings.Join(invalid, "、"))
+	}
+	return nil
+}
+
+// defaultTitle 首条用户消息的前若干字
+func defaultTitle(send string) string {
+	runes := []rune(strings.TrimSpace(send))
+	if len(runes) > defaultTitleRunes {
+		return string(runes[:defaultTitleRunes]) + "…"
+	}
+	return string(runes)
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderPrompt(t *testing.T) {
+	now := time.Date(2026, 3, 8, 9, 5, 0, 0, time.UTC)
+	data := newPromptData(now)
+	data.UserName = "小王"
+	data.AssistantName = "导游"
+	data.Vars["city"] = "杭州"
+
+	tests := []struct {
+		name   string
+		prompt string
+		want   string
+	}{
+		{"无模板语法原样返回", "你是{导游}，今天100%可用", "你是{导游}，今天100%可用"},
+		{"内置变量", "{{.Date}} {{.Time}} {{.Weekday}} {{.Timezone}}", "2026-03-08 09:05 星期日 UTC"},
+		{"用户与助手", "{{.AssistantName}}为{{.UserName}}服务", "导游为小王服务"},
+		{"会话变量", "城市：{{.Vars.city}}", "城市：杭州"},
+		{"未设置的变量为空", "[{{.Vars.unknown}}]", "[]"},
+		{"default函数", `{{default "北京" .Vars.unknown}}/{{default "北京" .Vars.city}}`, "北京/杭州"},
+		{"printf", `{{printf "%05.1f" 3.14159}}`, "003.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderPrompt(tt.prompt, data)
+			if err != nil {
+				t.Fatalf("RenderPrompt: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderPrompt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderPromptLimits(t *testing.T) {
+	data := newPromptData(time.Now())
+	tests := []struct {
+		name    string
+		prompt  string
+		wantErr error
+	}{
+		{"printf宽度过大", `{{printf "%0999999999d" 1}}`, nil},
+		{"printf星号宽度", `{{printf "%*d" 999999999 1}}`, nil},
+		{"printf精度过大", `{{printf "%.9999f" 1.0}}`, nil},
+		{"嵌套模板放大输出", `{{define "a"}}` + strings.Repeat("x", 1000) + `{{end}}` +
+			`{{define "b"}}` + strings.Repeat(`{{template "a"}}`, 8) + `{{end}}` +
+			`{{define "c"}}` + strings.Repeat(`{{template "b"}}`, 8) + `{{end}}` +
+			strings.Repeat(`{{template "c"}}`, 8), errPromptTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RenderPrompt(tt.prompt, data)
+			if err == nil {
+				t.Fatal("RenderPrompt 未返回错误")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if verr := ValidatePrompt(tt.prompt); !errors.Is(verr, ErrInvalidPrompt) {
+				t.Errorf("ValidatePrompt err = %v, want ErrInvalidPrompt", verr)
+			}
+		})
+	}
+}
+
+func TestValidatePrompt(t *testing.T) {
+	tests := []struct {
+		prompt string
+		valid  bool
+	}{
+		{"普通提示词", true},
+		{"今天是{{.Date}}，城市{{.Vars.city}}", true},
+		{"{{.Date", false},
+		{"{{.NoSuchField}}", false},
+		{"{{undefinedFunc 1}}", false},
+	}
+	for _, tt := range tests {
+		err := ValidatePrompt(tt.prompt)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidatePrompt(%q) = %v, want valid=%v", tt.prompt, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidPrompt) {
+			t.Errorf("ValidatePrompt(%q) = %v, want ErrInvalidPrompt", tt.prompt, err)
+		}
+	}
+}
+
+func TestRenderPromptStepLimit(t *testing.T) {
+	// 2^20次模板调用，不输出任何内容
+	var nested strings.Builder
+	nested.WriteString(`{{define "t0"}}{{end}}`)
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&nested, `{{define "t%d"}}{{template "t%d"}}{{template "t%d"}}{{end}}`, i, i-1, i-1)
+	}
+	nested.WriteString(`{{template "t20"}}`)
+
+	tests := []struct {
+		name   string
+		prompt string
+	}{
+		{"range整数", "{{range 3000000000}}{{end}}"},
+		{"range变量", "{{$n := 3000000000}}{{range $n}}{{end}}"},
+		{"range函数结果", `{{range len "abc"}}{{end}}`},
+		{"range当前值", "{{with 3000000000}}{{range .}}{{end}}{{end}}"},
+		{"递归模板", `{{define "r"}}{{template "r"}}{{end}}{{template "r"}}`},
+		{"嵌套模板调用", nested.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			if err := ValidatePrompt(tt.prompt); !errors.Is(err, ErrInvalidPrompt) {
+				t.Errorf("ValidatePrompt err = %v, want ErrInvalidPrompt", err)
+			}
+			if _, err := RenderPrompt(tt.prompt, newPromptData(time.Now())); err == nil {
+				t.Error("RenderPrompt 未返回错误")
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Errorf("耗时%v，应在执行前拒绝", d)
+			}
+		})
+	}
+}
+
+func TestRenderPromptRangeVars(t *testing.T) {
+	data := newPromptData(time.Now())
+	data.Vars = map[string]string{"a": "1", "b": "2"}
+	got, err := RenderPrompt("{{range $k, $v := .Vars}}{{$k}}={{$v}};{{end}}{{range $.Vars}}.{{end}}", data)
+	if err != nil {
+		t.Fatalf("RenderPrompt: %v", err)
+	}
+	if want := "a=1;b=2;.."; got != want {
+		t.Errorf("RenderPrompt = %q, want %q", got, want)
+	}
+
+	// 嵌套遍历会话变量时按实际变量数估算步数
+	for i := 0; i < 1000; i++ {
+		data.Vars[fmt.Sprintf("v%d", i)] = ""
+	}
+	if _, err := RenderPrompt("{{range .Vars}}{{range $.Vars}}{{end}}{{end}}", data); err == nil {
+		t.Error("嵌套遍历大量变量时应返回错误")
+	}
+}
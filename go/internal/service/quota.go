@@ -0,0 +1,190 @@
+package service
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/repository"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QuotaExceededError 配额超限错误（结构化返回给调用方）
+type QuotaExceededError struct {
+	Scope  string  `json:"scope"`  // user/assistant
+	ID     string  `json:"id"`     // 用户ID或助手ID
+	Period string  `json:"period"` // daily/monthly
+	Metric string  `json:"metric"` // tokens/cost
+	Limit  float64 `json:"limit"`
+	Used   float64 `json:"used"`
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("配额已用尽: %s %s %s 上限 %v，已使用 %v", e.Scope, e.Period, e.Metric, e.Limit, e.Used)
+}
+
+// QuotaService 用量记录与配额校验业务接口
+type QuotaService interface {
+	// 调用LLM前校验当前用户与助手的配额
+	Check(ctx context.Context, assistantID string) error
+	// 记录一次LLM调用的用量
+	Record(ctx context.Context, assistantID, modelName string, usage model.Usage) error
+	// 查询当前用户（以及指定助手）的用量与上限
+	Report(ctx context.Context, assistantID string) ([]model.QuotaReport, error)
+	// 热更新配额策略与模型单价
+	UpdatePolicy(policy model.QuotaPolicy, pricing map[string]model.ModelPrice)
+}
+
+type quotaServiceImpl struct {
+	usageRepo repository.UsageRepo
+	mu        sync.RWMutex
+	policy    model.QuotaPolicy
+	pricing   map[string]model.ModelPrice
+}
+
+func NewQuotaService(usageRepo repository.UsageRepo, policy model.QuotaPolicy, pricing map[string]model.ModelPrice) QuotaService {
+	return &quotaServiceImpl{
+		usageRepo: usageRepo,
+		policy:    policy,
+		pricing:   pricing,
+	}
+}
+
+// UpdatePolicy 替换配额策略与单价（对之后的校验与记录生效）
+func (s *quotaServiceImpl) UpdatePolicy(policy model.QuotaPolicy, pricing map[string]model.ModelPrice) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+	s.pricing = pricing
+}
+
+// snapshot 读取当前配额策略与单价
+func (s *quotaServiceImpl) snapshot() (model.QuotaPolicy, map[string]model.ModelPrice) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policy, s.pricing
+}
+
+// Check 校验配额（未启用时直接通过）
+func (s *quotaServiceImpl) Check(ctx context.Context, assistantID string) error {
+	if policy, _ := s.snapshot(); !policy.Enabled {
+		return nil
+	}
+
+	reports, err := s.Report(ctx, assistantID)
+	if err != nil {
+		return err
+	}
+	for _, r := range reports {
+		if err := exceeded(r, "daily", r.Daily); err != nil {
+			return err
+		}
+		if err := exceeded(r, "monthly", r.Monthly); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Record 记录用量（按模型单价折算费用）
+func (s *quotaServiceImpl) Record(ctx context.Context, assistantID, modelName string, usage model.Usage) error {
+	if usage.TotalTokens == 0 && usage.InputTokens == 0 && usage.OutputTokens == 0 {
+		return nil
+	}
+
+	record := &model.UsageRecord{
+		ID:           uuid.New().String(),
+		UserID:       auth.UserID(ctx),
+		AssistantID:  assistantID,
+		Model:        modelName,
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		TotalTokens:  usage.TotalTokens,
+		Cost:         s.cost(ctx, modelName, usage),
+		GmtCreate:    time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := s.usageRepo.Save(ctx, record); err != nil {
+		return fmt.Errorf("记录用量失败: %w", err)
+	}
+	return nil
+}
+
+// Report 查询用量（用户维度始终返回，传入助手ID时追加助手维度）
+func (s *quotaServiceImpl) Report(ctx context.Context, assistantID string) ([]model.QuotaReport, error) {
+	now := time.Now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Format("2006-01-02 15:04:05")
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Format("2006-01-02 15:04:05")
+
+	policy, _ := s.snapshot()
+	userID := auth.UserID(ctx)
+	userReport := model.QuotaReport{Scope: "user", ID: userID}
+	limit := s.limitFor(policy.Users, userID, policy.DefaultUser)
+	if userID == "" {
+		// 系统身份（未启用鉴权或使用引导密钥）不是具体用户，不受用户配额限制（仍统计用量，助手配额照常生效）
+		limit = model.QuotaLimit{}
+	}
+	if err := s.fill(&userReport, limit, dayStart, monthStart, func(since string) (int, float64, error) {
+		return s.usageRepo.SumByUserSince(ctx, userID, since)
+	}); err != nil {
+		return nil, err
+	}
+	reports := []model.QuotaReport{userReport}
+
+	if assistantID != "" {
+		assistantReport := model.QuotaReport{Scope: "assistant", ID: assistantID}
+		limit := s.limitFor(policy.Assistants, assistantID, policy.DefaultAssistant)
+		if err := s.fill(&assistantReport, limit, dayStart, monthStart, func(since string) (int, float64, error) {
+			return s.usageRepo.SumByAssistantSince(ctx, assistantID, since)
+		}); err != nil {
+			return nil, err
+		}
+		reports = append(reports, assistantReport)
+	}
+	return reports, nil
+}
+
+func (s *quotaServiceImpl) fill(r *model.QuotaReport, limit model.QuotaLimit, dayStart, monthStart string, sum func(since string) (int, float64, error)) error {
+	dailyTokens, dailyCost, err := sum(dayStart)
+	if err != nil {
+		return err
+	}
+	monthlyTokens, monthlyCost, err := sum(monthStart)
+	if err != nil {
+		return err
+	}
+	r.Daily = model.UsageSummary{Tokens: dailyTokens, Cost: dailyCost, TokenLimit: limit.DailyTokens, CostLimit: limit.DailyCost}
+	r.Monthly = model.UsageSummary{Tokens: monthlyTokens, Cost: monthlyCost, TokenLimit: limit.MonthlyTokens, CostLimit: limit.MonthlyCost}
+	return nil
+}
+
+func (s *quotaServiceImpl) limitFor(overrides map[string]model.QuotaLimit, id string, def model.QuotaLimit) model.QuotaLimit {
+	if l, ok := overrides[id]; ok {
+		return l
+	}
+	return def
+}
+
+// 按模型单价计算费用（未配置单价的模型费用为0）
+func (s *quotaServiceImpl) cost(ctx context.Context, modelName string, usage model.Usage) float64 {
+	_, pricing := s.snapshot()
+	price, ok := pricing[modelName]
+	if !ok {
+		slog.WarnContext(ctx, "模型未配置单价，费用按0计算", "model", modelName)
+		return 0
+	}
+	return float64(usage.InputTokens)/1000*price.InputPer1K + float64(usage.OutputTokens)/1000*price.OutputPer1K
+}
+
+func exceeded(r model.QuotaReport, period string, u model.UsageSummary) error {
+	if u.TokenLimit > 0 && u.Tokens >= u.TokenLimit {
+		return &QuotaExceededError{Scope: r.Scope, ID: r.ID, Period: period, Metric: "tokens", Limit: float64(u.TokenLimit), Used: float64(u.Tokens)}
+	}
+	if u.CostLimit > 0 && u.Cost >= u.CostLimit {
+		return &QuotaExceededError{Scope: r.Scope, ID: r.ID, Period: period, Metric: "cost", Limit: u.CostLimit, Used: u.Cost}
+	}
+	return nil
+}
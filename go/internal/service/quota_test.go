@@ -0,0 +1,78 @@
+package service
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/data/sqlite"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/repository"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func newTestQuotaService(t *testing.T, policy model.QuotaPolicy) QuotaService {
+	t.Helper()
+	db, err := sqlite.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewQuotaService(repository.NewUsageRepo(db), policy, nil)
+}
+
+func TestQuotaUserLimit(t *testing.T) {
+	svc := newTestQuotaService(t, model.QuotaPolicy{Enabled: true, DefaultUser: model.QuotaLimit{DailyTokens: 10}})
+	u1 := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: "u1"})
+	u2 := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: "u2"})
+
+	if err := svc.Record(u1, "a1", "m", model.Usage{TotalTokens: 10}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	var quotaErr *QuotaExceededError
+	if err := svc.Check(u1, "a1"); !errors.As(err, &quotaErr) || quotaErr.Scope != "user" || quotaErr.ID != "u1" {
+		t.Errorf("u1 Check err = %v, want 用户配额超限", err)
+	}
+	// 用户配额按用户分别计算
+	if err := svc.Check(u2, "a1"); err != nil {
+		t.Errorf("u2 Check err = %v, want nil", err)
+	}
+}
+
+func TestQuotaSystemIdentity(t *testing.T) {
+	svc := newTestQuotaService(t, model.QuotaPolicy{
+		Enabled:     true,
+		DefaultUser: model.QuotaLimit{DailyTokens: 10},
+		Assistants:  map[string]model.QuotaLimit{"a2": {DailyTokens: 10}},
+	})
+	// 无身份与引导密钥（管理员、用户ID为空）均为系统身份
+	for _, ctx := range []context.Context{
+		context.Background(),
+		auth.WithPrincipal(context.Background(), &auth.Principal{Admin: true}),
+	} {
+		if err := svc.Record(ctx, "a1", "m", model.Usage{TotalTokens: 100}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+		// 系统身份不受用户配额限制
+		if err := svc.Check(ctx, "a1"); err != nil {
+			t.Errorf("系统身份 Check err = %v, want nil", err)
+		}
+	}
+
+	reports, err := svc.Report(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if r := reports[0]; r.Scope != "user" || r.ID != "" || r.Daily.Tokens != 200 || r.Daily.TokenLimit != 0 {
+		t.Errorf("系统身份用量 = %+v, want 统计200 token且不设上限", r)
+	}
+
+	// 助手配额对系统身份照常生效
+	if err := svc.Record(context.Background(), "a2", "m", model.Usage{TotalTokens: 10}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	var quotaErr *QuotaExceededError
+	if err := svc.Check(context.Background(), "a2"); !errors.As(err, &quotaErr) || quotaErr.Scope != "assistant" {
+		t.Errorf("系统身份 Check(a2) err = %v, want 助手配额超限", err)
+	}
+}
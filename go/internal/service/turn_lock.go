@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// 同一会话（助手+用户）并发对话轮次的处理策略
+const (
+	TurnPolicyQueue  = "queue"  // 排队：等待上一轮保存历史后再处理，保证上下文完整
+	TurnPolicyReject = "reject" // 拒绝：上一轮未结束时直接返回ErrConversationBusy
+	TurnPolicyAllow  = "allow"  // 允许：并发处理（历史追加仍是原子的，但后一轮看不到上一轮的回复）
+)
+
+var ErrConversationBusy = errors.New("该会话正在生成回复，请稍后再试")
+
+// IsValidTurnPolicy 判断并发策略是否合法
+func IsValidTurnPolicy(policy string) bool {
+	switch policy {
+	case TurnPolicyQueue, TurnPolicyReject, TurnPolicyAllow:
+		return true
+	default:
+		return false
+	}
+}
+
+// turnLocks 按会话加锁（无人持有或等待时回收）
+type turnLocks struct {
+	policy string
+	mu     sync.Mutex
+	locks  map[string]*turnLock
+}
+
+type turnLock struct {
+	sem  chan struct{}
+	refs int // 持有者与等待者数量
+}
+
+func newTurnLocks(policy string) *turnLocks {
+	return &turnLocks{policy: policy, locks: make(map[string]*turnLock)}
+}
+
+// acquire 按策略获取会话锁，返回的release必须在历史保存后调用
+func (l *turnLocks) acquire(ctx context.Context, key string) (func(), error) {
+	if l.policy == TurnPolicyAllow {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &turnLock{sem: make(chan struct{}, 1)}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	if l.policy == TurnPolicyReject {
+		select {
+		case lock.sem <- struct{}{}:
+		default:
+			l.unref(key, lock)
+			return nil, ErrConversationBusy
+		}
+	} else {
+		select {
+		case lock.sem <- struct{}{}:
+		case <-ctx.Done():
+			l.unref(key, lock)
+			return nil, ctx.Err()
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-lock.sem
+			l.unref(key, lock)
+		})
+	}, nil
+}
+
+func (l *turnLocks) unref(key string, lock *turnLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.locks, key)
+	}
+}
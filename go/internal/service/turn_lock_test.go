@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTurnLocksReject(t *testing.T) {
+	l := newTurnLocks(TurnPolicyReject)
+	release, err := l.acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := l.acquire(context.Background(), "a"); !errors.Is(err, ErrConversationBusy) {
+		t.Fatalf("同一会话第二次acquire err = %v, want ErrConversationBusy", err)
+	}
+	// 不同会话互不影响
+	releaseB, err := l.acquire(context.Background(), "b")
+	if err != nil {
+		t.Fatalf("acquire(b): %v", err)
+	}
+	releaseB()
+
+	release()
+	release() // 重复调用无副作用
+	release, err = l.acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("释放后acquire: %v", err)
+	}
+	release()
+	if n := len(l.locks); n != 0 {
+		t.Errorf("释放后仍有%d把锁未回收", n)
+	}
+}
+
+func TestTurnLocksQueue(t *testing.T) {
+	l := newTurnLocks(TurnPolicyQueue)
+	release, err := l.acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		r, err := l.acquire(context.Background(), "a")
+		if err != nil {
+			t.Errorf("排队acquire: %v", err)
+			close(acquired)
+			return
+		}
+		acquired <- r
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("上一轮未释放时不应获取到锁")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case r := <-acquired:
+		if r != nil {
+			r()
+		}
+	case <-time.After(time.Second):
+		t.Fatal("上一轮释放后排队的请求未获取到锁")
+	}
+	if n := len(l.locks); n != 0 {
+		t.Errorf("释放后仍有%d把锁未回收", n)
+	}
+}
+
+func TestTurnLocksQueueCanceled(t *testing.T) {
+	l := newTurnLocks(TurnPolicyQueue)
+	release, err := l.acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("等待超时 err = %v, want DeadlineExceeded", err)
+	}
+	if refs := l.locks["a"].refs; refs != 1 {
+		t.Errorf("取消等待后refs = %d, want 1", refs)
+	}
+	release()
+	if n := len(l.locks); n != 0 {
+		t.Errorf("释放后仍有%d把锁未回收", n)
+	}
+}
+
+func TestTurnLocksAllow(t *testing.T) {
+	l := newTurnLocks(TurnPolicyAllow)
+	for i := 0; i < 3; i++ {
+		release, err := l.acquire(context.Background(), "a")
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		defer release()
+	}
+	if n := len(l.locks); n != 0 {
+		t.Errorf("allow策略不应创建锁，got %d", n)
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"Voice_Assistant/internal/auth"
+	"Voice_Assistant/internal/model"
+	"Voice_Assistant/internal/repository"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 会话令牌前缀（用于与API密钥区分）
+const SessionTokenPrefix = "vs_"
+
+var (
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	ErrInvalidSession     = errors.New("会话无效或已过期")
+	ErrUsernameTaken      = errors.New("用户名已存在")
+	ErrRegisterDisabled   = errors.New("未开放注册")
+)
+
+// UserService 用户与登录会话业务接口
+type UserService interface {
+	// 查询所有用户（仅管理员）
+	SelectAll(ctx context.Context) ([]model.User, error)
+	// 按ID查询用户
+	SelectByID(ctx context.Context, id string) (*model.User, error)
+	// 注册用户（第一个注册的用户为管理员）
+	Register(ctx context.Context, username, password, displayName string) (*model.User, error)
+	// 登录，返回会话令牌与过期时间
+	Login(ctx context.Context, username, password string) (string, string, *model.User, error)
+	// 注销会话
+	Logout(ctx context.Context, token string) error
+	// 校验会话令牌，返回对应用户
+	Authenticate(ctx context.Context, token string) (*model.User, error)
+}
+
+type userServiceImpl struct {
+	userRepo      repository.UserRepo
+	allowRegister bool
+	sessionTTL    time.Duration
+}
+
+func NewUserService(userRepo repository.UserRepo, allowRegister bool, sessionTTLHours int) UserService {
+	if sessionTTLHours <= 0 {
+		sessionTTLHours = 24 * 7
+	}
+	return &userServiceImpl{
+		userRepo:      userRepo,
+		allowRegister: allowRegister,
+		sessionTTL:    time.Duration(sessionTTLHours) * time.Hour,
+	}
+}
+
+// SelectAll 查询所有用户
+func (s *userServiceImpl) SelectAll(ctx context.Context) ([]model.User, error) {
+	users, err := s.userRepo.SelectAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	return users, nil
+}
+
+// SelectByID 按ID查询用户
+func (s *userServiceImpl) SelectByID(ctx context.Context, id string) (*model.User, error) {
+	user, err := s.userRepo.SelectByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	return user, nil
+}
+
+// Register 注册用户（未开放注册时仅允许创建第一个用户或由管理员创建）
+func (s *userServiceImpl) Register(ctx context.Context, username, password, displayName string) (*model.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, errors.New("用户名为必填项")
+	}
+	if len(password) < 8 {
+		return nil, errors.New("密码长度不能少于8位")
+	}
+
+	count, err := s.userRepo.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := auth.FromContext(ctx); count > 0 && !s.allowRegister && !(ok && p.Admin) {
+		return nil, ErrRegisterDisabled
+	}
+
+	if _, err := s.userRepo.SelectByUsername(ctx, username); err == nil {
+		return nil, ErrUsernameTaken
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("密码加密失败: %w", err)
+	}
+
+	role := model.RoleUser
+	if count == 0 {
+		role = model.RoleAdmin
+	}
+	if displayName == "" {
+		displayName = username
+	}
+
+	user := &model.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		PasswordHash: string(hash),
+		DisplayName:  displayName,
+		Role:         role,
+		GmtCreate:    time.Now().Format("2006-01-02 15:04:05"),
+	}
+	return s.userRepo.Save(ctx, user)
+}
+
+// Login 校验密码并创建会话
+func (s *userServiceImpl) Login(ctx context.Context, username, password string) (string, string, *model.User, error) {
+	user, err := s.userRepo.SelectByUsername(ctx, strings.TrimSpace(username))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", nil, ErrInvalidCredentials
+		}
+		return "", "", nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return "", "", nil, ErrInvalidCredentials
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", nil, fmt.Errorf("生成会话令牌失败: %w", err)
+	}
+	token := SessionTokenPrefix + hex.EncodeToString(buf)
+
+	now := time.Now()
+	session := &model.Session{
+		TokenHash: hashAPIKey(token),
+		UserID:    user.ID,
+		ExpiresAt: now.Add(s.sessionTTL).Format("2006-01-02 15:04:05"),
+		GmtCreate: now.Format("2006-01-02 15:04:05"),
+	}
+	if err := s.userRepo.SaveSession(ctx, session); err != nil {
+		return "", "", nil, err
+	}
+	return token, session.ExpiresAt, user, nil
+}
+
+// Logout 删除会话
+func (s *userServiceImpl) Logout(ctx context.Context, token string) error {
+	return s.userRepo.DeleteSessionByHash(ctx, hashAPIKey(token))
+}
+
+// Authenticate 校验会话令牌（过期会话会被清理）
+func (s *userServiceImpl) Authenticate(ctx context.Context, token string) (*model.User, error) {
+	session, err := s.userRepo.SelectSessionByHash(ctx, hashAPIKey(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidSession
+		}
+		return nil, err
+	}
+
+	expiresAt, err := time.ParseInLocation("2006-01-02 15:04:05", session.ExpiresAt, time.Local)
+	if err != nil || time.Now().After(expiresAt) {
+		_ = s.userRepo.DeleteSessionByHash(ctx, session.TokenHash)
+		return nil, ErrInvalidSession
+	}
+
+	user, err := s.userRepo.SelectByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidSession
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	return user, nil
+}
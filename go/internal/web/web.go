@@ -0,0 +1,119 @@
+package web
+
+import (
+	"Voice_Assistant/internal/model"
+	"embed"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 前端构建产物（vue目录下 npm run build 输出到 static/dist）
+//
+//go:embed all:static
+var static embed.FS
+
+// Vite构建的带哈希文件名的资源目录，可长期缓存
+const hashedAssetsDir = "assets/"
+
+// 预压缩变体（优先brotli）
+var encodings = []struct {
+	name string
+	ext  string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// Handler 前端静态资源处理器（SPA：无扩展名的未知路径回退到index.html）；前端未构建时返回nil
+func Handler() gin.HandlerFunc {
+	dist, err := fs.Sub(static, "static/dist")
+	if err != nil || !exists(dist, "index.html") {
+		return nil
+	}
+
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			c.JSON(http.StatusNotFound, model.Result{Success: false, Msg: "接口不存在"})
+			return
+		}
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.JSON(http.StatusMethodNotAllowed, model.Result{Success: false, Msg: "不支持的请求方法"})
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean(c.Request.URL.Path), "/")
+		if name == "" {
+			name = "index.html"
+		}
+		if !exists(dist, name) {
+			// 带扩展名的缺失文件按404处理，其余交给前端路由
+			if path.Ext(name) != "" {
+				c.Status(http.StatusNotFound)
+				return
+			}
+			name = "index.html"
+		}
+		serveFile(c, dist, name)
+	}
+}
+
+// serveFile 输出文件（按Accept-Encoding选择预压缩变体，并设置缓存策略）
+func serveFile(c *gin.Context, dist fs.FS, name string) {
+	h := c.Writer.Header()
+	if strings.HasPrefix(name, hashedAssetsDir) {
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		// index.html等入口文件每次校验，保证发布后立即加载新资源
+		h.Set("Cache-Control", "no-cache")
+	}
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h.Set("Content-Type", contentType)
+	h.Add("Vary", "Accept-Encoding")
+
+	file := name
+	accept := c.GetHeader("Accept-Encoding")
+	for _, enc := range encodings {
+		if acceptsEncoding(accept, enc.name) && exists(dist, name+enc.ext) {
+			h.Set("Content-Encoding", enc.name)
+			file = name + enc.ext
+			break
+		}
+	}
+
+	f, err := dist.Open(file)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	// embed.FS的文件均实现io.ReadSeeker
+	http.ServeContent(c.Writer, c.Request, name, time.Time{}, f.(io.ReadSeeker))
+}
+
+func exists(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && !info.IsDir()
+}
+
+// acceptsEncoding 判断客户端是否接受指定编码（忽略q=0）
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		token, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(token), encoding) {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
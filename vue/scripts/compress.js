@@ -0,0 +1,24 @@
+// 为构建产物生成 gzip / brotli 预压缩文件，由Go服务按 Accept-Encoding 直接返回
+import { readdirSync, readFileSync, statSync, writeFileSync } from 'node:fs'
+import { extname, join } from 'node:path'
+import { fileURLToPath } from 'node:url'
+import { brotliCompressSync, gzipSync, constants } from 'node:zlib'
+
+const outDir = fileURLToPath(new URL('../../go/internal/web/static/dist', import.meta.url))
+const compressible = new Set(['.html', '.js', '.css', '.svg', '.json', '.txt', '.map'])
+const minSize = 1024
+
+const walk = (dir) => readdirSync(dir).flatMap((name) => {
+  const path = join(dir, name)
+  return statSync(path).isDirectory() ? walk(path) : [path]
+})
+
+for (const file of walk(outDir)) {
+  if (!compressible.has(extname(file))) continue
+  const data = readFileSync(file)
+  if (data.length < minSize) continue
+  writeFileSync(`${file}.gz`, gzipSync(data, { level: 9 }))
+  writeFileSync(`${file}.br`, brotliCompressSync(data, {
+    params: { [constants.BROTLI_PARAM_QUALITY]: constants.BROTLI_MAX_QUALITY },
+  }))
+}
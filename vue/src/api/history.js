@@ -1,4 +1,4 @@
-import request from '@/utils/request'
+import request, { authHeaders, redirectToLogin } from '@/utils/request'
 
 export default {
     getByAssistantId: (assistantId) => request.get(`/api/voice-robot/v1/history/${assistantId}`),
@@ -11,11 +11,15 @@ export default {
     try {
       const response = await fetch(url, {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
+        headers: { 'Content-Type': 'application/json', ...authHeaders() },
         body: JSON.stringify(data),
         signal: signal,
       });
 
+      if (response.status === 401) {
+        redirectToLogin();
+        throw new Error('未授权，请重新登录');
+      }
       if (!response.ok) {
         throw new Error(`请求失败: ${response.status} ${response.statusText}`);
       }
@@ -0,0 +1,13 @@
+import request from '@/utils/request'
+
+// 用户 API（对应路由：/api/voice-robot/v1/user）
+export default {
+  // 登录，返回 { token, expires_at, user }
+  login: (data) => request.post('/api/voice-robot/v1/user/login', data),
+
+  // 注销当前会话
+  logout: () => request.post('/api/voice-robot/v1/user/logout'),
+
+  // 获取当前登录用户
+  me: () => request.get('/api/voice-robot/v1/user/me')
+}
@@ -9,7 +9,12 @@ const router = createRouter({
       // 修改为返回 Promise 的函数
       component: () => import("../views/Home.vue"),
     },
+    {
+      path: '/login',
+      name: 'login',
+      component: () => import("../views/Login.vue"),
+    },
   ]
 })
 
-export default router
\ No newline at end of file
+export default router
@@ -0,0 +1,8 @@
+// 登录会话令牌（vs_开头，由 /user/login 下发），保存在 localStorage
+const TOKEN_KEY = 'voice_assistant_token'
+
+export const getToken = () => localStorage.getItem(TOKEN_KEY) || ''
+
+export const setToken = (token) => localStorage.setItem(TOKEN_KEY, token)
+
+export const clearToken = () => localStorage.removeItem(TOKEN_KEY)
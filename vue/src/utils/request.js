@@ -1,13 +1,36 @@
 import axios from 'axios'
+import router from '@/router'
+import { getToken, clearToken } from '@/utils/auth'
 
 const request = axios.create({
-  baseURL: 'http://localhost:8080',
+  // 默认同源（生产环境由Go服务直接提供前端，开发环境由Vite代理/api）
+  baseURL: import.meta.env.VITE_API_BASE_URL || '',
   timeout: 60000,
   headers: {
     'Content-Type': 'application/json;charset=utf-8'
   }
 })
 
+// 附加登录会话令牌（不在前端内置API密钥，构建产物会被公开访问）
+export const authHeaders = () => {
+  const token = getToken()
+  return token ? { Authorization: `Bearer ${token}` } : {}
+}
+
+// 会话失效时清除令牌并跳转登录页
+export const redirectToLogin = () => {
+  clearToken()
+  const current = router.currentRoute.value
+  if (current.name !== 'login') {
+    router.replace({ name: 'login', query: { redirect: current.fullPath } })
+  }
+}
+
+request.interceptors.request.use((config) => {
+  Object.assign(config.headers, authHeaders())
+  return config
+})
+
 request.interceptors.response.use(
   (response) => {
     if (response.config.responseType === 'blob') {
@@ -31,7 +54,10 @@ request.interceptors.response.use(
     if (error.response) {
       switch (error.response.status) {
         case 401:
-          errorMsg = '未授权，请重新登录'
+          errorMsg = error.response.data?.msg || '未授权，请重新登录'
+          if (!error.config.url.endsWith('/user/login')) {
+            redirectToLogin()
+          }
           break
         case 403:
           errorMsg = '权限不足'
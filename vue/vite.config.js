@@ -9,6 +9,11 @@ export default defineConfig({
       '@': fileURLToPath(new URL('./src', import.meta.url))
     }
   },
+  // 构建产物输出到Go模块内，由 go:embed 打包进二进制
+  build: {
+    outDir: '../go/internal/web/static/dist',
+    emptyOutDir: true
+  },
   server: {
     proxy: {
       '/api': {